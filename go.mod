@@ -2,6 +2,13 @@ module github.com/bcdannyboy/montecargo
 
 go 1.19
 
+require (
+	github.com/gonum/stat v0.0.0-20181125101827-41a0da705a5b
+	github.com/stretchr/testify v1.8.4
+	gonum.org/v1/gonum v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/gonum/blas v0.0.0-20181208220705-f22b278b28ac // indirect
@@ -9,11 +16,7 @@ require (
 	github.com/gonum/internal v0.0.0-20181124074243-f884aa714029 // indirect
 	github.com/gonum/lapack v0.0.0-20181123203213-e4cdc5a0bff9 // indirect
 	github.com/gonum/matrix v0.0.0-20181209220409-c518dec07be9 // indirect
-	github.com/gonum/stat v0.0.0-20181125101827-41a0da705a5b // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
-	github.com/stretchr/testify v1.8.4 // indirect
 	golang.org/x/exp v0.0.0-20230321023759-10a507213a29 // indirect
-	gonum.org/v1/gonum v0.14.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,279 @@
+// Package cli implements the montecargo command.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+)
+
+// Exit codes of the montecargo command.
+const (
+	ExitOK         = 0 // The command succeeded
+	ExitRuntime    = 1 // The command failed while running, e.g. a file couldn't be read or written
+	ExitUsage      = 2 // The command line was invalid
+	ExitValidation = 3 // The model is invalid
+)
+
+// defaultTrials is the number of trials run when neither the model nor the command line sets one.
+const defaultTrials = 100_000
+
+// command is a subcommand of montecargo.
+type command struct {
+	name    string
+	args    string // Positional arguments shown in the usage line
+	summary string
+	run     func(ctx context.Context, env *environment, args []string) error
+}
+
+var commands = []command{
+	{"run", "MODEL", "simulate a model and print the results of each event", runCommand},
+	{"validate", "MODEL...", "check models for errors without simulating them", validateCommand},
+	{"report", "MODEL", "simulate a model and report its aggregate loss and controls, as text, JSON or HTML", reportCommand},
+	{"compare", "BASELINE MODEL", "simulate two models with the same random numbers and compare them", compareCommand},
+	{"graph", "MODEL", "draw the dependency graph of a model as DOT, Mermaid or SVG", graphCommand},
+	{"init", "[FILE]", "write a starter model to FILE, or to standard output", initCommand},
+}
+
+// environment is where a command writes its output.
+type environment struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// usageError is an invalid command line.
+type usageError struct {
+	flags *flag.FlagSet
+	err   error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+// Run runs the montecargo command with the given arguments, excluding the program name, and
+// returns its exit code. An interrupt cancels the simulation in progress.
+func Run(args []string, stdout, stderr io.Writer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	env := &environment{stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		printUsage(stderr)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(stdout)
+		return ExitOK
+	case "version", "-version", "--version":
+		fmt.Fprintln(stdout, "montecargo", montecargo.Version)
+		return ExitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return env.exitCode(cmd, cmd.run(ctx, env, args[1:]))
+		}
+	}
+
+	fmt.Fprintf(stderr, "montecargo: unknown command %q\n\n", args[0])
+	printUsage(stderr)
+	return ExitUsage
+}
+
+// exitCode reports the error of a command and returns the exit code it maps to.
+func (env *environment) exitCode(cmd command, err error) int {
+	var usageErr usageError
+	var modelErrs montecargo.ModelErrors
+	var diagnostics montecargo.Diagnostics
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, flag.ErrHelp) && errors.As(err, &usageErr):
+		printCommandUsage(env.stdout, cmd, usageErr.flags)
+		return ExitOK
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(env.stderr, "montecargo %s: %v\n", cmd.name, err)
+		printCommandUsage(env.stderr, cmd, usageErr.flags)
+		return ExitUsage
+	case errors.As(err, &modelErrs):
+		for _, modelErr := range modelErrs {
+			fmt.Fprintln(env.stderr, modelErr)
+		}
+		return ExitValidation
+	case errors.As(err, &diagnostics):
+		for _, diagnostic := range diagnostics {
+			fmt.Fprintf(env.stderr, "montecargo %s: %s\n", cmd.name, diagnostic)
+		}
+		return ExitValidation
+	default:
+		fmt.Fprintf(env.stderr, "montecargo %s: %v\n", cmd.name, err)
+		return ExitRuntime
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: montecargo <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "montecargo <command> -h" for the flags of a command.`)
+	fmt.Fprintf(w, "Exit codes: %d success, %d runtime error, %d usage error, %d invalid model.\n", ExitOK, ExitRuntime, ExitUsage, ExitValidation)
+}
+
+func printCommandUsage(w io.Writer, cmd command, flags *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: montecargo %s [flags] %s\n", cmd.name, cmd.args)
+	fmt.Fprintf(w, "%s%s.\n", strings.ToUpper(cmd.summary[:1]), cmd.summary[1:])
+	if flags == nil {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	flags.SetOutput(w)
+	flags.PrintDefaults()
+	flags.SetOutput(io.Discard)
+}
+
+// newFlagSet returns the flag set of a command. Errors are reported by exitCode.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {}
+	return flags
+}
+
+// parseArgs parses the flags of a command, which may come before or after its positional
+// arguments, and checks the number of positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, usageError{flags, err}
+		}
+		rest := flags.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		args = rest
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	switch {
+	case len(positional) < min:
+		return nil, usageError{flags, errors.New("missing arguments")}
+	case max >= 0 && len(positional) > max:
+		return nil, usageError{flags, fmt.Errorf("unexpected arguments: %s", strings.Join(positional[max:], " "))}
+	}
+	return positional, nil
+}
+
+// usagef returns a usage error for the command line of the flag set.
+func usagef(flags *flag.FlagSet, format string, args ...interface{}) error {
+	return usageError{flags, fmt.Errorf(format, args...)}
+}
+
+// settingsFlags override the settings of a model.
+type settingsFlags struct {
+	flags   *flag.FlagSet
+	trials  int
+	seed    int64
+	workers int
+	horizon string
+}
+
+func addSettingsFlags(flags *flag.FlagSet) *settingsFlags {
+	s := &settingsFlags{flags: flags}
+	flags.IntVar(&s.trials, "trials", 0, fmt.Sprintf("number of trials (default: the model's, or %d)", defaultTrials))
+	flags.Int64Var(&s.seed, "seed", 0, "random seed, for reproducible runs (default: the model's, or the clock)")
+	flags.IntVar(&s.workers, "workers", 0, "number of worker goroutines (default: the model's, or the number of CPU cores)")
+	flags.StringVar(&s.horizon, "horizon", "", `period each trial covers, e.g. "P1Y" or "quarterly" (default: the model's, or a year)`)
+	return s
+}
+
+// apply returns the settings of the model with the flags that were set applied to them.
+func (s *settingsFlags) apply(settings montecargo.ModelSettings) (montecargo.ModelSettings, error) {
+	var err error
+	s.flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "trials":
+			if s.trials < 1 {
+				err = usagef(s.flags, "-trials must be at least 1")
+			}
+			settings.Trials = s.trials
+		case "seed":
+			seed := s.seed
+			settings.Seed = &seed
+		case "workers":
+			if s.workers < 1 {
+				err = usagef(s.flags, "-workers must be at least 1")
+			}
+			settings.Workers = s.workers
+		case "horizon":
+			horizon, parseErr := montecargo.ParseTimeframe(s.horizon)
+			if parseErr != nil {
+				err = usagef(s.flags, "-horizon: %v", parseErr)
+			}
+			settings.Horizon = horizon
+		}
+	})
+	if settings.Trials == 0 {
+		settings.Trials = defaultTrials
+	}
+	return settings, err
+}
+
+// formatFlag adds the -format flag, which accepts one of formats and defaults to the first.
+func formatFlag(flags *flag.FlagSet, formats ...string) func() (string, error) {
+	format := flags.String("format", formats[0], "output format: "+strings.Join(formats, ", "))
+	return func() (string, error) {
+		for _, f := range formats {
+			if strings.EqualFold(*format, f) {
+				return f, nil
+			}
+		}
+		return "", usagef(flags, "unknown format %q, want one of %s", *format, strings.Join(formats, ", "))
+	}
+}
+
+// outputFlag adds the -o flag, which writes the output of a command to a file instead of stdout.
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("o", "", "write the output to this file instead of standard output")
+}
+
+// writeOutput calls write with the output file named by path, or with stdout when path is empty.
+func (env *environment) writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(env.stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,595 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+)
+
+// defaultLevel is the confidence level value at risk and expected shortfall are reported at.
+const defaultLevel = 0.99
+
+// simulation is a model simulated with the settings of its command line.
+type simulation struct {
+	path     string
+	model    *montecargo.Model
+	settings montecargo.ModelSettings
+	result   montecargo.SimulationResult
+}
+
+// loadSettings loads a model and applies the settings flags to its settings.
+func loadSettings(path string, flags *settingsFlags) (*montecargo.Model, montecargo.ModelSettings, error) {
+	model, err := montecargo.LoadModel(path)
+	if err != nil {
+		return nil, montecargo.ModelSettings{}, err
+	}
+	settings, err := flags.apply(model.Settings)
+	return model, settings, err
+}
+
+// simulate runs a model with the given settings and any extra options.
+func simulate(ctx context.Context, path string, model *montecargo.Model, settings montecargo.ModelSettings, opts ...montecargo.SimulationOption) (*simulation, error) {
+	simulator := montecargo.NewSimulator(append(settings.Options(), opts...)...)
+	result, err := simulator.Run(ctx, model.Events, settings.Trials, model.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+	return &simulation{path: path, model: model, settings: settings, result: result}, nil
+}
+
+// warn prints the timeframe warnings of a simulation to stderr.
+func (env *environment) warn(sim *simulation) {
+	for _, warning := range sim.result.Warnings {
+		fmt.Fprintf(env.stderr, "%s: warning: %s\n", sim.path, warning)
+	}
+}
+
+func runCommand(ctx context.Context, env *environment, args []string) error {
+	flags := newFlagSet("run")
+	settingsFlags := addSettingsFlags(flags)
+	format := formatFlag(flags, "text", "json", "csv", "jsonl")
+	output := outputFlag(flags)
+	positional, err := parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := format()
+	if err != nil {
+		return err
+	}
+	if outputFormat == "csv" && *output == "" {
+		return usagef(flags, "-format csv writes the events and the percentiles to separate files and needs -o")
+	}
+
+	model, settings, err := loadSettings(positional[0], settingsFlags)
+	if err != nil {
+		return err
+	}
+	sim, err := simulate(ctx, positional[0], model, settings)
+	if err != nil {
+		return err
+	}
+	env.warn(sim)
+
+	if outputFormat == "text" {
+		return env.writeOutput(*output, func(w io.Writer) error {
+			return writeRunText(w, sim)
+		})
+	}
+
+	metadata, err := montecargo.NewExportMetadata(sim.result, model.Events, model.Dependencies)
+	if err != nil {
+		return err
+	}
+	export := montecargo.NewResultExport(metadata, sim.result, model.Events)
+	switch outputFormat {
+	case "csv":
+		if err := env.writeOutput(*output, export.WriteEventsCSV); err != nil {
+			return err
+		}
+		return env.writeOutput(percentilesPath(*output), export.WritePercentilesCSV)
+	case "jsonl":
+		return env.writeOutput(*output, func(w io.Writer) error {
+			return montecargo.WriteTrialsJSONL(w, metadata, sim.result)
+		})
+	default:
+		return env.writeOutput(*output, export.WriteJSON)
+	}
+}
+
+// percentilesPath returns the file the percentiles of a CSV export are written to:
+// results.csv becomes results-percentiles.csv.
+func percentilesPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-percentiles" + ext
+}
+
+func writeRunText(w io.Writer, sim *simulation) error {
+	report := montecargo.NewLossReport(sim.result, defaultLevel)
+	fmt.Fprintf(w, "Model:   %s\n", sim.path)
+	fmt.Fprintf(w, "Trials:  %d (seed %d, horizon %s, %s strategy)\n\n", sim.result.Trials, sim.result.Seed, sim.result.Horizon, sim.settings.Strategy)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "EVENT\tPROBABILITY\tSTD DEV\tFREQUENCY\tLOSS IF IT OCCURS\tEXPECTED LOSS")
+	for _, event := range sim.model.Events {
+		stat := sim.result.EventStats[event.Name]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.3f\t%s\t%s\n", event.Name, formatPercent(stat.Probability), formatPercent(stat.StdDev), stat.MeanFrequency, formatMoney(stat.Loss.ConditionalMean), formatMoney(stat.Loss.ExpectedLoss))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	return writeLossReport(w, report)
+}
+
+func writeLossReport(w io.Writer, report montecargo.LossReport) error {
+	level := formatLevel(report.Level)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Expected loss\t%s\n", formatMoney(report.Mean))
+	fmt.Fprintf(tw, "Median loss (P50)\t%s\n", formatMoney(report.P50))
+	fmt.Fprintf(tw, "P90 loss\t%s\n", formatMoney(report.P90))
+	fmt.Fprintf(tw, "P99 loss\t%s\n", formatMoney(report.P99))
+	fmt.Fprintf(tw, "VaR %s\t%s\n", level, formatMoney(report.VaR))
+	fmt.Fprintf(tw, "TVaR %s\t%s\n", level, formatMoney(report.TVaR))
+	fmt.Fprintf(tw, "Largest loss\t%s\n", formatMoney(report.Max))
+	return tw.Flush()
+}
+
+type lossOutput struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	P50    float64 `json:"p50"`
+	P90    float64 `json:"p90"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	Level  float64 `json:"level"`
+	VaR    float64 `json:"var"`
+	TVaR   float64 `json:"tvar"`
+	Max    float64 `json:"max"`
+}
+
+func newLossOutput(report montecargo.LossReport) lossOutput {
+	return lossOutput{
+		Mean:   report.Mean,
+		StdDev: report.StdDev,
+		P50:    report.P50,
+		P90:    report.P90,
+		P95:    report.P95,
+		P99:    report.P99,
+		Level:  report.Level,
+		VaR:    report.VaR,
+		TVaR:   report.TVaR,
+		Max:    report.Max,
+	}
+}
+
+func validateCommand(ctx context.Context, env *environment, args []string) error {
+	flags := newFlagSet("validate")
+	paths, err := parseArgs(flags, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	var invalid montecargo.ModelErrors
+	for _, path := range paths {
+		model, err := montecargo.LoadModel(path)
+		var modelErrs montecargo.ModelErrors
+		if errors.As(err, &modelErrs) {
+			invalid = append(invalid, modelErrs...)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		dependencies := 0
+		for _, deps := range model.Dependencies {
+			dependencies += len(deps)
+		}
+		fmt.Fprintf(env.stdout, "%s: ok (%d events, %d dependencies)\n", path, len(model.Events), dependencies)
+
+		for _, warning := range montecargo.Validate(model.Events, model.Dependencies, model.Settings.Horizon).Warnings() {
+			fmt.Fprintf(env.stderr, "%s: %s\n", path, warning)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+func reportCommand(ctx context.Context, env *environment, args []string) error {
+	flags := newFlagSet("report")
+	settingsFlags := addSettingsFlags(flags)
+	level := flags.Float64("level", defaultLevel, "confidence level of value at risk and expected shortfall")
+	tolerance := flags.String("tolerance", "", `risk tolerance curve for the HTML report as LOSS:PROBABILITY pairs, e.g. "1e6:0.1,1e7:0.01"`)
+	title := flags.String("title", "", "title of the HTML report (default: the model file name)")
+	format := formatFlag(flags, "text", "json", "html")
+	output := outputFlag(flags)
+	positional, err := parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := format()
+	if err != nil {
+		return err
+	}
+	if *level <= 0 || *level >= 1 {
+		return usagef(flags, "-level must be between 0 and 1")
+	}
+	tolerancePoints, err := parseTolerance(*tolerance)
+	if err != nil {
+		return usagef(flags, "-tolerance: %v", err)
+	}
+
+	model, settings, err := loadSettings(positional[0], settingsFlags)
+	if err != nil {
+		return err
+	}
+	model.Settings = settings
+	riskReport, err := montecargo.NewSimulator(settings.Options()...).Report(ctx, model, settings.Trials, tolerancePoints)
+	if err != nil {
+		return err
+	}
+	riskReport.Level = *level
+	riskReport.Title = *title
+	if riskReport.Title == "" {
+		riskReport.Title = "Risk Report: " + filepath.Base(positional[0])
+	}
+	sim := &simulation{path: positional[0], model: model, settings: settings, result: riskReport.Result}
+	env.warn(sim)
+
+	report := newReportOutput(sim, montecargo.NewLossReport(sim.result, *level), riskReport.Controls)
+	return env.writeOutput(*output, func(w io.Writer) error {
+		switch outputFormat {
+		case "html":
+			return riskReport.WriteHTML(w)
+		case "json":
+			return writeJSON(w, report)
+		default:
+			return writeReportText(w, report)
+		}
+	})
+}
+
+// parseTolerance parses a risk tolerance curve written as comma separated LOSS:PROBABILITY
+// pairs.
+func parseTolerance(s string) ([]montecargo.TolerancePoint, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var points []montecargo.TolerancePoint
+	for _, pair := range strings.Split(s, ",") {
+		loss, probability, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("%q is not LOSS:PROBABILITY", pair)
+		}
+		point := montecargo.TolerancePoint{}
+		var err error
+		if point.Loss, err = strconv.ParseFloat(loss, 64); err != nil || point.Loss <= 0 {
+			return nil, fmt.Errorf("invalid loss %q", loss)
+		}
+		if point.Probability, err = strconv.ParseFloat(probability, 64); err != nil || point.Probability < 0 || point.Probability > 1 {
+			return nil, fmt.Errorf("invalid probability %q", probability)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// reportOutput is the JSON output of the report command.
+type reportOutput struct {
+	Model    string          `json:"model"`
+	Seed     int64           `json:"seed"`
+	Trials   int             `json:"trials"`
+	Horizon  string          `json:"horizon"`
+	Loss     lossOutput      `json:"loss"`
+	Events   []rankedEvent   `json:"events"`
+	Controls []controlOutput `json:"controls,omitempty"`
+
+	loss    montecargo.LossReport
+	horizon montecargo.Timeframe
+}
+
+// rankedEvent is a loss event and its share of the expected loss.
+type rankedEvent struct {
+	Name         string  `json:"name"`
+	Probability  float64 `json:"probability"`
+	ExpectedLoss float64 `json:"expectedLoss"`
+	Share        float64 `json:"share"`
+}
+
+type controlOutput struct {
+	Name               string  `json:"name"`
+	ExpectedCost       float64 `json:"expectedCost"`
+	AvoidedLoss        float64 `json:"avoidedLoss"`
+	NetBenefit         float64 `json:"netBenefit"`
+	ROSI               float64 `json:"rosi"`
+	PaybackProbability float64 `json:"paybackProbability"`
+}
+
+func newReportOutput(sim *simulation, loss montecargo.LossReport, controls []montecargo.ControlAnalysis) reportOutput {
+	report := reportOutput{
+		Model:   sim.path,
+		Seed:    sim.result.Seed,
+		Trials:  sim.result.Trials,
+		Horizon: sim.result.Horizon.ISO8601(),
+		Loss:    newLossOutput(loss),
+		loss:    loss,
+		horizon: sim.result.Horizon,
+	}
+
+	total := 0.0
+	for _, event := range sim.model.Events {
+		if !event.IsCostSaving {
+			total += sim.result.EventStats[event.Name].Loss.ExpectedLoss
+		}
+	}
+	for _, event := range sim.model.Events {
+		if event.IsCostSaving {
+			continue
+		}
+		stat := sim.result.EventStats[event.Name]
+		ranked := rankedEvent{Name: event.Name, Probability: stat.Probability, ExpectedLoss: stat.Loss.ExpectedLoss}
+		if total > 0 {
+			ranked.Share = stat.Loss.ExpectedLoss / total
+		}
+		report.Events = append(report.Events, ranked)
+	}
+	sort.SliceStable(report.Events, func(i, j int) bool {
+		return report.Events[i].ExpectedLoss > report.Events[j].ExpectedLoss
+	})
+
+	for _, control := range controls {
+		report.Controls = append(report.Controls, controlOutput{
+			Name:               control.Control,
+			ExpectedCost:       control.ExpectedCost,
+			AvoidedLoss:        control.AvoidedLoss.Mean,
+			NetBenefit:         control.NetBenefit,
+			ROSI:               control.ROSI,
+			PaybackProbability: control.PaybackProbability,
+		})
+	}
+	return report
+}
+
+func writeReportText(w io.Writer, report reportOutput) error {
+	fmt.Fprintf(w, "Risk report for %s\n", report.Model)
+	fmt.Fprintf(w, "%d trials, seed %d\n\n", report.Trials, report.Seed)
+
+	fmt.Fprintf(w, "Aggregate loss over %s\n", report.horizon)
+	if err := writeLossReport(w, report.loss); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Events by expected loss")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "EVENT\tPROBABILITY\tEXPECTED LOSS\tSHARE")
+	for _, event := range report.Events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", event.Name, formatPercent(event.Probability), formatMoney(event.ExpectedLoss), formatPercent(event.Share))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Controls) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Controls")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTROL\tEXPECTED COST\tAVOIDED LOSS\tNET BENEFIT\tROSI\tPAYBACK")
+	for _, control := range report.Controls {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", control.Name, formatMoney(control.ExpectedCost), formatMoney(control.AvoidedLoss), formatMoney(control.NetBenefit), formatPercent(control.ROSI), formatPercent(control.PaybackProbability))
+	}
+	return tw.Flush()
+}
+
+func compareCommand(ctx context.Context, env *environment, args []string) error {
+	flags := newFlagSet("compare")
+	settingsFlags := addSettingsFlags(flags)
+	format := formatFlag(flags, "text", "json")
+	output := outputFlag(flags)
+	positional, err := parseArgs(flags, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := format()
+	if err != nil {
+		return err
+	}
+
+	baselineModel, settings, err := loadSettings(positional[0], settingsFlags)
+	if err != nil {
+		return err
+	}
+	candidateModel, err := montecargo.LoadModel(positional[1])
+	if err != nil {
+		return err
+	}
+
+	// both models run with the baseline's settings and the same random numbers, so that the
+	// differences come from the models rather than from sampling noise
+	if settings.Seed == nil {
+		seed := time.Now().UnixNano()
+		settings.Seed = &seed
+	}
+	baseline, err := simulate(ctx, positional[0], baselineModel, settings, montecargo.WithCommonRandomNumbers())
+	if err != nil {
+		return err
+	}
+	candidate, err := simulate(ctx, positional[1], candidateModel, settings, montecargo.WithCommonRandomNumbers())
+	if err != nil {
+		return err
+	}
+	env.warn(baseline)
+	env.warn(candidate)
+
+	comparison := newComparisonOutput(baseline, candidate)
+	return env.writeOutput(*output, func(w io.Writer) error {
+		if outputFormat == "json" {
+			return writeJSON(w, comparison)
+		}
+		return writeComparisonText(w, comparison)
+	})
+}
+
+// comparisonOutput is the JSON output of the compare command.
+type comparisonOutput struct {
+	Baseline string            `json:"baseline"`
+	Model    string            `json:"model"`
+	Seed     int64             `json:"seed"`
+	Trials   int               `json:"trials"`
+	Horizon  string            `json:"horizon"`
+	Loss     lossComparison    `json:"loss"`
+	Events   []eventComparison `json:"events"`
+}
+
+type lossComparison struct {
+	Baseline lossOutput `json:"baseline"`
+	Model    lossOutput `json:"model"`
+}
+
+// eventComparison is an event of either model. An event missing from a model is null.
+type eventComparison struct {
+	Name     string                  `json:"name"`
+	Baseline *montecargo.EventExport `json:"baseline"`
+	Model    *montecargo.EventExport `json:"model"`
+}
+
+func newComparisonOutput(baseline, candidate *simulation) comparisonOutput {
+	comparison := comparisonOutput{
+		Baseline: baseline.path,
+		Model:    candidate.path,
+		Seed:     baseline.result.Seed,
+		Trials:   baseline.result.Trials,
+		Horizon:  baseline.result.Horizon.ISO8601(),
+		Loss: lossComparison{
+			Baseline: newLossOutput(montecargo.NewLossReport(baseline.result, defaultLevel)),
+			Model:    newLossOutput(montecargo.NewLossReport(candidate.result, defaultLevel)),
+		},
+	}
+
+	baselineEvents := montecargo.NewResultExport(montecargo.ExportMetadata{}, baseline.result, baseline.model.Events).Events
+	candidateEvents := montecargo.NewResultExport(montecargo.ExportMetadata{}, candidate.result, candidate.model.Events).Events
+	index := make(map[string]int)
+	for i := range baselineEvents {
+		index[baselineEvents[i].Name] = len(comparison.Events)
+		comparison.Events = append(comparison.Events, eventComparison{Name: baselineEvents[i].Name, Baseline: &baselineEvents[i]})
+	}
+	for i := range candidateEvents {
+		if j, ok := index[candidateEvents[i].Name]; ok {
+			comparison.Events[j].Model = &candidateEvents[i]
+			continue
+		}
+		comparison.Events = append(comparison.Events, eventComparison{Name: candidateEvents[i].Name, Model: &candidateEvents[i]})
+	}
+	return comparison
+}
+
+func writeComparisonText(w io.Writer, comparison comparisonOutput) error {
+	fmt.Fprintf(w, "Baseline: %s\n", comparison.Baseline)
+	fmt.Fprintf(w, "Model:    %s\n", comparison.Model)
+	fmt.Fprintf(w, "Trials:   %d (seed %d, horizon %s)\n\n", comparison.Trials, comparison.Seed, comparison.Horizon)
+
+	baseline, model := comparison.Loss.Baseline, comparison.Loss.Model
+	level := formatLevel(baseline.Level)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tBASELINE\tMODEL\tCHANGE")
+	rows := []struct {
+		name            string
+		baseline, model float64
+	}{
+		{"Expected loss", baseline.Mean, model.Mean},
+		{"P50 loss", baseline.P50, model.P50},
+		{"P90 loss", baseline.P90, model.P90},
+		{"P99 loss", baseline.P99, model.P99},
+		{"VaR " + level, baseline.VaR, model.VaR},
+		{"TVaR " + level, baseline.TVaR, model.TVaR},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", row.name, formatMoney(row.baseline), formatMoney(row.model), formatChange(row.baseline, row.model))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "EVENT\tBASELINE PROBABILITY\tMODEL PROBABILITY\tBASELINE EXPECTED LOSS\tMODEL EXPECTED LOSS\tCHANGE")
+	for _, event := range comparison.Events {
+		baselineProbability, modelProbability := "-", "-"
+		baselineLoss, modelLoss, change := "-", "-", "-"
+		if event.Baseline != nil {
+			baselineProbability, baselineLoss = formatPercent(event.Baseline.Probability), formatMoney(event.Baseline.ExpectedLoss)
+		}
+		if event.Model != nil {
+			modelProbability, modelLoss = formatPercent(event.Model.Probability), formatMoney(event.Model.ExpectedLoss)
+		}
+		if event.Baseline != nil && event.Model != nil {
+			change = formatChange(event.Baseline.ExpectedLoss, event.Model.ExpectedLoss)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", event.Name, baselineProbability, modelProbability, baselineLoss, modelLoss, change)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// formatMoney formats an amount in whole dollars with thousands separators.
+func formatMoney(amount float64) string {
+	sign := ""
+	if amount = math.Round(amount); amount < 0 {
+		sign, amount = "-", -amount
+	}
+	digits := strconv.FormatFloat(amount, 'f', 0, 64)
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return sign + "$" + grouped.String()
+}
+
+func formatPercent(fraction float64) string {
+	return fmt.Sprintf("%.2f%%", fraction*100)
+}
+
+// formatLevel formats a confidence level as a percentage without trailing zeros, e.g. "99.9%".
+func formatLevel(level float64) string {
+	return strconv.FormatFloat(level*100, 'f', -1, 64) + "%"
+}
+
+// formatChange formats the change from one amount to another, with the relative change when
+// the first amount isn't zero.
+func formatChange(from, to float64) string {
+	change := to - from
+	formatted := formatMoney(change)
+	if change >= 0 {
+		formatted = "+" + formatted
+	}
+	if from != 0 {
+		formatted += fmt.Sprintf(" (%+.1f%%)", change/math.Abs(from)*100)
+	}
+	return formatted
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"io"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+)
+
+func graphCommand(ctx context.Context, env *environment, args []string) error {
+	flags := newFlagSet("graph")
+	format := formatFlag(flags, "dot", "mermaid", "svg")
+	output := outputFlag(flags)
+	positional, err := parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := format()
+	if err != nil {
+		return err
+	}
+
+	model, err := montecargo.LoadModel(positional[0])
+	if err != nil {
+		return err
+	}
+	return env.writeOutput(*output, func(w io.Writer) error {
+		switch outputFormat {
+		case "mermaid":
+			return model.WriteMermaid(w)
+		case "svg":
+			return model.WriteSVG(w)
+		default:
+			return model.WriteDOT(w)
+		}
+	})
+}
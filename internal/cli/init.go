@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+)
+
+// starterModel is the model written by init: three threats that cascade into each other and two
+// controls that prevent them.
+//
+//go:embed starter.yaml
+var starterModel []byte
+
+func initCommand(ctx context.Context, env *environment, args []string) error {
+	flags := newFlagSet("init")
+	force := flags.Bool("force", false, "overwrite FILE if it exists")
+	positional, err := parseArgs(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 0 || positional[0] == "-" {
+		_, err := env.stdout.Write(starterModel)
+		return err
+	}
+
+	path := positional[0]
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		model, err := montecargo.ParseModel(starterModel, "starter.yaml")
+		if err != nil {
+			return err
+		}
+		err = montecargo.SaveModel(path, model)
+		if err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(path, starterModel, 0o644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.stdout, "wrote %s\n", path)
+	return nil
+}
@@ -0,0 +1,93 @@
+package montecargo
+
+import "sort"
+
+// conditional is the conditional probability table of an event, from either a dependency with
+// IfHappens and IfNotHappens or the event's ProbabilityTable.
+type conditional struct {
+	parents []string
+	// probabilities over the event's timeframe, indexed by the outcomes of the parents: bit i
+	// is set when parents[i] happens
+	probabilities []float64
+}
+
+// resolveConditional returns the conditional probability table of an event, or nil if its
+// probability doesn't depend on other events or it is disabled. The dependencies must be valid.
+func resolveConditional(event Event, dependencies []Dependency) *conditional {
+	if event.disabled {
+		return nil
+	}
+	for _, dep := range dependencies {
+		if dep.IfHappens != nil && dep.IfNotHappens != nil {
+			return &conditional{parents: []string{dep.EventName}, probabilities: []float64{*dep.IfNotHappens, *dep.IfHappens}}
+		}
+	}
+	if len(event.ProbabilityTable) == 0 {
+		return nil
+	}
+
+	table := &conditional{parents: tableParents(dependencies)}
+	table.probabilities = make([]float64, 1<<len(table.parents))
+	for _, row := range event.ProbabilityTable {
+		table.probabilities[table.outcomeIndex(func(i int) bool { return row.Given[table.parents[i]] })] = row.Probability
+	}
+	return table
+}
+
+// tableParents returns the names of the events a ProbabilityTable is over: the dependencies
+// without a Condition, probabilities of their own or Requires, sorted.
+func tableParents(dependencies []Dependency) []string {
+	var parents []string
+	for _, dep := range dependencies {
+		if dep.Condition == "" && dep.IfHappens == nil && dep.IfNotHappens == nil && dep.Requires == nil {
+			parents = appendUnique(parents, dep.EventName)
+		}
+	}
+	sort.Strings(parents)
+	return parents
+}
+
+// dependencyCondition returns the Condition a dependency holds the event to. A dependency with
+// only an EventName is on a row of the event's ProbabilityTable, or without one means
+// "happens".
+func dependencyCondition(event Event, dep Dependency) string {
+	if dep.Condition == "" && len(event.ProbabilityTable) == 0 && dep.IfHappens == nil && dep.IfNotHappens == nil && dep.Requires == nil {
+		return "happens"
+	}
+	return dep.Condition
+}
+
+// outcomeIndex returns the index of the probability for the outcomes of the parents.
+func (c *conditional) outcomeIndex(happens func(parent int) bool) int {
+	index := 0
+	for i := range c.parents {
+		if happens(i) {
+			index |= 1 << i
+		}
+	}
+	return index
+}
+
+// probability returns the probability of the event over the horizon given the outcomes of
+// its parents.
+func (c *conditional) probability(event Event, horizon Timeframe, happens func(parent int) bool) float64 {
+	return ConvertProbability(c.probabilities[c.outcomeIndex(happens)], event.Timeframe, horizon)
+}
+
+// expectedProbability returns the probability of the event over the horizon when each parent
+// happens independently with its probability in eventStats.
+func (c *conditional) expectedProbability(event Event, horizon Timeframe, eventStats map[string]EventStat) float64 {
+	expected := 0.0
+	for index, probability := range c.probabilities {
+		weight := 1.0
+		for i, parent := range c.parents {
+			if parentProb := eventStats[parent].Probability; index&(1<<i) != 0 {
+				weight *= parentProb
+			} else {
+				weight *= 1 - parentProb
+			}
+		}
+		expected += weight * ConvertProbability(probability, event.Timeframe, horizon)
+	}
+	return expected
+}
@@ -0,0 +1,190 @@
+package montecargo
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+// defaultRiskLevel is the confidence level value at risk and expected shortfall are measured at
+// in analyses that don't take one.
+const defaultRiskLevel = 0.99
+
+// ControlAnalysis is the return on security investment of a cost saving event. The benefit of
+// the control in a trial is the loss of the events it prevents, which are the events that
+// depend on it not happening, plus its own savings. Benefits are measured over the simulation
+// horizon against an implementation cost paid once for the horizon.
+type ControlAnalysis struct {
+	Control            string
+	Horizon            Timeframe
+	AvoidedLoss        LossReport // Distribution of the benefit of the control per trial
+	CostLower          float64    // Lower bound of the implementation cost, less its standard deviation
+	CostUpper          float64    // Upper bound of the implementation cost, plus its standard deviation
+	ExpectedCost       float64
+	NetBenefit         float64 // Mean benefit less the expected implementation cost
+	ROSI               float64 // Return on security investment: NetBenefit / ExpectedCost, zero for controls without a cost
+	PaybackProbability float64 // Fraction of trials in which the benefit exceeds the implementation cost
+}
+
+// AnalyzeControls simulates the events once as given and once without each cost saving event,
+// using common random numbers so that the runs can be compared trial by trial, and returns the
+// analysis of every cost saving event in the order of events. With StrategyJoint the benefit
+// of each trial reflects the dependencies of that trial; with StrategyLevels only the mean
+// benefit is meaningful.
+func (s *Simulator) AnalyzeControls(ctx context.Context, events []Event, numSimulations int, dependencies map[string][]Dependency) ([]ControlAnalysis, error) {
+	paired := s.withCommonRandomNumbers()
+	residual, err := paired.Run(ctx, events, numSimulations, dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	var analyses []ControlAnalysis
+	for i, control := range events {
+		if !control.IsCostSaving {
+			continue
+		}
+
+		withoutControl := append([]Event(nil), events...)
+		withoutControl[i] = disableEvent(control)
+		unmitigated, err := paired.Run(ctx, withoutControl, numSimulations, dependencies)
+		if err != nil {
+			return nil, err
+		}
+
+		analyses = append(analyses, analyzeControl(control, residual, unmitigated, paired.config.seed))
+	}
+	return analyses, nil
+}
+
+// analyzeControl compares the trials of a run with the control to the same trials without it.
+func analyzeControl(control Event, residual, unmitigated SimulationResult, seed int64) ControlAnalysis {
+	trials := len(residual.TrialLosses)
+	if len(unmitigated.TrialLosses) < trials {
+		trials = len(unmitigated.TrialLosses)
+	}
+
+	costRand := implementationCostRand(control, seed)
+	benefits := make([]float64, trials)
+	totalCost, paidBack := 0.0, 0
+	for i := range benefits {
+		benefits[i] = unmitigated.TrialLosses[i] - residual.TrialLosses[i] + residual.TrialSavings[i] - unmitigated.TrialSavings[i]
+		cost := sampleImplementationCost(control, costRand)
+		totalCost += cost
+		if benefits[i] > cost {
+			paidBack++
+		}
+	}
+
+	analysis := ControlAnalysis{
+		Control:     control.Name,
+		Horizon:     residual.Horizon,
+		AvoidedLoss: newLossReport(benefits, defaultRiskLevel),
+	}
+	analysis.CostLower, analysis.CostUpper = implementationCostBounds(control)
+	if trials > 0 {
+		analysis.ExpectedCost = totalCost / float64(trials)
+		analysis.PaybackProbability = float64(paidBack) / float64(trials)
+	}
+	analysis.NetBenefit = analysis.AvoidedLoss.Mean - analysis.ExpectedCost
+	if analysis.ExpectedCost > 0 {
+		analysis.ROSI = analysis.NetBenefit / analysis.ExpectedCost
+	}
+	return analysis
+}
+
+// implementationCostRand returns the generator the implementation costs of a control are drawn
+// from in a run with the given seed.
+func implementationCostRand(control Event, seed int64) *rand.Rand {
+	return rand.New(newXoshiroSource(int64(mix64(uint64(seed) ^ eventKey(control.Name)))))
+}
+
+// expectedImplementationCost is the mean implementation cost of a control over the trials of a
+// run with the given seed, drawn as analyzeControl draws it for ControlAnalysis.ExpectedCost.
+func expectedImplementationCost(control Event, seed int64, trials int) float64 {
+	if trials < 1 {
+		return 0
+	}
+	costRand := implementationCostRand(control, seed)
+	total := 0.0
+	for i := 0; i < trials; i++ {
+		total += sampleImplementationCost(control, costRand)
+	}
+	return total / float64(trials)
+}
+
+// netTrialLosses returns the loss of each trial less the savings of its cost saving events, the
+// measure AnalyzeControls compares runs on.
+func netTrialLosses(result SimulationResult) []float64 {
+	net := make([]float64, len(result.TrialLosses))
+	for i, loss := range result.TrialLosses {
+		net[i] = loss
+		if i < len(result.TrialSavings) {
+			net[i] -= result.TrialSavings[i]
+		}
+	}
+	return net
+}
+
+// implementationCostBounds widens the event's implementation cost bounds by their standard
+// deviations. A missing bound takes the value of the other.
+func implementationCostBounds(event Event) (lower, upper float64) {
+	switch {
+	case event.CostOfImplementationLower != nil && event.CostOfImplementationUpper != nil:
+		lower, upper = *event.CostOfImplementationLower, *event.CostOfImplementationUpper
+	case event.CostOfImplementationLower != nil:
+		lower, upper = *event.CostOfImplementationLower, *event.CostOfImplementationLower
+	case event.CostOfImplementationUpper != nil:
+		lower, upper = *event.CostOfImplementationUpper, *event.CostOfImplementationUpper
+	default:
+		return 0, 0
+	}
+
+	if event.CostOfImplementationLowerStdDev != nil {
+		lower -= *event.CostOfImplementationLowerStdDev
+	}
+	if event.CostOfImplementationUpperStdDev != nil {
+		upper += *event.CostOfImplementationUpperStdDev
+	}
+	return math.Max(0, lower), math.Max(0, upper)
+}
+
+// sampleImplementationCost draws the implementation cost of a control: each bound is perturbed
+// by its standard deviation and the cost is drawn uniformly between them.
+func sampleImplementationCost(event Event, localRand *rand.Rand) float64 {
+	if event.CostOfImplementationLower == nil && event.CostOfImplementationUpper == nil {
+		return 0
+	}
+
+	lower, upper := 0.0, 0.0
+	if event.CostOfImplementationLower != nil {
+		lower = *event.CostOfImplementationLower
+		if event.CostOfImplementationLowerStdDev != nil {
+			lower += localRand.NormFloat64() * *event.CostOfImplementationLowerStdDev
+		}
+	}
+	if event.CostOfImplementationUpper != nil {
+		upper = *event.CostOfImplementationUpper
+		if event.CostOfImplementationUpperStdDev != nil {
+			upper += localRand.NormFloat64() * *event.CostOfImplementationUpperStdDev
+		}
+	}
+	if event.CostOfImplementationLower == nil {
+		lower = upper
+	}
+	if event.CostOfImplementationUpper == nil {
+		upper = lower
+	}
+	if lower > upper {
+		lower, upper = upper, lower
+	}
+
+	return math.Max(0, lower+(upper-lower)*localRand.Float64())
+}
+
+// eventKey hashes an event's name.
+func eventKey(name string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	return hash.Sum64()
+}
@@ -0,0 +1,400 @@
+package montecargo
+
+import (
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Copula selects how correlated events draw their joint samples.
+type Copula int
+
+const (
+	// CopulaGaussian joins the events through a multivariate normal distribution.
+	CopulaGaussian Copula = iota
+	// CopulaStudentT joins the events through a multivariate t distribution. Unlike the
+	// Gaussian copula it has tail dependence: extreme outcomes tend to happen together, the
+	// more so the fewer the degrees of freedom.
+	CopulaStudentT
+)
+
+func (c Copula) String() string {
+	switch c {
+	case CopulaGaussian:
+		return "gaussian"
+	case CopulaStudentT:
+		return "student-t"
+	default:
+		return "unknown copula"
+	}
+}
+
+// CorrelationTarget selects what a Correlation correlates.
+type CorrelationTarget int
+
+const (
+	// CorrelateOccurrence correlates whether, or how often, the events occur.
+	CorrelateOccurrence CorrelationTarget = iota
+	// CorrelateImpact correlates the impacts of the events' first occurrence in a trial.
+	CorrelateImpact
+	// CorrelateOccurrenceAndImpact correlates both, each with its own draw from the copula.
+	CorrelateOccurrenceAndImpact
+)
+
+func (t CorrelationTarget) String() string {
+	switch t {
+	case CorrelateOccurrence:
+		return "occurrence"
+	case CorrelateImpact:
+		return "impact"
+	case CorrelateOccurrenceAndImpact:
+		return "occurrence and impact"
+	default:
+		return "unknown correlation target"
+	}
+}
+
+// defaultDegreesOfFreedom are the degrees of freedom of a CopulaStudentT without any.
+const defaultDegreesOfFreedom = 4
+
+// Correlation correlates events that aren't causally linked but share drivers, such as several
+// vendors exposed to the same zero-day. Each trial draws one value per event from the copula
+// and uses it in place of the uniform random number the event would otherwise draw.
+type Correlation struct {
+	Events           []string    // The correlated events, in the order of the rows of Matrix
+	Matrix           [][]float64 // Symmetric with ones on the diagonal and positive semi-definite
+	Copula           Copula
+	DegreesOfFreedom float64 // Degrees of freedom of CopulaStudentT, 4 when zero
+	Target           CorrelationTarget
+	Repair           bool // Replace a Matrix that isn't positive semi-definite with the nearest one that is
+}
+
+// correlation is a Correlation resolved for a run.
+type correlation struct {
+	rows             map[string]int
+	factor           [][]float64
+	copula           Copula
+	degreesOfFreedom float64
+	target           CorrelationTarget
+}
+
+// resolveCorrelation checks a correlation against the events and factors its matrix. It
+// reports whether the matrix had to be repaired.
+func resolveCorrelation(c Correlation, events []Event) (*correlation, bool, error) {
+	if len(c.Events) < 2 {
+		return nil, false, errorf(ErrInvalidCorrelation, "correlation needs at least two events, got %d", len(c.Events))
+	}
+	known := make(map[string]Event, len(events))
+	for _, event := range events {
+		known[event.Name] = event
+	}
+	resolved := &correlation{rows: make(map[string]int, len(c.Events)), copula: c.Copula, degreesOfFreedom: c.DegreesOfFreedom, target: c.Target}
+	for i, name := range c.Events {
+		event, exists := known[name]
+		switch _, duplicate := resolved.rows[name]; {
+		case !exists:
+			return nil, false, errorf(ErrUnknownEvent, "correlation of unknown event %q", name)
+		case duplicate:
+			return nil, false, errorf(ErrInvalidCorrelation, "event %q is correlated twice", name)
+		}
+		if c.Target != CorrelateOccurrence && event.ImpactDistribution != nil {
+			if _, ok := event.ImpactDistribution.(Quantiler); !ok {
+				return nil, false, errorf(ErrInvalidCorrelation, "the impact distribution of %q has no Quantile method, so its impacts can't be correlated", name)
+			}
+		}
+		resolved.rows[name] = i
+	}
+
+	switch {
+	case c.Copula != CopulaGaussian && c.Copula != CopulaStudentT:
+		return nil, false, errorf(ErrInvalidCorrelation, "unknown copula %d", c.Copula)
+	case c.Target < CorrelateOccurrence || c.Target > CorrelateOccurrenceAndImpact:
+		return nil, false, errorf(ErrInvalidCorrelation, "unknown correlation target %d", c.Target)
+	case c.DegreesOfFreedom < 0 || math.IsNaN(c.DegreesOfFreedom):
+		return nil, false, errorf(ErrInvalidCorrelation, "degrees of freedom %g must not be negative", c.DegreesOfFreedom)
+	}
+	if resolved.degreesOfFreedom == 0 {
+		resolved.degreesOfFreedom = defaultDegreesOfFreedom
+	}
+
+	n := len(c.Events)
+	if len(c.Matrix) != n {
+		return nil, false, errorf(ErrInvalidCorrelation, "correlation matrix has %d rows for %d events", len(c.Matrix), n)
+	}
+	for i, row := range c.Matrix {
+		if len(row) != n {
+			return nil, false, errorf(ErrInvalidCorrelation, "row %d of the correlation matrix has %d columns for %d events", i+1, len(row), n)
+		}
+		for j, value := range row {
+			switch {
+			case i == j && value != 1:
+				return nil, false, errorf(ErrInvalidCorrelation, "correlation of %q with itself is %g, expected 1", c.Events[i], value)
+			case !(value >= -1 && value <= 1):
+				return nil, false, errorf(ErrInvalidCorrelation, "correlation %g of %q and %q is not between -1 and 1", value, c.Events[i], c.Events[j])
+			case j < i && math.Abs(value-c.Matrix[j][i]) > 1e-12:
+				return nil, false, errorf(ErrInvalidCorrelation, "correlation matrix isn't symmetric: %q and %q are correlated by %g and %g", c.Events[j], c.Events[i], c.Matrix[j][i], value)
+			}
+		}
+	}
+
+	factor, ok := correlationFactor(c.Matrix)
+	if ok {
+		resolved.factor = factor
+		return resolved, false, nil
+	}
+	if !c.Repair {
+		return nil, false, errorf(ErrInvalidCorrelation, "correlation matrix is not positive semi-definite; set Repair to use the nearest matrix that is")
+	}
+	if resolved.factor, ok = correlationFactor(NearestCorrelationMatrix(c.Matrix)); !ok {
+		return nil, false, errorf(ErrInvalidCorrelation, "correlation matrix could not be repaired")
+	}
+	return resolved, true, nil
+}
+
+// eventRows returns the row of every event in the correlation, or -1 for uncorrelated events.
+func (c *correlation) eventRows(events []Event) []int {
+	rows := make([]int, len(events))
+	for i, event := range events {
+		rows[i] = -1
+		if row, exists := c.rows[event.Name]; exists {
+			rows[i] = row
+		}
+	}
+	return rows
+}
+
+// sample draws one value in (0, 1) per row from the copula into uniforms, using normals as
+// scratch space.
+func (c *correlation) sample(uniforms, normals []float64, localRand *rand.Rand) {
+	for i := range normals {
+		normals[i] = localRand.NormFloat64()
+	}
+	scale := 1.0
+	if c.copula == CopulaStudentT {
+		// a chi-squared draw with the degrees of freedom is twice a gamma draw with half of them
+		scale = math.Sqrt(2 * sampleGamma(c.degreesOfFreedom/2, localRand) / c.degreesOfFreedom)
+	}
+	for i, row := range c.factor {
+		x := 0.0
+		for j, weight := range row {
+			x += weight * normals[j]
+		}
+		var u float64
+		if c.copula == CopulaStudentT {
+			u = distuv.StudentsT{Mu: 0, Sigma: 1, Nu: c.degreesOfFreedom}.CDF(x / scale)
+		} else {
+			u = distuv.UnitNormal.CDF(x)
+		}
+		// keep quantiles of unbounded distributions finite
+		uniforms[i] = math.Min(math.Max(u, 1e-15), 1-1e-15)
+	}
+}
+
+// correlatedTrial holds the values one trial drew from the copula for each event.
+type correlatedTrial struct {
+	correlation *correlation
+	rows        []int
+	occurrence  []float64
+	impact      []float64
+	normals     []float64
+}
+
+// newCorrelatedTrial prepares the draws from the run's copula for the events, or returns nil
+// when the run isn't correlated.
+func newCorrelatedTrial(events []Event, run *runState) *correlatedTrial {
+	if run.correlation == nil {
+		return nil
+	}
+	n := len(run.correlation.factor)
+	return &correlatedTrial{correlation: run.correlation, rows: run.correlation.eventRows(events), occurrence: make([]float64, n), impact: make([]float64, n), normals: make([]float64, n)}
+}
+
+// draw draws the values of the next trial.
+func (t *correlatedTrial) draw(localRand *rand.Rand) {
+	if t == nil {
+		return
+	}
+	if t.correlation.target != CorrelateImpact {
+		t.correlation.sample(t.occurrence, t.normals, localRand)
+	}
+	if t.correlation.target != CorrelateOccurrence {
+		t.correlation.sample(t.impact, t.normals, localRand)
+	}
+}
+
+// occurrenceQuantile returns the value the event at the given index drew for its occurrence,
+// or nil if its occurrence isn't correlated.
+func (t *correlatedTrial) occurrenceQuantile(event int) *float64 {
+	if t == nil || t.rows[event] < 0 || t.correlation.target == CorrelateImpact {
+		return nil
+	}
+	return &t.occurrence[t.rows[event]]
+}
+
+// impactQuantile returns the value the event at the given index drew for its impact, or nil if
+// its impact isn't correlated.
+func (t *correlatedTrial) impactQuantile(event int) *float64 {
+	if t == nil || t.rows[event] < 0 || t.correlation.target == CorrelateOccurrence {
+		return nil
+	}
+	return &t.impact[t.rows[event]]
+}
+
+// psdTolerance is how far below zero an eigenvalue of a positive semi-definite matrix may be
+// from rounding.
+const psdTolerance = 1e-10
+
+// correlationFactor returns a matrix A with A Aᵀ = matrix, and whether the matrix is positive
+// semi-definite. It is the Cholesky factor of positive definite matrices; singular ones, such as
+// those of events correlated by 1, have none and are factored by their eigendecomposition.
+func correlationFactor(matrix [][]float64) ([][]float64, bool) {
+	n := len(matrix)
+	symmetric := symDense(matrix)
+	factor := make([][]float64, n)
+	for i := range factor {
+		factor[i] = make([]float64, n)
+	}
+
+	var cholesky mat.Cholesky
+	if cholesky.Factorize(symmetric) {
+		var lower mat.TriDense
+		cholesky.LTo(&lower)
+		for i := range factor {
+			for j := 0; j <= i; j++ {
+				factor[i][j] = lower.At(i, j)
+			}
+		}
+		return factor, true
+	}
+
+	values, vectors, ok := eigen(symmetric)
+	if !ok || values[0] < -psdTolerance {
+		return nil, false
+	}
+	for i := range factor {
+		for k, value := range values {
+			factor[i][k] = vectors.At(i, k) * math.Sqrt(math.Max(value, 0))
+		}
+	}
+	return factor, true
+}
+
+// IsPositiveSemiDefinite reports whether a symmetric matrix is positive semi-definite, as every
+// correlation matrix must be.
+func IsPositiveSemiDefinite(matrix [][]float64) bool {
+	values, _, ok := eigen(symDense(matrix))
+	return ok && (len(values) == 0 || values[0] >= -psdTolerance)
+}
+
+// NearestCorrelationMatrix returns the correlation matrix nearest to a symmetric matrix in the
+// Frobenius norm: positive semi-definite with ones on the diagonal. It uses Higham's
+// alternating projections with Dykstra's correction.
+func NearestCorrelationMatrix(matrix [][]float64) [][]float64 {
+	const tolerance, maxIterations = 1e-12, 1000
+	n := len(matrix)
+	y := copyMatrix(matrix)
+	correction := make([][]float64, n)
+	for i := range correction {
+		correction[i] = make([]float64, n)
+	}
+
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		r := copyMatrix(y)
+		for i := range r {
+			for j := range r[i] {
+				r[i][j] -= correction[i][j]
+			}
+		}
+		x := nearestPositiveSemiDefinite(r)
+		change := 0.0
+		for i := range x {
+			for j := range x[i] {
+				correction[i][j] = x[i][j] - r[i][j]
+				next := x[i][j]
+				if i == j {
+					next = 1
+				}
+				change += (next - y[i][j]) * (next - y[i][j])
+				y[i][j] = next
+			}
+		}
+		if math.Sqrt(change) < tolerance {
+			break
+		}
+	}
+
+	// the projection onto the positive semi-definite matrices can leave tiny negative
+	// eigenvalues behind, so clip once more and rescale to a unit diagonal
+	y = nearestPositiveSemiDefinite(y)
+	for i := range y {
+		for j := range y[i] {
+			if i != j {
+				y[i][j] /= math.Sqrt(y[i][i] * y[j][j])
+			}
+		}
+	}
+	for i := range y {
+		y[i][i] = 1
+	}
+	return y
+}
+
+// nearestPositiveSemiDefinite projects a symmetric matrix onto the positive semi-definite
+// matrices by clipping its negative eigenvalues to zero.
+func nearestPositiveSemiDefinite(matrix [][]float64) [][]float64 {
+	n := len(matrix)
+	projected := mat.NewSymDense(n, nil)
+	values, vectors, ok := eigen(symDense(matrix))
+	if ok {
+		for k, value := range values {
+			if value > 0 {
+				projected.SymRankOne(projected, value, vectors.ColView(k))
+			}
+		}
+	}
+
+	rows := make([][]float64, n)
+	for i := range rows {
+		rows[i] = make([]float64, n)
+		for j := range rows[i] {
+			rows[i][j] = projected.At(i, j)
+		}
+	}
+	return rows
+}
+
+// eigen returns the eigenvalues of a symmetric matrix in ascending order, with its
+// eigenvectors as the columns of a matrix.
+func eigen(matrix *mat.SymDense) ([]float64, *mat.Dense, bool) {
+	if matrix.SymmetricDim() == 0 {
+		return nil, nil, true
+	}
+	var decomposition mat.EigenSym
+	if !decomposition.Factorize(matrix, true) {
+		return nil, nil, false
+	}
+	var vectors mat.Dense
+	decomposition.VectorsTo(&vectors)
+	return decomposition.Values(nil), &vectors, true
+}
+
+// symDense copies the lower triangle of a square matrix into a symmetric one.
+func symDense(matrix [][]float64) *mat.SymDense {
+	symmetric := mat.NewSymDense(len(matrix), nil)
+	for i, row := range matrix {
+		for j := 0; j <= i; j++ {
+			symmetric.SetSym(i, j, row[j])
+		}
+	}
+	return symmetric
+}
+
+func copyMatrix(matrix [][]float64) [][]float64 {
+	copied := make([][]float64, len(matrix))
+	for i, row := range matrix {
+		copied[i] = append([]float64(nil), row...)
+	}
+	return copied
+}
@@ -0,0 +1,241 @@
+package montecargo
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// WriteDOT writes the dependency graph of the model in Graphviz DOT. Edges point from an event
+// to the events that depend on it; "not happens" dependencies are dashed and cost saving events
+// are drawn as ellipses. Requires expressions are drawn as diamond gates, with dashed edges into
+// a not.
+func (m *Model) WriteDOT(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph montecargo {")
+	fmt.Fprintln(out, "  rankdir=LR;")
+	fmt.Fprintln(out, "  node [shape=box, style=rounded];")
+	for _, event := range m.Events {
+		if event.IsCostSaving {
+			fmt.Fprintf(out, "  %s [shape=ellipse];\n", dotQuote(event.Name))
+		} else {
+			fmt.Fprintf(out, "  %s;\n", dotQuote(event.Name))
+		}
+	}
+	gates := 0
+	edge := func(from, to, label string, dashed bool) {
+		var attributes []string
+		if label != "" {
+			attributes = append(attributes, "label="+dotQuote(label))
+		}
+		if dashed {
+			attributes = append(attributes, "style=dashed")
+		}
+		if len(attributes) > 0 {
+			fmt.Fprintf(out, "  %s -> %s [%s];\n", from, to, strings.Join(attributes, ", "))
+		} else {
+			fmt.Fprintf(out, "  %s -> %s;\n", from, to)
+		}
+	}
+	m.eachDependency(func(child string, dep Dependency) {
+		if dep.Requires != nil {
+			gate := func(label string) string {
+				id := dotQuote(fmt.Sprintf("_gate%d", gates))
+				gates++
+				fmt.Fprintf(out, "  %s [label=%s, shape=diamond, style=solid];\n", id, dotQuote(label))
+				return id
+			}
+			drawRequires(child, *dep.Requires, dotQuote, gate, edge)
+			return
+		}
+		edge(dotQuote(dep.EventName), dotQuote(child), dependencyLabel(dep), dep.Condition == "not happens")
+	})
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+// dependencyLabel describes a dependency for the edge labels of the diagrams.
+func dependencyLabel(dep Dependency) string {
+	switch {
+	case dep.Condition != "":
+		return dep.Condition
+	case dep.IfHappens != nil && dep.IfNotHappens != nil:
+		return fmt.Sprintf("%g if happens, %g if not", *dep.IfHappens, *dep.IfNotHappens)
+	default:
+		return "probability table"
+	}
+}
+
+// drawRequires draws the Requires expression of a dependency as a tree of gates ending at the
+// child. event returns the node of an event, gate adds a gate node with the label and returns
+// it, and edge draws an edge between nodes, dashed into a not.
+func drawRequires(child string, requires Expression, event func(name string) string, gate func(label string) string, edge func(from, to, label string, dashed bool)) {
+	var draw func(e Expression) string
+	draw = func(e Expression) string {
+		if e.Operator == ExpressionEvent {
+			return event(e.Event)
+		}
+		label := strings.ToUpper(e.Operator.String())
+		if e.Operator == ExpressionAtLeast {
+			label = fmt.Sprintf("%d of %d", e.K, len(e.Operands))
+		}
+		node := gate(label)
+		for _, operand := range e.Operands {
+			edge(draw(operand), node, "", e.Operator == ExpressionNot)
+		}
+		return node
+	}
+	edge(draw(requires), event(child), "requires", false)
+}
+
+// WriteMermaid writes the dependency graph of the model as a Mermaid flowchart, with the same
+// conventions as WriteDOT: cost saving events are rounded, "not happens" edges dotted and gates
+// rhombuses.
+func (m *Model) WriteMermaid(w io.Writer) error {
+	ids := make(map[string]string, len(m.Events))
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "flowchart LR")
+	for i, event := range m.Events {
+		ids[event.Name] = fmt.Sprintf("e%d", i)
+		if event.IsCostSaving {
+			fmt.Fprintf(out, "  %s([%s])\n", ids[event.Name], mermaidQuote(event.Name))
+		} else {
+			fmt.Fprintf(out, "  %s[%s]\n", ids[event.Name], mermaidQuote(event.Name))
+		}
+	}
+	gates := 0
+	event := func(name string) string { return ids[name] }
+	edge := func(from, to, label string, dashed bool) {
+		arrow := "-->"
+		if dashed {
+			arrow = "-.->"
+		}
+		if label != "" {
+			arrow += "|" + mermaidQuote(label) + "|"
+		}
+		fmt.Fprintf(out, "  %s %s %s\n", from, arrow, to)
+	}
+	m.eachDependency(func(child string, dep Dependency) {
+		if dep.Requires != nil {
+			gate := func(label string) string {
+				id := fmt.Sprintf("g%d", gates)
+				gates++
+				fmt.Fprintf(out, "  %s{%s}\n", id, mermaidQuote(label))
+				return id
+			}
+			drawRequires(child, *dep.Requires, event, gate, edge)
+			return
+		}
+		edge(ids[dep.EventName], ids[child], dependencyLabel(dep), dep.Condition == "not happens")
+	})
+	return out.Flush()
+}
+
+// WriteSVG draws the dependency graph of the model as an SVG diagram with one column per
+// dependency level, the same conventions as WriteDOT and a legend. The SVG has no XML prolog, so
+// it can be inlined in HTML.
+func (m *Model) WriteSVG(w io.Writer) error {
+	const (
+		boxWidth, boxHeight = 220.0, 40.0
+		columnGap, rowGap   = 80.0, 20.0
+		margin, legend      = 20.0, 30.0
+		maxLabel            = 30
+	)
+
+	type box struct{ x, y float64 }
+	boxes := make(map[string]box, len(m.Events))
+	levels := dependencyLevels(m.Events, m.Dependencies)
+	rows := 0
+	for column, level := range levels {
+		for row, event := range level {
+			boxes[event.Name] = box{margin + float64(column)*(boxWidth+columnGap), margin + legend + float64(row)*(boxHeight+rowGap)}
+		}
+		if len(level) > rows {
+			rows = len(level)
+		}
+	}
+	width := 2*margin + float64(len(levels))*boxWidth + math.Max(float64(len(levels)-1), 0)*columnGap
+	height := 2*margin + legend + float64(rows)*boxHeight + math.Max(float64(rows-1), 0)*rowGap
+	if width < 400 {
+		width = 400
+	}
+
+	svg := &svgWriter{w: w}
+	svg.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="sans-serif" font-size="12">`+"\n", width, height, width, height)
+	svg.printf(`<defs><marker id="montecargo-arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="#555"/></marker></defs>` + "\n")
+	svg.printf(`<rect width="%g" height="%g" fill="white"/>`+"\n", width, height)
+	svg.printf(`<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="#555" stroke-width="1.5"/><text x="%g" y="%g">happens</text>`+"\n", margin, margin+4, margin+30, margin+4, margin+36, margin+8)
+	svg.printf(`<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="#555" stroke-width="1.5" stroke-dasharray="5 4"/><text x="%g" y="%g">not happens</text>`+"\n", margin+110, margin+4, margin+140, margin+4, margin+146, margin+8)
+	svg.printf(`<rect x="%g" y="%g" width="24" height="12" rx="6" fill="#e3f4e1" stroke="#3c8d40"/><text x="%g" y="%g">control</text>`+"\n", margin+240, margin-2, margin+270, margin+8)
+
+	edge := func(parent, child string, dashed bool, title string) {
+		from, okFrom := boxes[parent]
+		to, okTo := boxes[child]
+		if !okFrom || !okTo {
+			return
+		}
+		x1, y1 := from.x+boxWidth, from.y+boxHeight/2
+		x2, y2 := to.x, to.y+boxHeight/2
+		dash := ""
+		if dashed {
+			dash = ` stroke-dasharray="5 4"`
+		}
+		svg.printf(`<path d="M%g,%g C%g,%g %g,%g %g,%g" fill="none" stroke="#555" stroke-width="1.5"%s marker-end="url(#montecargo-arrow)"><title>%s</title></path>`+"\n",
+			x1, y1, x1+columnGap/2, y1, x2-columnGap/2, y2, x2, y2, dash, html.EscapeString(title))
+	}
+	m.eachDependency(func(child string, dep Dependency) {
+		switch {
+		case dep.Requires != nil:
+			// the boxes are laid out by level, so expressions are only named in the titles
+			for _, parent := range dep.events() {
+				edge(parent, child, false, fmt.Sprintf("%s requires %s", child, dep.Requires))
+			}
+		case dep.Condition != "":
+			edge(dep.EventName, child, dep.Condition == "not happens", fmt.Sprintf("%s if %s %s", child, dep.EventName, dep.Condition))
+		default:
+			edge(dep.EventName, child, false, fmt.Sprintf("%s given %s: %s", child, dep.EventName, dependencyLabel(dep)))
+		}
+	})
+
+	for _, event := range m.Events {
+		b, ok := boxes[event.Name]
+		if !ok {
+			continue
+		}
+		fill, stroke, radius := "#fde8e7", "#c0392b", 4.0
+		if event.IsCostSaving {
+			fill, stroke, radius = "#e3f4e1", "#3c8d40", boxHeight/2
+		}
+		label := event.Name
+		if utf8.RuneCountInString(label) > maxLabel {
+			label = string([]rune(label)[:maxLabel-1]) + "…"
+		}
+		svg.printf(`<g><title>%s</title><rect x="%g" y="%g" width="%g" height="%g" rx="%g" fill="%s" stroke="%s"/><text x="%g" y="%g" text-anchor="middle">%s</text></g>`+"\n",
+			html.EscapeString(event.Name), b.x, b.y, boxWidth, boxHeight, radius, fill, stroke, b.x+boxWidth/2, b.y+boxHeight/2+4, html.EscapeString(label))
+	}
+
+	svg.printf("</svg>\n")
+	return svg.err
+}
+
+// eachDependency calls fn for every dependency of the model, in the order of its events.
+func (m *Model) eachDependency(fn func(child string, dep Dependency)) {
+	for _, event := range m.Events {
+		for _, dep := range m.Dependencies[event.Name] {
+			dep.Condition = dependencyCondition(event, dep)
+			fn(event.Name, dep)
+		}
+	}
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
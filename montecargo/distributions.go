@@ -0,0 +1,272 @@
+package montecargo
+
+import (
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// z90 is the z-score of the 95th percentile, the upper bound of a 90% confidence interval.
+const z90 = 1.6448536269514722
+
+// Distribution is a probability distribution that impacts can be drawn from.
+type Distribution interface {
+	// Sample draws a value using the given random number generator.
+	Sample(localRand *rand.Rand) float64
+	// Mean returns the expected value of the distribution.
+	Mean() float64
+}
+
+// Quantiler is implemented by distributions whose quantile function is known. Correlated
+// impacts (see Correlation) can only be drawn from distributions that implement it; every
+// distribution in this package does.
+type Quantiler interface {
+	// Quantile returns the value below which a fraction p, in (0, 1), of the distribution lies.
+	Quantile(p float64) float64
+}
+
+// Uniform is a uniform distribution between Min and Max.
+type Uniform struct {
+	Min float64
+	Max float64
+}
+
+func (d Uniform) Sample(localRand *rand.Rand) float64 {
+	return d.Min + (d.Max-d.Min)*localRand.Float64()
+}
+
+func (d Uniform) Mean() float64 {
+	return (d.Min + d.Max) / 2
+}
+
+func (d Uniform) Quantile(p float64) float64 {
+	return d.Min + (d.Max-d.Min)*p
+}
+
+// Triangular is a triangular distribution between Min and Max that peaks at Mode.
+type Triangular struct {
+	Min  float64
+	Mode float64
+	Max  float64
+}
+
+func (d Triangular) Sample(localRand *rand.Rand) float64 {
+	width := d.Max - d.Min
+	if width <= 0 {
+		return d.Min
+	}
+
+	u := localRand.Float64()
+	split := (d.Mode - d.Min) / width
+	if u < split {
+		return d.Min + math.Sqrt(u*width*(d.Mode-d.Min))
+	}
+	return d.Max - math.Sqrt((1-u)*width*(d.Max-d.Mode))
+}
+
+func (d Triangular) Mean() float64 {
+	return (d.Min + d.Mode + d.Max) / 3
+}
+
+func (d Triangular) Quantile(p float64) float64 {
+	width := d.Max - d.Min
+	if width <= 0 {
+		return d.Min
+	}
+	if p < (d.Mode-d.Min)/width {
+		return d.Min + math.Sqrt(p*width*(d.Mode-d.Min))
+	}
+	return d.Max - math.Sqrt((1-p)*width*(d.Max-d.Mode))
+}
+
+// PERT is a beta-PERT distribution between Min and Max with Mode as the most likely value.
+// Compared to Triangular it puts less weight on the tails.
+type PERT struct {
+	Min  float64
+	Mode float64
+	Max  float64
+}
+
+func (d PERT) shape() (alpha, beta float64) {
+	width := d.Max - d.Min
+	alpha = 1 + 4*(d.Mode-d.Min)/width
+	beta = 1 + 4*(d.Max-d.Mode)/width
+	return alpha, beta
+}
+
+func (d PERT) Sample(localRand *rand.Rand) float64 {
+	if d.Max-d.Min <= 0 {
+		return d.Min
+	}
+
+	alpha, beta := d.shape()
+	x := sampleGamma(alpha, localRand)
+	y := sampleGamma(beta, localRand)
+	return d.Min + (d.Max-d.Min)*x/(x+y)
+}
+
+func (d PERT) Mean() float64 {
+	return (d.Min + 4*d.Mode + d.Max) / 6
+}
+
+func (d PERT) Quantile(p float64) float64 {
+	if d.Max-d.Min <= 0 {
+		return d.Min
+	}
+	alpha, beta := d.shape()
+	return d.Min + (d.Max-d.Min)*distuv.Beta{Alpha: alpha, Beta: beta}.Quantile(p)
+}
+
+// LogNormal is a lognormal distribution: the logarithm of its values is normally distributed
+// with mean Mu and standard deviation Sigma.
+type LogNormal struct {
+	Mu    float64
+	Sigma float64
+}
+
+// LogNormalFrom90CI fits a lognormal distribution so that lower and upper are its 5th and 95th
+// percentiles. This is the usual way to turn a "90% sure the loss is between X and Y" estimate
+// into a heavy-tailed loss distribution. lower must be positive and below upper; Validate
+// rejects the distribution fitted to any other interval.
+func LogNormalFrom90CI(lower, upper float64) LogNormal {
+	logLower, logUpper := math.Log(lower), math.Log(upper)
+	return LogNormal{
+		Mu:    (logLower + logUpper) / 2,
+		Sigma: (logUpper - logLower) / (2 * z90),
+	}
+}
+
+func (d LogNormal) Sample(localRand *rand.Rand) float64 {
+	return math.Exp(d.Mu + d.Sigma*localRand.NormFloat64())
+}
+
+func (d LogNormal) Mean() float64 {
+	return math.Exp(d.Mu + d.Sigma*d.Sigma/2)
+}
+
+func (d LogNormal) Quantile(p float64) float64 {
+	return math.Exp(d.Mu + d.Sigma*distuv.UnitNormal.Quantile(p))
+}
+
+// Normal is a normal distribution with mean Mu and standard deviation Sigma.
+type Normal struct {
+	Mu    float64
+	Sigma float64
+}
+
+// NormalFrom90CI fits a normal distribution so that lower and upper are its 5th and 95th
+// percentiles.
+func NormalFrom90CI(lower, upper float64) Normal {
+	return Normal{
+		Mu:    (lower + upper) / 2,
+		Sigma: (upper - lower) / (2 * z90),
+	}
+}
+
+func (d Normal) Sample(localRand *rand.Rand) float64 {
+	return d.Mu + d.Sigma*localRand.NormFloat64()
+}
+
+func (d Normal) Mean() float64 {
+	return d.Mu
+}
+
+func (d Normal) Quantile(p float64) float64 {
+	return d.Mu + d.Sigma*distuv.UnitNormal.Quantile(p)
+}
+
+// Pareto is a Pareto (power law) distribution with minimum value Scale and tail index Shape.
+// The lower the shape, the heavier the tail; for Shape <= 1 the mean is infinite.
+type Pareto struct {
+	Scale float64
+	Shape float64
+}
+
+// ParetoFrom90CI fits a Pareto distribution that starts at lower and has upper as its 95th
+// percentile. lower must be positive and below upper; Validate rejects the distribution fitted
+// to any other interval. When upper is more than 20 times lower, as it often is for cyber
+// losses, the fitted Shape is below 1 and the mean is infinite, so expected losses are
+// dominated by the few largest trials and don't converge.
+func ParetoFrom90CI(lower, upper float64) Pareto {
+	return Pareto{
+		Scale: lower,
+		Shape: math.Log(20) / math.Log(upper/lower),
+	}
+}
+
+func (d Pareto) Sample(localRand *rand.Rand) float64 {
+	// 1 - Float64() is in (0, 1], which keeps the sample finite
+	return d.Scale * math.Pow(1-localRand.Float64(), -1/d.Shape)
+}
+
+func (d Pareto) Mean() float64 {
+	if d.Shape <= 1 {
+		return math.Inf(1)
+	}
+	return d.Shape * d.Scale / (d.Shape - 1)
+}
+
+func (d Pareto) Quantile(p float64) float64 {
+	return d.Scale * math.Pow(1-p, -1/d.Shape)
+}
+
+// Gamma is a gamma distribution with the given Shape and Scale.
+type Gamma struct {
+	Shape float64
+	Scale float64
+}
+
+// GammaFrom90CI fits a gamma distribution by matching the mean and standard deviation of a
+// normal distribution whose 90% confidence interval is [lower, upper].
+func GammaFrom90CI(lower, upper float64) Gamma {
+	mean := (lower + upper) / 2
+	stdDev := (upper - lower) / (2 * z90)
+	variance := stdDev * stdDev
+	return Gamma{
+		Shape: mean * mean / variance,
+		Scale: variance / mean,
+	}
+}
+
+func (d Gamma) Sample(localRand *rand.Rand) float64 {
+	return d.Scale * sampleGamma(d.Shape, localRand)
+}
+
+func (d Gamma) Mean() float64 {
+	return d.Shape * d.Scale
+}
+
+func (d Gamma) Quantile(p float64) float64 {
+	if d.Shape <= 0 {
+		return 0
+	}
+	return d.Scale * distuv.Gamma{Alpha: d.Shape, Beta: 1}.Quantile(p)
+}
+
+// sampleGamma draws from a gamma distribution with unit scale using the Marsaglia-Tsang method.
+func sampleGamma(shape float64, localRand *rand.Rand) float64 {
+	if shape <= 0 {
+		return 0
+	}
+	if shape < 1 {
+		// boost the shape above 1 and correct with a power of a uniform draw
+		u := 1 - localRand.Float64()
+		return sampleGamma(shape+1, localRand) * math.Pow(u, 1/shape)
+	}
+
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := localRand.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := 1 - localRand.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
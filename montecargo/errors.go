@@ -0,0 +1,50 @@
+package montecargo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors, matched with errors.Is by the errors this package returns, including the
+// Diagnostics of Validate.
+var (
+	// ErrUnknownEvent is an event name that isn't among the events or the results.
+	ErrUnknownEvent = errors.New("unknown event")
+	// ErrDuplicateEvent is an event name used by more than one event.
+	ErrDuplicateEvent = errors.New("duplicate event")
+	// ErrInvalidProbability is a probability outside [0, 1] or a LowerProb above its UpperProb.
+	ErrInvalidProbability = errors.New("invalid probability")
+	// ErrInvalidImpact is a negative impact or an impact range that is incomplete or reversed.
+	ErrInvalidImpact = errors.New("invalid impact")
+	// ErrInvalidDependency is a dependency on the event itself, a cycle or an unknown condition.
+	ErrInvalidDependency = errors.New("invalid dependency")
+	// ErrInvalidValue is any other event field out of range, such as a negative timeframe.
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrInvalidCorrelation is a Correlation whose matrix doesn't fit its events or isn't a
+	// correlation matrix.
+	ErrInvalidCorrelation = errors.New("invalid correlation")
+	// ErrInvalidTrials is a number of trials below one.
+	ErrInvalidTrials = errors.New("invalid number of trials")
+	// ErrNoCoOccurrences is a result without co-occurrences, which only the joint strategy
+	// records.
+	ErrNoCoOccurrences = errors.New("no co-occurrences")
+)
+
+// sentinelError has its own message and matches a sentinel error with errors.Is.
+type sentinelError struct {
+	sentinel error
+	message  string
+}
+
+func (e *sentinelError) Error() string {
+	return e.message
+}
+
+func (e *sentinelError) Unwrap() error {
+	return e.sentinel
+}
+
+// errorf returns an error with the formatted message that matches sentinel.
+func errorf(sentinel error, format string, args ...interface{}) error {
+	return &sentinelError{sentinel: sentinel, message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,309 @@
+package montecargo
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Version is the version of montecargo recorded in exports. Release builds set it with
+// -ldflags "-X github.com/bcdannyboy/montecargo/montecargo.Version=v1.2.3".
+var Version = "dev"
+
+// ResultSchema identifies the layout of ResultExport. Fields may be added within a version;
+// removing a field or changing its meaning bumps it.
+const ResultSchema = "montecargo.result/v1"
+
+// exportPercentiles are the percentiles of the total loss included in exports.
+var exportPercentiles = []float64{0.5, 0.75, 0.9, 0.95, 0.99, 0.995, 0.999}
+
+// ExportMetadata identifies the run an export was produced by. It heads every export.
+type ExportMetadata struct {
+	Tool      string    `json:"tool"`
+	Version   string    `json:"version"`
+	Seed      int64     `json:"seed"`
+	Trials    int       `json:"trials"`
+	Horizon   string    `json:"horizon"`   // ISO-8601 duration of a trial
+	InputHash string    `json:"inputHash"` // See InputHash
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewExportMetadata returns the metadata of a run of the given events, timestamped now.
+func NewExportMetadata(result SimulationResult, events []Event, dependencies map[string][]Dependency) (ExportMetadata, error) {
+	hash, err := InputHash(events, dependencies)
+	if err != nil {
+		return ExportMetadata{}, err
+	}
+	return ExportMetadata{
+		Tool:      "montecargo",
+		Version:   Version,
+		Seed:      result.Seed,
+		Trials:    result.Trials,
+		Horizon:   result.Horizon.ISO8601(),
+		InputHash: hash,
+		Timestamp: time.Now().UTC(),
+	}, nil
+}
+
+// InputHash returns the SHA-256 of the events and dependencies as "sha256:<hex>". It hashes
+// the model file layout, so equal inputs hash equally however they were written or loaded.
+func InputHash(events []Event, dependencies map[string][]Dependency) (string, error) {
+	fm, err := newModelFile(&Model{Events: events, Dependencies: dependencies})
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(fm)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// ResultExport is a SimulationResult in the stable layout of ResultSchema, documented in the
+// README. Amounts are in the units of the impacts and probabilities are fractions.
+type ResultExport struct {
+	Schema        string                    `json:"schema"`
+	Metadata      ExportMetadata            `json:"metadata"`
+	Incomplete    bool                      `json:"incomplete"`
+	Events        []EventExport             `json:"events"`
+	Loss          LossExport                `json:"loss"`
+	LossRange     LossRangeExport           `json:"lossRange"`
+	CoOccurrences map[string]map[string]int `json:"coOccurrences,omitempty"`
+	Warnings      []string                  `json:"warnings"`
+}
+
+// EventExport is the result of one event: its stats, loss stats and loss breakdown. It is flat
+// so that it maps to a CSV row.
+type EventExport struct {
+	Name               string  `json:"name"`
+	CostSaving         bool    `json:"costSaving"`
+	Trials             int     `json:"trials"`
+	OccurredTrials     int     `json:"occurredTrials"`
+	Occurrences        int     `json:"occurrences"`
+	Probability        float64 `json:"probability"`
+	ProbabilityStdDev  float64 `json:"probabilityStdDev"`
+	MeanFrequency      float64 `json:"meanFrequency"`
+	SeverityMean       float64 `json:"severityMean"`
+	SeverityStdDev     float64 `json:"severityStdDev"`
+	ConditionalMean    float64 `json:"conditionalMean"`
+	ConditionalStdDev  float64 `json:"conditionalStdDev"`
+	ExpectedLoss       float64 `json:"expectedLoss"`
+	ExpectedLossStdDev float64 `json:"expectedLossStdDev"`
+	MinCost            float64 `json:"minCostOfImplementation"`
+	MaxCost            float64 `json:"maxCostOfImplementation"`
+
+	// Loss breakdown of CalculateExpectedLossRange, zero for events without impact bounds
+	MinLoss              float64 `json:"minLoss"`
+	MaxLoss              float64 `json:"maxLoss"`
+	AvgLoss              float64 `json:"avgLoss"`
+	ProbabilityExceedMin float64 `json:"probabilityExceedMin"`
+	ProbabilityExceedMax float64 `json:"probabilityExceedMax"`
+	ProbabilityExceedAvg float64 `json:"probabilityExceedAvg"`
+}
+
+// LossExport describes the total loss per trial, as LossReport does.
+type LossExport struct {
+	Trials      int                `json:"trials"`
+	Mean        float64            `json:"mean"`
+	StdDev      float64            `json:"stdDev"`
+	Level       float64            `json:"level"`
+	VaR         float64            `json:"var"`
+	TVaR        float64            `json:"tvar"`
+	Max         float64            `json:"max"`
+	Percentiles []PercentileExport `json:"percentiles"`
+}
+
+// PercentileExport is the loss not exceeded in the given fraction of trials.
+type PercentileExport struct {
+	Percentile float64 `json:"percentile"`
+	Loss       float64 `json:"loss"`
+}
+
+// LossRangeExport holds the totals of CalculateExpectedLossRange.
+type LossRangeExport struct {
+	MinLoss              float64 `json:"minLoss"`
+	MaxLoss              float64 `json:"maxLoss"`
+	AvgLoss              float64 `json:"avgLoss"`
+	ProbabilityExceedMin float64 `json:"probabilityExceedMin"`
+	ProbabilityExceedMax float64 `json:"probabilityExceedMax"`
+}
+
+// NewResultExport arranges a simulation result in the export layout, with events in the
+// given order and value at risk measured at 99%.
+func NewResultExport(metadata ExportMetadata, result SimulationResult, events []Event) ResultExport {
+	export := ResultExport{
+		Schema:        ResultSchema,
+		Metadata:      metadata,
+		Incomplete:    result.Incomplete,
+		Events:        make([]EventExport, 0, len(events)),
+		CoOccurrences: result.CoOccurrences,
+		Warnings:      make([]string, 0, len(result.Warnings)),
+	}
+
+	minLoss, maxLoss, avgLoss, exceedMin, exceedMax, breakdown := CalculateExpectedLossRange(events, result.EventStats)
+	export.LossRange = LossRangeExport{MinLoss: minLoss, MaxLoss: maxLoss, AvgLoss: avgLoss, ProbabilityExceedMin: exceedMin, ProbabilityExceedMax: exceedMax}
+
+	for _, event := range events {
+		eventResult := result.EventResults[event.Name]
+		stat := result.EventStats[event.Name]
+		loss := breakdown[event.Name]
+		export.Events = append(export.Events, EventExport{
+			Name:                 event.Name,
+			CostSaving:           event.IsCostSaving,
+			Trials:               eventResult.Trials,
+			OccurredTrials:       eventResult.Sum,
+			Occurrences:          eventResult.Occurrences,
+			Probability:          stat.Probability,
+			ProbabilityStdDev:    stat.StdDev,
+			MeanFrequency:        stat.MeanFrequency,
+			SeverityMean:         stat.Loss.SeverityMean,
+			SeverityStdDev:       stat.Loss.SeverityStdDev,
+			ConditionalMean:      stat.Loss.ConditionalMean,
+			ConditionalStdDev:    stat.Loss.ConditionalStdDev,
+			ExpectedLoss:         stat.Loss.ExpectedLoss,
+			ExpectedLossStdDev:   stat.Loss.ExpectedLossStdDev,
+			MinCost:              stat.MinCostOfImplementation,
+			MaxCost:              stat.MaxCostOfImplementation,
+			MinLoss:              loss.MinLoss,
+			MaxLoss:              loss.MaxLoss,
+			AvgLoss:              loss.AvgLoss,
+			ProbabilityExceedMin: loss.ProbabilityExceedMin,
+			ProbabilityExceedMax: loss.ProbabilityExceedMax,
+			ProbabilityExceedAvg: loss.ProbabilityExceedAvg,
+		})
+	}
+
+	report := NewLossReport(result, defaultRiskLevel)
+	distribution := NewLossDistribution(result.TrialLosses)
+	export.Loss = LossExport{
+		Trials:      report.Trials,
+		Mean:        report.Mean,
+		StdDev:      report.StdDev,
+		Level:       report.Level,
+		VaR:         report.VaR,
+		TVaR:        report.TVaR,
+		Max:         report.Max,
+		Percentiles: make([]PercentileExport, len(exportPercentiles)),
+	}
+	for i, p := range exportPercentiles {
+		export.Loss.Percentiles[i] = PercentileExport{Percentile: p, Loss: distribution.Percentile(p)}
+	}
+
+	for _, warning := range result.Warnings {
+		export.Warnings = append(export.Warnings, warning.String())
+	}
+	return export
+}
+
+// WriteJSON writes the export as indented JSON.
+func (e ResultExport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e)
+}
+
+// eventColumns are the columns of WriteEventsCSV, named as the JSON fields of EventExport.
+var eventColumns = []string{
+	"name", "costSaving", "trials", "occurredTrials", "occurrences", "probability", "probabilityStdDev",
+	"meanFrequency", "severityMean", "severityStdDev", "conditionalMean", "conditionalStdDev",
+	"expectedLoss", "expectedLossStdDev", "minCostOfImplementation", "maxCostOfImplementation",
+	"minLoss", "maxLoss", "avgLoss", "probabilityExceedMin", "probabilityExceedMax", "probabilityExceedAvg",
+}
+
+// WriteEventsCSV writes one row per event under a metadata header of "#" comment lines.
+func (e ResultExport) WriteEventsCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := writeCSVMetadata(w, e.Metadata); err != nil {
+		return err
+	}
+	if err := out.Write(eventColumns); err != nil {
+		return err
+	}
+	for _, event := range e.Events {
+		values := []float64{
+			event.Probability, event.ProbabilityStdDev, event.MeanFrequency, event.SeverityMean,
+			event.SeverityStdDev, event.ConditionalMean, event.ConditionalStdDev, event.ExpectedLoss,
+			event.ExpectedLossStdDev, event.MinCost, event.MaxCost, event.MinLoss, event.MaxLoss,
+			event.AvgLoss, event.ProbabilityExceedMin, event.ProbabilityExceedMax, event.ProbabilityExceedAvg,
+		}
+		record := []string{event.Name, strconv.FormatBool(event.CostSaving), strconv.Itoa(event.Trials), strconv.Itoa(event.OccurredTrials), strconv.Itoa(event.Occurrences)}
+		for _, value := range values {
+			record = append(record, formatFloat(value))
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WritePercentilesCSV writes the percentiles of the total loss, followed by its mean, value at
+// risk, expected shortfall and maximum, under a metadata header of "#" comment lines.
+func (e ResultExport) WritePercentilesCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := writeCSVMetadata(w, e.Metadata); err != nil {
+		return err
+	}
+	rows := [][]string{{"measure", "percentile", "loss"}}
+	for _, p := range e.Loss.Percentiles {
+		rows = append(rows, []string{"percentile", formatFloat(p.Percentile), formatFloat(p.Loss)})
+	}
+	rows = append(rows,
+		[]string{"mean", "", formatFloat(e.Loss.Mean)},
+		[]string{"var", formatFloat(e.Loss.Level), formatFloat(e.Loss.VaR)},
+		[]string{"tvar", formatFloat(e.Loss.Level), formatFloat(e.Loss.TVaR)},
+		[]string{"max", "1", formatFloat(e.Loss.Max)},
+	)
+	if err := out.WriteAll(rows); err != nil {
+		return err
+	}
+	return out.Error()
+}
+
+// writeCSVMetadata writes the metadata as "# key: value" lines, which encoding/csv skips with
+// Comment set to '#'.
+func writeCSVMetadata(w io.Writer, metadata ExportMetadata) error {
+	_, err := fmt.Fprintf(w, "# schema: %s\n# tool: %s\n# version: %s\n# seed: %d\n# trials: %d\n# horizon: %s\n# inputHash: %s\n# timestamp: %s\n",
+		ResultSchema, metadata.Tool, metadata.Version, metadata.Seed, metadata.Trials, metadata.Horizon, metadata.InputHash, metadata.Timestamp.Format(time.RFC3339Nano))
+	return err
+}
+
+// WriteTrialsJSONL streams the trials of a result as JSON Lines. The first line is
+// {"metadata": ...}; every other line is {"trial": i, "loss": ..., "savings": ...} with the
+// total loss and total savings of trial i, numbered from 0.
+func WriteTrialsJSONL(w io.Writer, metadata ExportMetadata, result SimulationResult) error {
+	out := bufio.NewWriter(w)
+	header, err := json.Marshal(struct {
+		Metadata ExportMetadata `json:"metadata"`
+	}{metadata})
+	if err != nil {
+		return err
+	}
+	out.Write(header)
+	out.WriteByte('\n')
+
+	for i, loss := range result.TrialLosses {
+		savings := 0.0
+		if i < len(result.TrialSavings) {
+			savings = result.TrialSavings[i]
+		}
+		out.WriteString(`{"trial":`)
+		out.WriteString(strconv.Itoa(i))
+		out.WriteString(`,"loss":`)
+		out.WriteString(formatFloat(loss))
+		out.WriteString(`,"savings":`)
+		out.WriteString(formatFloat(savings))
+		if _, err := out.WriteString("}\n"); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,220 @@
+package montecargo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operator is the kind of an Expression.
+type Operator int
+
+const (
+	// ExpressionEvent holds when its Event happens.
+	ExpressionEvent Operator = iota
+	// ExpressionAnd holds when all of its operands hold.
+	ExpressionAnd
+	// ExpressionOr holds when any of its operands holds.
+	ExpressionOr
+	// ExpressionNot holds when its single operand doesn't.
+	ExpressionNot
+	// ExpressionAtLeast holds when at least K of its operands hold.
+	ExpressionAtLeast
+)
+
+func (o Operator) String() string {
+	switch o {
+	case ExpressionEvent:
+		return "event"
+	case ExpressionAnd:
+		return "and"
+	case ExpressionOr:
+		return "or"
+	case ExpressionNot:
+		return "not"
+	case ExpressionAtLeast:
+		return "at least"
+	default:
+		return "unknown operator"
+	}
+}
+
+// Expression is a boolean expression over the outcomes of events in a trial, built with
+// Happens, And, Or, Not and AtLeast and nested to any depth.
+type Expression struct {
+	Operator Operator
+	Event    string       // The event of an ExpressionEvent
+	K        int          // The number of operands that must hold for ExpressionAtLeast
+	Operands []Expression // The operands of the other operators
+}
+
+// Happens holds when the event happens.
+func Happens(eventName string) Expression {
+	return Expression{Operator: ExpressionEvent, Event: eventName}
+}
+
+// And holds when all of the operands hold.
+func And(operands ...Expression) Expression {
+	return Expression{Operator: ExpressionAnd, Operands: operands}
+}
+
+// Or holds when any of the operands holds.
+func Or(operands ...Expression) Expression {
+	return Expression{Operator: ExpressionOr, Operands: operands}
+}
+
+// Not holds when the operand doesn't.
+func Not(operand Expression) Expression {
+	return Expression{Operator: ExpressionNot, Operands: []Expression{operand}}
+}
+
+// AtLeast holds when at least k of the operands hold, such as 2 of 3 redundant sites failing.
+func AtLeast(k int, operands ...Expression) Expression {
+	return Expression{Operator: ExpressionAtLeast, K: k, Operands: operands}
+}
+
+// Events returns the names of the events the expression is over, in order of appearance.
+func (e Expression) Events() []string {
+	var names []string
+	var walk func(e Expression)
+	walk = func(e Expression) {
+		if e.Operator == ExpressionEvent && e.Event != "" {
+			names = appendUnique(names, e.Event)
+		}
+		for _, operand := range e.Operands {
+			walk(operand)
+		}
+	}
+	walk(e)
+	return names
+}
+
+// String returns the expression in words, such as "Data Breach and not Host Detection".
+func (e Expression) String() string {
+	operands := make([]string, len(e.Operands))
+	for i, operand := range e.Operands {
+		operands[i] = operand.String()
+		if operand.Operator != ExpressionEvent && operand.Operator != ExpressionNot {
+			operands[i] = "(" + operands[i] + ")"
+		}
+	}
+	switch e.Operator {
+	case ExpressionEvent:
+		return e.Event
+	case ExpressionAnd:
+		return strings.Join(operands, " and ")
+	case ExpressionOr:
+		return strings.Join(operands, " or ")
+	case ExpressionNot:
+		return "not " + strings.Join(operands, ", ")
+	case ExpressionAtLeast:
+		return fmt.Sprintf("at least %d of %s", e.K, strings.Join(operands, ", "))
+	default:
+		return e.Operator.String()
+	}
+}
+
+// evaluate reports whether the expression holds for the given outcomes.
+func (e Expression) evaluate(happens func(eventName string) bool) bool {
+	switch e.Operator {
+	case ExpressionEvent:
+		return happens(e.Event)
+	case ExpressionAnd:
+		for _, operand := range e.Operands {
+			if !operand.evaluate(happens) {
+				return false
+			}
+		}
+		return true
+	case ExpressionOr:
+		for _, operand := range e.Operands {
+			if operand.evaluate(happens) {
+				return true
+			}
+		}
+		return false
+	case ExpressionNot:
+		return !e.Operands[0].evaluate(happens)
+	case ExpressionAtLeast:
+		holding := 0
+		for _, operand := range e.Operands {
+			if operand.evaluate(happens) {
+				holding++
+			}
+		}
+		return holding >= e.K
+	default:
+		return false
+	}
+}
+
+// probability returns the probability that the expression holds when each event happens
+// independently with the given probability, and operands don't share events.
+func (e Expression) probability(probabilityOf func(eventName string) float64) float64 {
+	switch e.Operator {
+	case ExpressionEvent:
+		return probabilityOf(e.Event)
+	case ExpressionAnd:
+		all := 1.0
+		for _, operand := range e.Operands {
+			all *= operand.probability(probabilityOf)
+		}
+		return all
+	case ExpressionOr:
+		none := 1.0
+		for _, operand := range e.Operands {
+			none *= 1 - operand.probability(probabilityOf)
+		}
+		return 1 - none
+	case ExpressionNot:
+		return 1 - e.Operands[0].probability(probabilityOf)
+	case ExpressionAtLeast:
+		// holding[j] is the probability that j of the operands so far hold
+		holding := make([]float64, len(e.Operands)+1)
+		holding[0] = 1
+		for i, operand := range e.Operands {
+			p := operand.probability(probabilityOf)
+			for j := i + 1; j > 0; j-- {
+				holding[j] = holding[j]*(1-p) + holding[j-1]*p
+			}
+			holding[0] *= 1 - p
+		}
+		atLeast := 0.0
+		for j := e.K; j < len(holding); j++ {
+			atLeast += holding[j]
+		}
+		return atLeast
+	default:
+		return 0
+	}
+}
+
+// validateExpression reports the problems with the structure of an expression. Its events are
+// checked with the rest of the dependencies.
+func validateExpression(e Expression, report func(format string, args ...interface{})) {
+	switch e.Operator {
+	case ExpressionEvent:
+		if e.Event == "" {
+			report("expression has no event")
+		}
+		if len(e.Operands) > 0 {
+			report("expression on %q has operands", e.Event)
+		}
+	case ExpressionAnd, ExpressionOr:
+		if len(e.Operands) == 0 {
+			report("%q expression has no operands", e.Operator)
+		}
+	case ExpressionNot:
+		if len(e.Operands) != 1 {
+			report("\"not\" expression has %d operands, expected 1", len(e.Operands))
+		}
+	case ExpressionAtLeast:
+		if e.K < 1 || e.K > len(e.Operands) {
+			report("\"at least %d\" expression has %d operands; at least must be between 1 and the number of operands", e.K, len(e.Operands))
+		}
+	default:
+		report("unknown expression operator %d", e.Operator)
+	}
+	for _, operand := range e.Operands {
+		validateExpression(operand, report)
+	}
+}
@@ -1,5 +1,10 @@
 package montecargo
 
+import (
+	"fmt"
+	"time"
+)
+
 func findEventByName(events []Event, name string) (*Event, bool) {
 	for _, event := range events {
 		if event.Name == name {
@@ -9,43 +14,35 @@ func findEventByName(events []Event, name string) (*Event, bool) {
 	return nil, false
 }
 
-func filterIndependentEvents(events []Event, dependencies map[string][]Dependency) []Event {
-	var independentEvents []Event
-	for _, event := range events {
-		if _, exists := dependencies[event.Name]; !exists {
-			independentEvents = append(independentEvents, event)
-		}
+// TimeframeToString describes the timeframe in the largest unit it is a whole number of. A zero
+// Timeframe is a year, as in Years.
+func TimeframeToString(tf Timeframe) string {
+	if tf == 0 {
+		tf = Yearly
+	}
+	if tf < 0 {
+		return "unknown timeframe"
 	}
-	return independentEvents
-}
 
-func filterDependentEvents(events []Event, dependencies map[string][]Dependency) []Event {
-	var dependentEvents []Event
-	for _, event := range events {
-		if _, exists := dependencies[event.Name]; exists {
-			dependentEvents = append(dependentEvents, event)
+	units := []struct {
+		length Timeframe
+		name   string
+	}{
+		{Yearly, "year"},
+		{Monthly, "month"},
+		{Weekly, "week"},
+		{Daily, "day"},
+		{Timeframe(time.Hour), "hour"},
+	}
+	for _, unit := range units {
+		if tf%unit.length == 0 {
+			count := int64(tf / unit.length)
+			if count == 1 {
+				return "1 " + unit.name
+			}
+			return fmt.Sprintf("%d %ss", count, unit.name)
 		}
 	}
-	return dependentEvents
-}
 
-func TimeframeToString(tf Timeframe) string {
-	switch tf {
-	case Daily:
-		return "1 day"
-	case Weekly:
-		return "1 week"
-	case Monthly:
-		return "1 month"
-	case Yearly:
-		return "1 year"
-	case EveryTwoYears:
-		return "2 years"
-	case EveryFiveYears:
-		return "5 years"
-	case EveryTenYears:
-		return "10 years"
-	default:
-		return "unknown timeframe"
-	}
+	return time.Duration(tf).String()
 }
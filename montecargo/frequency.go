@@ -0,0 +1,177 @@
+package montecargo
+
+import (
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Frequency selects how many times an event can occur in a single trial.
+type Frequency int
+
+const (
+	// FrequencyBernoulli: the event either occurs once in a trial or not at all.
+	FrequencyBernoulli Frequency = iota
+	// FrequencyPoisson: the number of occurrences in a trial is Poisson distributed.
+	FrequencyPoisson
+	// FrequencyNegativeBinomial: the number of occurrences is negative binomial distributed,
+	// which allows for more variance between years than Poisson.
+	FrequencyNegativeBinomial
+)
+
+func (f Frequency) String() string {
+	switch f {
+	case FrequencyBernoulli:
+		return "bernoulli"
+	case FrequencyPoisson:
+		return "poisson"
+	case FrequencyNegativeBinomial:
+		return "negative binomial"
+	default:
+		return "unknown frequency"
+	}
+}
+
+// maxRateProbability caps the probability an occurrence rate is derived from, so that events
+// that are certain to occur still get a finite rate. Such events should set AnnualRate.
+const maxRateProbability = 1 - 1e-9
+
+// probabilityToRate converts the probability of at least one occurrence into the rate of a
+// Poisson process with that probability.
+func probabilityToRate(probability float64) float64 {
+	return -math.Log(1 - math.Min(probability, maxRateProbability))
+}
+
+// rateToProbability converts the rate of a Poisson process into the probability of at least
+// one occurrence.
+func rateToProbability(rate float64) float64 {
+	return 1 - math.Exp(-rate)
+}
+
+// sampleOccurrences draws the number of times the event occurs in a trial given its adjusted
+// probability of occurring at least once. A correlated event passes the value it drew from the
+// copula as quantile, which replaces the uniform draw of its occurrence.
+func sampleOccurrences(event Event, adjustedProb float64, horizon Timeframe, localRand *rand.Rand, quantile *float64) int {
+	if event.Frequency == FrequencyBernoulli {
+		var u float64
+		if quantile != nil {
+			u = *quantile
+		} else {
+			u = localRand.Float64()
+		}
+		if u < adjustedProb {
+			return 1
+		}
+		return 0
+	}
+
+	if adjustedProb <= 0 {
+		return 0
+	}
+
+	// dependencies and confidence adjustments scale the rate like they scale the probability
+	rate := probabilityToRate(adjustedProb)
+	if event.AnnualRate != nil {
+		baseProb := adjustProbabilityForTimeframe(event, horizon)
+		if baseProb > 0 {
+			rate = *event.AnnualRate * horizon.Years() * adjustedProb / baseProb
+		}
+	}
+
+	if event.Frequency == FrequencyNegativeBinomial {
+		dispersion := 1.0
+		if event.Dispersion != nil {
+			dispersion = *event.Dispersion
+		}
+		// a Poisson draw whose rate is gamma distributed is negative binomial
+		rate = rate / dispersion * sampleGamma(dispersion, localRand)
+	}
+
+	if quantile != nil {
+		return poissonQuantile(rate, *quantile)
+	}
+	return samplePoisson(rate, localRand)
+}
+
+// poissonQuantile returns the smallest count whose Poisson cumulative probability with the given
+// mean reaches p.
+func poissonQuantile(lambda, p float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	if lambda >= 500 {
+		// the normal approximation with a continuity correction, before e^-lambda underflows
+		return int(math.Max(0, math.Ceil(lambda+math.Sqrt(lambda)*distuv.UnitNormal.Quantile(p)-0.5)))
+	}
+
+	probability := math.Exp(-lambda)
+	cumulative := probability
+	count := 0
+	// past the mean the terms shrink, so stop once rounding keeps the sum below p
+	for cumulative < p && (float64(count) < lambda || probability > 1e-17) {
+		count++
+		probability *= lambda / float64(count)
+		cumulative += probability
+	}
+	return count
+}
+
+// samplePoisson draws from a Poisson distribution with the given mean.
+func samplePoisson(lambda float64, localRand *rand.Rand) int {
+	if lambda <= 0 {
+		return 0
+	}
+
+	if lambda < 30 {
+		// Knuth's multiplication method
+		limit := math.Exp(-lambda)
+		product := localRand.Float64()
+		count := 0
+		for product > limit {
+			product *= localRand.Float64()
+			count++
+		}
+		return count
+	}
+
+	// transformed rejection with squeeze (PTRS), Hörmann 1993
+	sqrtLambda := math.Sqrt(lambda)
+	logLambda := math.Log(lambda)
+	b := 0.931 + 2.53*sqrtLambda
+	a := -0.059 + 0.02483*b
+	invAlpha := 1.1239 + 1.1328/(b-3.4)
+	vr := 0.9277 - 3.6224/(b-2)
+
+	for {
+		u := localRand.Float64() - 0.5
+		v := localRand.Float64()
+		us := 0.5 - math.Abs(u)
+		k := math.Floor((2*a/us+b)*u + lambda + 0.43)
+
+		if us >= 0.07 && v <= vr {
+			return int(k)
+		}
+		if k < 0 || (us < 0.013 && v > us) {
+			continue
+		}
+
+		logGamma, _ := math.Lgamma(k + 1)
+		if math.Log(v)+math.Log(invAlpha)-math.Log(a/(us*us)+b) <= -lambda+k*logLambda-logGamma {
+			return int(k)
+		}
+	}
+}
+
+// sampleTrialImpact draws an impact for each occurrence of an event in a trial and returns
+// their total and sum of squares. A correlated event's first occurrence takes its impact at the
+// quantile it drew from the copula; any further occurrences are drawn independently.
+func sampleTrialImpact(event Event, occurrences int, localRand *rand.Rand, quantile *float64) (total, sumOfSquares float64) {
+	for i := 0; i < occurrences; i++ {
+		impact := calculateImpact(event, localRand, quantile)
+		quantile = nil
+		total += impact
+		sumOfSquares += impact * impact
+	}
+	return total, sumOfSquares
+}
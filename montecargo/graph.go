@@ -0,0 +1,248 @@
+package montecargo
+
+import (
+	"sort"
+	"strings"
+)
+
+// DependencyGraph is a directed acyclic graph of events built from a dependency map.
+// Every event points to the events that depend on it.
+type DependencyGraph struct {
+	order    []string
+	levels   [][]string
+	parents  map[string][]string
+	children map[string][]string
+}
+
+// BuildDependencyGraph builds the dependency graph of the given events. It returns an error
+// if an event name is duplicated, a dependency references an unknown event or the event
+// itself, or the dependencies contain a cycle.
+func BuildDependencyGraph(events []Event, dependencies map[string][]Dependency) (*DependencyGraph, error) {
+	known := make(map[string]bool, len(events))
+	for _, event := range events {
+		if known[event.Name] {
+			return nil, errorf(ErrDuplicateEvent, "duplicate event name %q", event.Name)
+		}
+		known[event.Name] = true
+	}
+
+	dependentNames := make([]string, 0, len(dependencies))
+	for eventName := range dependencies {
+		dependentNames = append(dependentNames, eventName)
+	}
+	sort.Strings(dependentNames)
+
+	for _, eventName := range dependentNames {
+		if !known[eventName] {
+			return nil, errorf(ErrUnknownEvent, "dependencies defined for unknown event %q", eventName)
+		}
+		for _, dep := range dependencies[eventName] {
+			for _, parent := range dep.events() {
+				if parent == eventName {
+					return nil, errorf(ErrInvalidDependency, "event %q depends on itself", eventName)
+				}
+				if !known[parent] {
+					return nil, errorf(ErrUnknownEvent, "event %q depends on unknown event %q", eventName, parent)
+				}
+			}
+		}
+	}
+
+	if cycle := findDependencyCycle(events, dependencies); cycle != nil {
+		return nil, errorf(ErrInvalidDependency, "dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	graph := &DependencyGraph{
+		parents:  make(map[string][]string),
+		children: make(map[string][]string),
+	}
+	for _, event := range events {
+		for _, dep := range dependencies[event.Name] {
+			for _, parent := range dep.events() {
+				graph.parents[event.Name] = appendUnique(graph.parents[event.Name], parent)
+				graph.children[parent] = appendUnique(graph.children[parent], event.Name)
+			}
+		}
+	}
+
+	for _, level := range dependencyLevels(events, dependencies) {
+		names := make([]string, len(level))
+		for i, event := range level {
+			names[i] = event.Name
+		}
+		graph.levels = append(graph.levels, names)
+		graph.order = append(graph.order, names...)
+	}
+
+	return graph, nil
+}
+
+// Order returns the event names in topological order: every event comes after all of the
+// events it depends on.
+func (g *DependencyGraph) Order() []string {
+	return append([]string(nil), g.order...)
+}
+
+// Levels groups the event names by depth. Level 0 holds the events without dependencies and
+// every other event sits one level below the deepest event it depends on.
+func (g *DependencyGraph) Levels() [][]string {
+	levels := make([][]string, len(g.levels))
+	for i, level := range g.levels {
+		levels[i] = append([]string(nil), level...)
+	}
+	return levels
+}
+
+// Parents returns the names of the events the given event depends on.
+func (g *DependencyGraph) Parents(eventName string) []string {
+	return append([]string(nil), g.parents[eventName]...)
+}
+
+// Children returns the names of the events that depend on the given event.
+func (g *DependencyGraph) Children(eventName string) []string {
+	return append([]string(nil), g.children[eventName]...)
+}
+
+// dependencyLevels groups events by dependency depth, keeping the input order within a level.
+// Events with dependencies never share level 0 with independent events. References to unknown
+// events are ignored and events caught in a cycle are placed together in a final level.
+func dependencyLevels(events []Event, dependencies map[string][]Dependency) [][]Event {
+	known := make(map[string]bool, len(events))
+	for _, event := range events {
+		known[event.Name] = true
+	}
+
+	levelOf := make(map[string]int, len(events))
+	remaining := events
+	for len(remaining) > 0 {
+		var next []Event
+		for _, event := range remaining {
+			level, ready := 0, true
+			if len(dependencies[event.Name]) > 0 {
+				level = 1
+			}
+			for _, parent := range dependencyParents(dependencies[event.Name]) {
+				if !known[parent] || parent == event.Name {
+					continue
+				}
+				parentLevel, placed := levelOf[parent]
+				if !placed {
+					ready = false
+					break
+				}
+				if parentLevel+1 > level {
+					level = parentLevel + 1
+				}
+			}
+
+			if ready {
+				levelOf[event.Name] = level
+			} else {
+				next = append(next, event)
+			}
+		}
+
+		if len(next) == len(remaining) {
+			break
+		}
+		remaining = next
+	}
+
+	maxLevel := -1
+	for _, level := range levelOf {
+		if level > maxLevel {
+			maxLevel = level
+		}
+	}
+
+	levels := make([][]Event, maxLevel+1)
+	var unresolved []Event
+	for _, event := range events {
+		level, placed := levelOf[event.Name]
+		if !placed {
+			unresolved = append(unresolved, event)
+			continue
+		}
+		levels[level] = append(levels[level], event)
+	}
+
+	// a dependent level can be empty when its only parents were unknown
+	nonEmpty := levels[:0]
+	for _, level := range levels {
+		if len(level) > 0 {
+			nonEmpty = append(nonEmpty, level)
+		}
+	}
+	if len(unresolved) > 0 {
+		nonEmpty = append(nonEmpty, unresolved)
+	}
+
+	return nonEmpty
+}
+
+// findDependencyCycle returns the event names along the first dependency cycle found, with the
+// first event repeated at the end, or nil if there is none.
+func findDependencyCycle(events []Event, dependencies map[string][]Dependency) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	state := make(map[string]int, len(events))
+	var path []string
+	var visit func(eventName string) []string
+	visit = func(eventName string) []string {
+		state[eventName] = visiting
+		path = append(path, eventName)
+
+		for _, parent := range dependencyParents(dependencies[eventName]) {
+			switch state[parent] {
+			case visiting:
+				for i, name := range path {
+					if name == parent {
+						cycle := append([]string(nil), path[i:]...)
+						return append(cycle, parent)
+					}
+				}
+			case unvisited:
+				if cycle := visit(parent); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[eventName] = done
+		return nil
+	}
+
+	for _, event := range events {
+		if state[event.Name] == unvisited {
+			if cycle := visit(event.Name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// dependencyParents returns the names of the events the dependencies are on.
+func dependencyParents(dependencies []Dependency) []string {
+	var parents []string
+	for _, dep := range dependencies {
+		for _, parent := range dep.events() {
+			parents = appendUnique(parents, parent)
+		}
+	}
+	return parents
+}
+
+func appendUnique(names []string, name string) []string {
+	for _, existing := range names {
+		if existing == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
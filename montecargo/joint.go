@@ -0,0 +1,170 @@
+package montecargo
+
+import "math/rand"
+
+// trialCondition is a dependency condition resolved to the index of the event it depends on.
+type trialCondition struct {
+	eventIndex    int
+	mustNotHappen bool
+}
+
+// trialDependencies are the dependencies of an event resolved to event indices.
+type trialDependencies struct {
+	conditions  []trialCondition
+	conditional *conditional // Conditional probabilities of the event, if any
+	parents     []int        // Event indices of the parents of the conditional probabilities
+	requires    []Expression // Expressions the outcomes of the trial must satisfy
+}
+
+// resolveTrialDependencies resolves the dependencies of every event, in the order the events
+// are simulated, to event indices.
+func resolveTrialDependencies(events []Event, dependencies map[string][]Dependency) []trialDependencies {
+	index := make(map[string]int, len(events))
+	for i, event := range events {
+		index[event.Name] = i
+	}
+
+	resolved := make([]trialDependencies, len(events))
+	for i, event := range events {
+		for _, dep := range dependencies[event.Name] {
+			if dep.Requires != nil {
+				resolved[i].requires = append(resolved[i].requires, *dep.Requires)
+			}
+			condition := dependencyCondition(event, dep)
+			if condition == "" {
+				continue
+			}
+			resolved[i].conditions = append(resolved[i].conditions, trialCondition{
+				eventIndex:    index[dep.EventName],
+				mustNotHappen: condition == "not happens",
+			})
+		}
+		if table := resolveConditional(event, dependencies[event.Name]); table != nil {
+			resolved[i].conditional = table
+			for _, parent := range table.parents {
+				resolved[i].parents = append(resolved[i].parents, index[parent])
+			}
+		}
+	}
+	return resolved
+}
+
+// dependencyConditionsMet reports whether every dependency condition of an event holds
+// given the outcomes of the current trial.
+func dependencyConditionsMet(conditions []trialCondition, outcomes []bool) bool {
+	for _, condition := range conditions {
+		if outcomes[condition.eventIndex] == condition.mustNotHappen {
+			return false
+		}
+	}
+	return true
+}
+
+// requirementsMet reports whether every Requires expression of an event holds given the
+// outcomes of the current trial.
+func requirementsMet(requires []Expression, happens func(eventName string) bool) bool {
+	for _, expression := range requires {
+		if !expression.evaluate(happens) {
+			return false
+		}
+	}
+	return true
+}
+
+// Simulate all events jointly, one trial at a time, in dependency order
+func simulateJointEvents(events []Event, firstTrial, trials int, dependencies []trialDependencies, workerRand *rand.Rand, run *runState) SimulationResult {
+	results := make([]EventResult, len(events))
+	coOccurrences := make([][]int, len(events))
+	for i := range coOccurrences {
+		coOccurrences[i] = make([]int, len(events))
+	}
+
+	outcomes := make([]bool, len(events))
+	index := make(map[string]int, len(events))
+	for i, event := range events {
+		index[event.Name] = i
+	}
+	happens := func(eventName string) bool { return outcomes[index[eventName]] }
+	occurred := make([]int, 0, len(events))
+	recorder := newTrialRecorder(trials, run)
+	rng := newTrialRand(events, firstTrial, workerRand, run)
+	correlated := newCorrelatedTrial(events, run)
+
+	completed := runTrials(trials, run, func() {
+		correlated.draw(rng.forCorrelation())
+		occurred = occurred[:0]
+		loss, savings := 0.0, 0.0
+		for i, event := range events {
+			outcomes[i] = false
+
+			if !dependencyConditionsMet(dependencies[i].conditions, outcomes) || !requirementsMet(dependencies[i].requires, happens) {
+				continue
+			}
+			localRand := rng.forEvent(i)
+
+			var adjustedProb float64
+			if table := dependencies[i].conditional; table != nil {
+				adjustedProb = table.probability(event, run.horizon, func(parent int) bool { return outcomes[dependencies[i].parents[parent]] })
+			} else {
+				adjustedProb = sampleProbability(event, run.sampling, run.horizon, localRand)
+			}
+			if event.ConfidenceStdDev != nil {
+				adjustedProb = adjustProbabilityWithConfidenceStdDev(adjustedProb, *event.ConfidenceStdDev, localRand)
+			}
+
+			if occurrences := sampleOccurrences(event, adjustedProb, run.horizon, localRand, correlated.occurrenceQuantile(i)); occurrences > 0 {
+				outcomes[i] = true
+				occurred = append(occurred, i)
+				impact := recordOccurrences(&results[i], occurrences, event, localRand, correlated.impactQuantile(i))
+				loss += trialLoss(event, impact)
+				savings += costSavings(event, impact)
+			}
+		}
+		recorder.record(loss, savings)
+		rng.nextTrial()
+
+		for _, a := range occurred {
+			for _, b := range occurred {
+				if a != b {
+					coOccurrences[a][b]++
+				}
+			}
+		}
+	})
+
+	localResult := workerResult(events, results, recorder.losses, recorder.savings, completed)
+	localResult.CoOccurrences = make(map[string]map[string]int, len(events))
+	for i, event := range events {
+		pairs := make(map[string]int)
+		for k, count := range coOccurrences[i] {
+			if count > 0 {
+				pairs[events[k].Name] = count
+			}
+		}
+		localResult.CoOccurrences[event.Name] = pairs
+	}
+
+	return localResult
+}
+
+func simulateJoint(events []Event, numSimulations int, dependencies map[string][]Dependency, graph *DependencyGraph, streams *rngStreams, run *runState) (SimulationResult, map[string]EventStat) {
+	orderedEvents := make([]Event, 0, len(events))
+	for _, eventName := range graph.Order() {
+		event, _ := findEventByName(events, eventName)
+		orderedEvents = append(orderedEvents, *event)
+	}
+	trialDeps := resolveTrialDependencies(orderedEvents, dependencies)
+
+	workerResults := runWorkers(numSimulations, streams, run, func(firstTrial, trials int, localRand *rand.Rand) SimulationResult {
+		return simulateJointEvents(orderedEvents, firstTrial, trials, trialDeps, localRand, run)
+	})
+	finalResult := mergeWorkerResults(workerResults)
+	if finalResult.CoOccurrences == nil {
+		finalResult.CoOccurrences = make(map[string]map[string]int)
+	}
+
+	// every worker records a result for every event, so none is missing
+	eventStats, _ := CalculateEventStats(finalResult.EventResults, completedTrials(finalResult, events), events)
+
+	return finalResult, eventStats
+}
@@ -0,0 +1,352 @@
+package montecargo
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// defaultCurvePoints is the number of losses a loss exceedance curve is evaluated at when no
+// number is given.
+const defaultCurvePoints = 50
+
+// TolerancePoint is a point of a risk tolerance curve: the highest acceptable probability that
+// the loss over the horizon exceeds Loss.
+type TolerancePoint struct {
+	Loss        float64 `json:"loss"`
+	Probability float64 `json:"probability"`
+}
+
+// ExceedancePoint is the probability that the loss over the horizon exceeds Loss, before and
+// after controls. Tolerance is nil when the curve has no tolerance.
+type ExceedancePoint struct {
+	Loss      float64  `json:"loss"`
+	Inherent  float64  `json:"inherent"`
+	Residual  float64  `json:"residual"`
+	Tolerance *float64 `json:"tolerance,omitempty"`
+}
+
+// ToleranceCrossing is a loss at which the residual curve crosses the tolerance curve.
+// Exceeds is true when residual risk rises above tolerance past Loss and false when it falls
+// back within tolerance.
+type ToleranceCrossing struct {
+	Loss    float64 `json:"loss"`
+	Exceeds bool    `json:"exceeds"`
+}
+
+// LossExceedanceCurve overlays inherent risk, residual risk and a risk tolerance at
+// log-spaced losses.
+type LossExceedanceCurve struct {
+	Points    []ExceedancePoint   `json:"points"`
+	Tolerance []TolerancePoint    `json:"tolerance,omitempty"`
+	Crossings []ToleranceCrossing `json:"crossings,omitempty"`
+}
+
+// WithoutControls returns a copy of events in which every cost saving event never occurs, for
+// simulating inherent risk. Events depending on a control not happening then always may occur.
+func WithoutControls(events []Event) []Event {
+	inherent := make([]Event, len(events))
+	for i, event := range events {
+		if event.IsCostSaving {
+			event = disableEvent(event)
+		}
+		inherent[i] = event
+	}
+	return inherent
+}
+
+// disableEvent returns a copy of the event that never occurs.
+func disableEvent(event Event) Event {
+	event.LowerProb, event.UpperProb = 0, 0
+	event.LowerProbStdDev, event.UpperProbStdDev, event.ConfidenceStdDev = nil, nil, nil
+	event.AnnualRate = nil
+	event.disabled = true
+	return event
+}
+
+// NewLossExceedanceCurve evaluates the exceedance probability of the inherent and residual trial
+// losses at points log-spaced losses between the smallest positive and the largest loss. The
+// tolerance curve is optional.
+func NewLossExceedanceCurve(inherent, residual SimulationResult, tolerance []TolerancePoint, points int) LossExceedanceCurve {
+	if points < 2 {
+		points = defaultCurvePoints
+	}
+
+	inherentLosses := NewLossDistribution(inherent.TrialLosses)
+	residualLosses := NewLossDistribution(residual.TrialLosses)
+	tolerance = append([]TolerancePoint(nil), tolerance...)
+	sort.Slice(tolerance, func(i, j int) bool { return tolerance[i].Loss < tolerance[j].Loss })
+
+	minLoss := math.Min(smallestPositive(inherent.TrialLosses), smallestPositive(residual.TrialLosses))
+	maxLoss := math.Max(inherentLosses.Max(), residualLosses.Max())
+	if math.IsInf(minLoss, 1) || maxLoss <= minLoss {
+		minLoss, maxLoss = 1, math.Max(10, maxLoss)
+	}
+
+	curve := LossExceedanceCurve{Points: make([]ExceedancePoint, points)}
+	if len(tolerance) > 0 {
+		curve.Tolerance = tolerance
+	}
+
+	logMin, logMax := math.Log(minLoss), math.Log(maxLoss)
+	for i := range curve.Points {
+		loss := math.Exp(logMin + (logMax-logMin)*float64(i)/float64(points-1))
+		point := ExceedancePoint{
+			Loss:     loss,
+			Inherent: inherentLosses.ExceedanceProbability(loss),
+			Residual: residualLosses.ExceedanceProbability(loss),
+		}
+		if len(tolerance) > 0 {
+			acceptable := toleranceAt(tolerance, loss)
+			point.Tolerance = &acceptable
+		}
+		curve.Points[i] = point
+	}
+
+	curve.Crossings = toleranceCrossings(curve.Points)
+	return curve
+}
+
+// ExceedsTolerance reports whether residual risk is above tolerance at any point of the curve.
+func (c LossExceedanceCurve) ExceedsTolerance() bool {
+	for _, point := range c.Points {
+		if point.Tolerance != nil && point.Residual > *point.Tolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// LossExceedance simulates the events with and without their controls, using common random
+// numbers for both runs, and returns their loss exceedance curve.
+func (s *Simulator) LossExceedance(ctx context.Context, events []Event, numSimulations int, dependencies map[string][]Dependency, tolerance []TolerancePoint) (LossExceedanceCurve, error) {
+	paired := s.withCommonRandomNumbers()
+	residual, err := paired.Run(ctx, events, numSimulations, dependencies)
+	if err != nil {
+		return LossExceedanceCurve{}, err
+	}
+
+	inherent, err := paired.Run(ctx, WithoutControls(events), numSimulations, dependencies)
+	if err != nil {
+		return LossExceedanceCurve{}, err
+	}
+
+	return NewLossExceedanceCurve(inherent, residual, tolerance, defaultCurvePoints), nil
+}
+
+// WriteCSV writes the curve with a header row, leaving the tolerance column empty when the
+// curve has no tolerance.
+func (c LossExceedanceCurve) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"loss", "inherent", "residual", "tolerance"}); err != nil {
+		return err
+	}
+	for _, point := range c.Points {
+		acceptable := ""
+		if point.Tolerance != nil {
+			acceptable = formatFloat(*point.Tolerance)
+		}
+		record := []string{formatFloat(point.Loss), formatFloat(point.Inherent), formatFloat(point.Residual), acceptable}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes the curve, its tolerance and its crossings as indented JSON.
+func (c LossExceedanceCurve) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// WriteSVG renders the curve as a standalone SVG chart with a logarithmic loss axis.
+func (c LossExceedanceCurve) WriteSVG(w io.Writer) error {
+	const (
+		width, height            = 800.0, 500.0
+		left, right, top, bottom = 80.0, 30.0, 40.0, 60.0
+		plotWidth                = width - left - right
+		plotHeight               = height - top - bottom
+	)
+	if len(c.Points) == 0 {
+		_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g"></svg>`+"\n", width, height)
+		return err
+	}
+
+	logMin := math.Log10(c.Points[0].Loss)
+	logMax := math.Log10(c.Points[len(c.Points)-1].Loss)
+	if logMax <= logMin {
+		logMax = logMin + 1
+	}
+	x := func(loss float64) float64 {
+		return left + (math.Log10(loss)-logMin)/(logMax-logMin)*plotWidth
+	}
+	y := func(probability float64) float64 {
+		return top + (1-probability)*plotHeight
+	}
+
+	svg := &svgWriter{w: w}
+	svg.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="sans-serif" font-size="12">`+"\n", width, height, width, height)
+	svg.printf(`<rect width="%g" height="%g" fill="white"/>`+"\n", width, height)
+	svg.printf(`<text x="%g" y="24" font-size="16" text-anchor="middle">Loss Exceedance Curve</text>`+"\n", width/2)
+
+	// grid and axis labels
+	for tick := 0; tick <= 10; tick += 2 {
+		probability := float64(tick) / 10
+		svg.printf(`<line x1="%g" y1="%.1f" x2="%g" y2="%.1f" stroke="#ddd"/>`+"\n", left, y(probability), left+plotWidth, y(probability))
+		svg.printf(`<text x="%g" y="%.1f" text-anchor="end">%d%%</text>`+"\n", left-8, y(probability)+4, tick*10)
+	}
+	multipliers := []float64{1}
+	if logMax-logMin <= 2 {
+		multipliers = []float64{1, 2, 5}
+	}
+	for exponent := math.Floor(logMin); exponent <= logMax; exponent++ {
+		for _, multiplier := range multipliers {
+			loss := multiplier * math.Pow(10, exponent)
+			if math.Log10(loss) < logMin-1e-9 || math.Log10(loss) > logMax+1e-9 {
+				continue
+			}
+			svg.printf(`<line x1="%.1f" y1="%g" x2="%.1f" y2="%g" stroke="#ddd"/>`+"\n", x(loss), top, x(loss), top+plotHeight)
+			svg.printf(`<text x="%.1f" y="%g" text-anchor="middle">%s</text>`+"\n", x(loss), top+plotHeight+18, formatLoss(loss))
+		}
+	}
+	svg.printf(`<rect x="%g" y="%g" width="%g" height="%g" fill="none" stroke="#333"/>`+"\n", left, top, plotWidth, plotHeight)
+	svg.printf(`<text x="%g" y="%g" text-anchor="middle">Loss</text>`+"\n", left+plotWidth/2, height-16)
+	svg.printf(`<text x="20" y="%g" text-anchor="middle" transform="rotate(-90 20 %g)">Probability of exceeding loss</text>`+"\n", top+plotHeight/2, top+plotHeight/2)
+
+	series := []struct {
+		name, color, dash string
+		value             func(ExceedancePoint) (float64, bool)
+	}{
+		{"Inherent", "#999999", "", func(p ExceedancePoint) (float64, bool) { return p.Inherent, true }},
+		{"Residual", "#1f77b4", "", func(p ExceedancePoint) (float64, bool) { return p.Residual, true }},
+		{"Tolerance", "#d62728", ` stroke-dasharray="6 4"`, func(p ExceedancePoint) (float64, bool) {
+			if p.Tolerance == nil {
+				return 0, false
+			}
+			return *p.Tolerance, true
+		}},
+	}
+	legendY := top + 16
+	for _, line := range series {
+		points := ""
+		for _, point := range c.Points {
+			if value, ok := line.value(point); ok {
+				points += fmt.Sprintf("%.1f,%.1f ", x(point.Loss), y(value))
+			}
+		}
+		if points == "" {
+			continue
+		}
+		svg.printf(`<polyline points="%s" fill="none" stroke="%s" stroke-width="2"%s/>`+"\n", points, line.color, line.dash)
+		svg.printf(`<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s" stroke-width="2"%s/>`+"\n", left+plotWidth-150, legendY, left+plotWidth-120, legendY, line.color, line.dash)
+		svg.printf(`<text x="%g" y="%g">%s</text>`+"\n", left+plotWidth-112, legendY+4, line.name)
+		legendY += 18
+	}
+
+	for _, crossing := range c.Crossings {
+		probability := residualAt(c.Points, crossing.Loss)
+		svg.printf(`<circle cx="%.1f" cy="%.1f" r="4" fill="#d62728"><title>Residual risk crosses tolerance at %s</title></circle>`+"\n", x(crossing.Loss), y(probability), formatLoss(crossing.Loss))
+	}
+
+	svg.printf("</svg>\n")
+	return svg.err
+}
+
+// svgWriter writes formatted output, keeping the first error.
+type svgWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *svgWriter) printf(format string, args ...interface{}) {
+	if s.err == nil {
+		_, s.err = fmt.Fprintf(s.w, format, args...)
+	}
+}
+
+// toleranceAt interpolates the tolerance curve, sorted by loss, linearly in the logarithm of
+// the loss. Losses outside the curve take the tolerance of its nearest end.
+func toleranceAt(tolerance []TolerancePoint, loss float64) float64 {
+	if loss <= tolerance[0].Loss {
+		return tolerance[0].Probability
+	}
+	for i := 1; i < len(tolerance); i++ {
+		if loss <= tolerance[i].Loss {
+			lower, upper := tolerance[i-1], tolerance[i]
+			if lower.Loss <= 0 {
+				return upper.Probability
+			}
+			t := math.Log(loss/lower.Loss) / math.Log(upper.Loss/lower.Loss)
+			return lower.Probability + (upper.Probability-lower.Probability)*t
+		}
+	}
+	return tolerance[len(tolerance)-1].Probability
+}
+
+// toleranceCrossings finds the losses at which residual risk crosses tolerance, interpolating
+// between points in the logarithm of the loss.
+func toleranceCrossings(points []ExceedancePoint) []ToleranceCrossing {
+	var crossings []ToleranceCrossing
+	for i := 1; i < len(points); i++ {
+		previous, current := points[i-1], points[i]
+		if previous.Tolerance == nil || current.Tolerance == nil {
+			continue
+		}
+		before := previous.Residual - *previous.Tolerance
+		after := current.Residual - *current.Tolerance
+		if (before > 0) == (after > 0) {
+			continue
+		}
+		t := before / (before - after)
+		loss := math.Exp(math.Log(previous.Loss) + t*math.Log(current.Loss/previous.Loss))
+		crossings = append(crossings, ToleranceCrossing{Loss: loss, Exceeds: after > 0})
+	}
+	return crossings
+}
+
+// residualAt interpolates the residual curve at a loss within it.
+func residualAt(points []ExceedancePoint, loss float64) float64 {
+	for i := 1; i < len(points); i++ {
+		if loss <= points[i].Loss {
+			t := math.Log(loss/points[i-1].Loss) / math.Log(points[i].Loss/points[i-1].Loss)
+			return points[i-1].Residual + (points[i].Residual-points[i-1].Residual)*t
+		}
+	}
+	return points[len(points)-1].Residual
+}
+
+func smallestPositive(losses []float64) float64 {
+	smallest := math.Inf(1)
+	for _, loss := range losses {
+		if loss > 0 && loss < smallest {
+			smallest = loss
+		}
+	}
+	return smallest
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// formatLoss abbreviates a loss for chart labels, e.g. $10K or $1.5M.
+func formatLoss(loss float64) string {
+	units := []struct {
+		size   float64
+		suffix string
+	}{{1e12, "T"}, {1e9, "B"}, {1e6, "M"}, {1e3, "K"}}
+	for _, unit := range units {
+		if loss >= unit.size {
+			return "$" + strconv.FormatFloat(loss/unit.size, 'g', 3, 64) + unit.suffix
+		}
+	}
+	return "$" + strconv.FormatFloat(loss, 'g', 3, 64)
+}
@@ -0,0 +1,147 @@
+package montecargo
+
+import (
+	"math"
+	"sort"
+)
+
+// LossDistribution is the empirical distribution of the total loss of a trial.
+type LossDistribution struct {
+	sorted []float64
+}
+
+// NewLossDistribution returns the distribution of the given trial losses.
+func NewLossDistribution(trialLosses []float64) *LossDistribution {
+	sorted := append([]float64(nil), trialLosses...)
+	sort.Float64s(sorted)
+	return &LossDistribution{sorted: sorted}
+}
+
+// Len returns the number of trials in the distribution.
+func (d *LossDistribution) Len() int {
+	return len(d.sorted)
+}
+
+// Mean returns the mean loss, which is the expected annual loss for a one year horizon.
+func (d *LossDistribution) Mean() float64 {
+	if len(d.sorted) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, loss := range d.sorted {
+		sum += loss
+	}
+	return sum / float64(len(d.sorted))
+}
+
+// StdDev returns the population standard deviation of the loss.
+func (d *LossDistribution) StdDev() float64 {
+	if len(d.sorted) == 0 {
+		return 0
+	}
+	mean := d.Mean()
+	sumOfSquares := 0.0
+	for _, loss := range d.sorted {
+		sumOfSquares += (loss - mean) * (loss - mean)
+	}
+	return math.Sqrt(sumOfSquares / float64(len(d.sorted)))
+}
+
+// Percentile returns the loss below which the fraction p (0 to 1) of trials fall, linearly
+// interpolating between trials.
+func (d *LossDistribution) Percentile(p float64) float64 {
+	if len(d.sorted) == 0 {
+		return 0
+	}
+	p = math.Max(0, math.Min(1, p))
+	position := p * float64(len(d.sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return d.sorted[lower] + (d.sorted[upper]-d.sorted[lower])*(position-float64(lower))
+}
+
+// ValueAtRisk returns the loss that is not exceeded at the given confidence level, e.g. 0.99.
+func (d *LossDistribution) ValueAtRisk(level float64) float64 {
+	return d.Percentile(level)
+}
+
+// ExpectedShortfall returns the mean loss of the worst 1 - level fraction of trials, also known
+// as tail value at risk.
+func (d *LossDistribution) ExpectedShortfall(level float64) float64 {
+	if len(d.sorted) == 0 {
+		return 0
+	}
+	// round away the floating point error in 1 - level first, or 0.99 of 1000 trials would
+	// take the worst 11 of them
+	tail := int(math.Ceil(math.Round((1-level)*float64(len(d.sorted))*1e9) / 1e9))
+	if tail < 1 {
+		tail = 1
+	}
+	if tail > len(d.sorted) {
+		tail = len(d.sorted)
+	}
+
+	sum := 0.0
+	for _, loss := range d.sorted[len(d.sorted)-tail:] {
+		sum += loss
+	}
+	return sum / float64(tail)
+}
+
+// ExceedanceProbability returns the fraction of trials whose loss is greater than loss.
+func (d *LossDistribution) ExceedanceProbability(loss float64) float64 {
+	if len(d.sorted) == 0 {
+		return 0
+	}
+	below := sort.Search(len(d.sorted), func(i int) bool { return d.sorted[i] > loss })
+	return float64(len(d.sorted)-below) / float64(len(d.sorted))
+}
+
+// Max returns the largest loss of any trial.
+func (d *LossDistribution) Max() float64 {
+	if len(d.sorted) == 0 {
+		return 0
+	}
+	return d.sorted[len(d.sorted)-1]
+}
+
+// LossReport summarises the total loss of a trial across every event of a simulation. It
+// complements SimulationResult, which describes events one at a time.
+type LossReport struct {
+	Trials int
+	Mean   float64 // Expected loss over the simulation horizon
+	StdDev float64
+	P50    float64
+	P90    float64
+	P95    float64
+	P99    float64
+	P999   float64
+	Level  float64 // Confidence level of VaR and TVaR
+	VaR    float64 // Value at risk: the loss not exceeded at Level
+	TVaR   float64 // Tail value at risk (expected shortfall): the mean loss beyond VaR
+	Max    float64 // Largest loss observed in any trial
+}
+
+// NewLossReport summarises the trial losses of a simulation, measuring value at risk and
+// expected shortfall at the given confidence level, e.g. 0.99.
+func NewLossReport(result SimulationResult, level float64) LossReport {
+	return newLossReport(result.TrialLosses, level)
+}
+
+func newLossReport(losses []float64, level float64) LossReport {
+	distribution := NewLossDistribution(losses)
+	return LossReport{
+		Trials: distribution.Len(),
+		Mean:   distribution.Mean(),
+		StdDev: distribution.StdDev(),
+		P50:    distribution.Percentile(0.5),
+		P90:    distribution.Percentile(0.9),
+		P95:    distribution.Percentile(0.95),
+		P99:    distribution.Percentile(0.99),
+		P999:   distribution.Percentile(0.999),
+		Level:  level,
+		VaR:    distribution.ValueAtRisk(level),
+		TVaR:   distribution.ExpectedShortfall(level),
+		Max:    distribution.Max(),
+	}
+}
@@ -1,11 +1,15 @@
 package montecargo
 
 import (
-	"fmt"
 	"math"
 	"math/rand"
+	"strconv"
+	"strings"
 )
 
+// MeanSTD returns the probability that the event occurs in a trial, and the mean and standard
+// deviation of its loss in the trials it occurred in (the loss given occurrence). Use
+// CalculateLossStats for the expected loss over all trials.
 func MeanSTD(eventResult EventResult, numSimulations int) (probability, probStdDev, impactMean, impactStdDev float64) {
 	probability = float64(eventResult.Sum) / float64(numSimulations)
 	probMean := probability
@@ -23,27 +27,53 @@ func MeanSTD(eventResult EventResult, numSimulations int) (probability, probStdD
 	return
 }
 
-func adjustProbabilityWithConfidenceStdDev(probability, confidenceStdDev float64, localRand *rand.Rand) float64 {
-	// Adjust the probability based on a normal distribution centered around the original probability
-	// and a standard deviation defined by the confidence standard deviation.
-	adjustedProbability := probability + localRand.NormFloat64()*confidenceStdDev
-
-	// Ensure the adjusted probability is within the range [0, 1]
-	if adjustedProbability < 0 {
-		adjustedProbability = 0
-	} else if adjustedProbability > 1 {
-		adjustedProbability = 1
+// CalculateLossStats summarises the event's losses per occurrence, per trial it occurred in and
+// per trial overall.
+func CalculateLossStats(eventResult EventResult, numSimulations int) LossStats {
+	var stats LossStats
+	if eventResult.Occurrences > 0 {
+		stats.SeverityMean, stats.SeverityStdDev = meanStdDev(eventResult.ImpactSum, eventResult.SeveritySumOfSquares, float64(eventResult.Occurrences))
+	}
+	if eventResult.Sum > 0 {
+		stats.ConditionalMean, stats.ConditionalStdDev = meanStdDev(eventResult.ImpactSum, eventResult.ImpactSumOfSquares, float64(eventResult.Sum))
 	}
+	if numSimulations > 0 {
+		// trials without the event add a zero loss, which leaves both sums unchanged
+		stats.ExpectedLoss, stats.ExpectedLossStdDev = meanStdDev(eventResult.ImpactSum, eventResult.ImpactSumOfSquares, float64(numSimulations))
+	}
+	return stats
+}
 
-	return adjustedProbability
+// meanStdDev returns the mean and population standard deviation of count values given their
+// sum and sum of squares.
+func meanStdDev(sum, sumOfSquares, count float64) (mean, stdDev float64) {
+	mean = sum / count
+	variance := sumOfSquares/count - mean*mean
+	return mean, math.Sqrt(math.Max(0, variance))
 }
 
-func adjustDependentProbability(probability, dependencyProb, dependencyStdDev float64, localRand *rand.Rand) float64 {
-	// scale the probability by the dependency's probability
-	// and apply a random adjustment based on the dependency's standard deviation
-	adjustedProbability := probability * dependencyProb
-	adjustmentFactor := localRand.NormFloat64() * dependencyStdDev
-	adjustedProbability += adjustmentFactor
+// JointProbability returns the fraction of trials in which both events occurred.
+// It requires a result produced by JointMonteCarloSimulation, returning ErrNoCoOccurrences for
+// any other, and returns ErrUnknownEvent for events that aren't in the result.
+func JointProbability(simulationResult SimulationResult, numSimulations int, eventA, eventB string) (float64, error) {
+	if numSimulations <= 0 {
+		return 0, errorf(ErrInvalidTrials, "number of trials %d must be positive", numSimulations)
+	}
+	if simulationResult.CoOccurrences == nil {
+		return 0, errorf(ErrNoCoOccurrences, "result has no co-occurrences, which only joint simulations record")
+	}
+	for _, eventName := range []string{eventA, eventB} {
+		if _, exists := simulationResult.EventResults[eventName]; !exists {
+			return 0, errorf(ErrUnknownEvent, "no result for event %q", eventName)
+		}
+	}
+	return float64(simulationResult.CoOccurrences[eventA][eventB]) / float64(numSimulations), nil
+}
+
+func adjustProbabilityWithConfidenceStdDev(probability, confidenceStdDev float64, localRand *rand.Rand) float64 {
+	// Adjust the probability based on a normal distribution centered around the original probability
+	// and a standard deviation defined by the confidence standard deviation.
+	adjustedProbability := probability + localRand.NormFloat64()*confidenceStdDev
 
 	// Ensure the adjusted probability is within the range [0, 1]
 	if adjustedProbability < 0 {
@@ -55,13 +85,16 @@ func adjustDependentProbability(probability, dependencyProb, dependencyStdDev fl
 	return adjustedProbability
 }
 
-func CalculateEventStats(simulationResults map[string]EventResult, numSimulations int, events []Event) map[string]EventStat {
+// CalculateEventStats summarises the results of the events over numSimulations trials. Events
+// without a result are left out of the stats and reported with ErrUnknownEvent.
+func CalculateEventStats(simulationResults map[string]EventResult, numSimulations int, events []Event) (map[string]EventStat, error) {
 	eventStats := make(map[string]EventStat)
+	var missing []string
 
 	for _, event := range events {
 		eventResult, exists := simulationResults[event.Name]
 		if !exists {
-			fmt.Printf("Event result not found for %s\n", event.Name)
+			missing = append(missing, strconv.Quote(event.Name))
 			continue
 		}
 
@@ -69,6 +102,10 @@ func CalculateEventStats(simulationResults map[string]EventResult, numSimulation
 		stat := EventStat{
 			Probability: probability,
 			StdDev:      stdDev,
+			Loss:        CalculateLossStats(eventResult, numSimulations),
+		}
+		if numSimulations > 0 {
+			stat.MeanFrequency = float64(eventResult.Occurrences) / float64(numSimulations)
 		}
 
 		// Calculate the bounds for the cost of implementation if applicable
@@ -78,6 +115,8 @@ func CalculateEventStats(simulationResults map[string]EventResult, numSimulation
 
 			if event.CostOfImplementationLowerStdDev != nil {
 				lowerCost -= *event.CostOfImplementationLowerStdDev // Minimum
+			}
+			if event.CostOfImplementationUpperStdDev != nil {
 				upperCost += *event.CostOfImplementationUpperStdDev // Maximum
 			}
 
@@ -88,13 +127,27 @@ func CalculateEventStats(simulationResults map[string]EventResult, numSimulation
 		eventStats[event.Name] = stat
 	}
 
-	return eventStats
+	if len(missing) > 0 {
+		return eventStats, errorf(ErrUnknownEvent, "no result for event %s", strings.Join(missing, ", "))
+	}
+	return eventStats, nil
 }
 
+// NormalCDF returns the probability that a normal variable is at most x. A zero standard
+// deviation is a point mass at the mean.
 func NormalCDF(x, mean, stdDev float64) float64 {
+	if stdDev == 0 {
+		if x < mean {
+			return 0
+		}
+		return 1
+	}
 	return 0.5 * (1 + math.Erf((x-mean)/(stdDev*math.Sqrt2)))
 }
 
+// CalculateExpectedLossRange estimates each event's loss range from its probability and impact
+// bounds, with exceedance probabilities from a normal approximation. It doesn't use simulated
+// losses; NewLossReport gives percentiles and tail measures of the simulated total loss.
 func CalculateExpectedLossRange(events []Event, eventStats map[string]EventStat) (float64, float64, float64, float64, float64, map[string]struct {
 	MinLoss              float64
 	MaxLoss              float64
@@ -157,66 +210,67 @@ func CalculateExpectedLossRange(events []Event, eventStats map[string]EventStat)
 	return totalMinLoss, totalMaxLoss, totalAvgLoss, probExceedTotalMin, probExceedTotalMax, lossBreakdown
 }
 
-func calculateImpact(event Event, probability float64, localRand *rand.Rand) int {
-	if event.MinImpact == nil || event.MaxImpact == nil {
-		return 0
-	}
-
-	impactRange := *event.MaxImpact - *event.MinImpact
-	impact := *event.MinImpact + impactRange*localRand.Float64()
-
-	if event.ConfidenceStdDev != nil {
-		impactAdjustment := localRand.NormFloat64() * *event.ConfidenceStdDev
-		impact += impactAdjustment
-	}
-
-	if event.IsCostSaving {
-		return -int(impact * probability) // Negative impact for cost savings
+// widenRange widens [lower, upper] around its middle so that the original range covers the
+// confidence fraction of the result. Confidence outside (0, 1) leaves the range as it is.
+func widenRange(lower, upper, confidence float64) (float64, float64) {
+	if confidence <= 0 || confidence >= 1 {
+		return lower, upper
 	}
-	return int(impact * probability) // Positive impact for losses
+	middle := (lower + upper) / 2
+	halfWidth := (upper - lower) / 2 / confidence
+	return middle - halfWidth, middle + halfWidth
 }
 
-func calculateTotalMitigatedImpact(costSavingEvent Event, events []Event, eventStats map[string]EventStat, dependencies map[string][]Dependency, localRand *rand.Rand) (float64, float64, float64) {
-	totalSavings := 0.0
-	var lowerCost, upperCost float64
-
-	costSavingEventStat, exists := eventStats[costSavingEvent.Name]
-	if !exists {
-		fmt.Printf("Cost-saving event stat not found for %s\n", costSavingEvent.Name)
-		return 0.0, 0.0, 0.0
+// confidenceZ returns the z-score of a central confidence interval, defaulting to 90% when the
+// confidence is outside (0, 1).
+func confidenceZ(confidence float64) float64 {
+	if confidence <= 0 || confidence >= 1 {
+		return z90
 	}
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
 
-	occurrences := GetOccurrencesPerYear(costSavingEvent.Timeframe)
-
-	for _, dep := range dependencies[costSavingEvent.Name] {
-		if dep.Condition == "not happens" {
-			dependentEventStat, exists := eventStats[dep.EventName]
-			if exists {
-				dependentEvent, found := findEventByName(events, dep.EventName)
-				if found && dependentEvent.MinImpact != nil && dependentEvent.MaxImpact != nil {
-					avgImpact := (*dependentEvent.MinImpact + *dependentEvent.MaxImpact) / 2
-					savings := avgImpact * (1 - dependentEventStat.Probability) * costSavingEventStat.Probability
-					totalSavings += savings * occurrences
-				}
-			}
+// calculateImpact draws the loss of a single occurrence of the event, or the negative of its
+// savings for cost saving events. A non-nil quantile, drawn from a copula, replaces the uniform
+// draw the impact is otherwise based on.
+func calculateImpact(event Event, localRand *rand.Rand, quantile *float64) float64 {
+	var impact float64
+	if event.ImpactDistribution != nil {
+		// losses and savings can't be negative, whatever the tail of the distribution
+		if quantiler, ok := event.ImpactDistribution.(Quantiler); ok && quantile != nil {
+			impact = math.Max(0, quantiler.Quantile(*quantile))
+		} else {
+			impact = math.Max(0, event.ImpactDistribution.Sample(localRand))
+		}
+	} else {
+		if event.MinImpact == nil || event.MaxImpact == nil {
+			return 0
 		}
-	}
 
-	if costSavingEvent.CostOfImplementationLower != nil {
-		lowerCost = *costSavingEvent.CostOfImplementationLower
-		if costSavingEvent.CostOfImplementationLowerStdDev != nil {
-			lowerCostAdjustment := localRand.NormFloat64() * *costSavingEvent.CostOfImplementationLowerStdDev
-			lowerCost += lowerCostAdjustment
+		// the bounds are estimates too, so perturb each by its own standard deviation
+		minImpact, maxImpact := *event.MinImpact, *event.MaxImpact
+		if event.MinImpactStdDev != nil {
+			minImpact += localRand.NormFloat64() * *event.MinImpactStdDev
+		}
+		if event.MaxImpactStdDev != nil {
+			maxImpact += localRand.NormFloat64() * *event.MaxImpactStdDev
+		}
+		if minImpact > maxImpact {
+			minImpact, maxImpact = maxImpact, minImpact
 		}
-	}
 
-	if costSavingEvent.CostOfImplementationUpper != nil {
-		upperCost = *costSavingEvent.CostOfImplementationUpper
-		if costSavingEvent.CostOfImplementationUpperStdDev != nil {
-			upperCostAdjustment := localRand.NormFloat64() * *costSavingEvent.CostOfImplementationUpperStdDev
-			upperCost += upperCostAdjustment
+		minImpact, maxImpact = widenRange(minImpact, maxImpact, event.Confidence)
+		var u float64
+		if quantile != nil {
+			u = *quantile
+		} else {
+			u = localRand.Float64()
 		}
+		impact = math.Max(0, minImpact+(maxImpact-minImpact)*u)
 	}
 
-	return totalSavings, lowerCost, upperCost
+	if event.IsCostSaving {
+		return -impact // Negative impact for cost savings
+	}
+	return impact // Positive impact for losses
 }
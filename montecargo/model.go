@@ -0,0 +1,832 @@
+package montecargo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelVersion is the version of the model file format written by this package.
+const ModelVersion = 1
+
+// Model is a complete scenario: its events, the dependencies between them and the settings
+// to simulate it with.
+type Model struct {
+	Version      int
+	Events       []Event
+	Dependencies map[string][]Dependency
+	Settings     ModelSettings
+}
+
+// ModelSettings are the simulation settings stored with a model. Zero values leave the
+// simulator's defaults in place.
+type ModelSettings struct {
+	Trials      int
+	Seed        *int64
+	Workers     int
+	Horizon     Timeframe
+	Strategy    Strategy
+	Sampling    ProbabilitySampling
+	Correlation *Correlation
+}
+
+// Options returns the simulation options for the settings.
+func (s ModelSettings) Options() []SimulationOption {
+	opts := []SimulationOption{WithStrategy(s.Strategy), WithProbabilitySampling(s.Sampling)}
+	if s.Seed != nil {
+		opts = append(opts, WithSeed(*s.Seed))
+	}
+	if s.Workers > 0 {
+		opts = append(opts, WithWorkers(s.Workers))
+	}
+	if s.Horizon > 0 {
+		opts = append(opts, WithHorizon(s.Horizon))
+	}
+	if s.Correlation != nil {
+		opts = append(opts, WithCorrelation(*s.Correlation))
+	}
+	return opts
+}
+
+// ModelError is a problem with a model file at a line and column of it. Line is zero when the
+// problem isn't tied to a position.
+type ModelError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e ModelError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	default:
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+}
+
+// ModelErrors are all the problems found in a model file.
+type ModelErrors []ModelError
+
+func (e ModelErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// modelFile is the layout of a model file.
+type modelFile struct {
+	Version      int                         `yaml:"version" json:"version"`
+	Settings     *settingsFile               `yaml:"settings,omitempty" json:"settings,omitempty"`
+	Events       []eventFile                 `yaml:"events" json:"events"`
+	Dependencies map[string][]dependencyFile `yaml:"dependencies,omitempty" json:"dependencies,omitempty"`
+}
+
+type settingsFile struct {
+	Trials      int              `yaml:"trials,omitempty" json:"trials,omitempty"`
+	Seed        *int64           `yaml:"seed,omitempty" json:"seed,omitempty"`
+	Workers     int              `yaml:"workers,omitempty" json:"workers,omitempty"`
+	Horizon     string           `yaml:"horizon,omitempty" json:"horizon,omitempty"`
+	Strategy    string           `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	Sampling    string           `yaml:"sampling,omitempty" json:"sampling,omitempty"`
+	Correlation *correlationFile `yaml:"correlation,omitempty" json:"correlation,omitempty"`
+}
+
+// correlationFile is the layout of a correlation between events.
+type correlationFile struct {
+	Copula           string      `yaml:"copula,omitempty" json:"copula,omitempty"`
+	DegreesOfFreedom float64     `yaml:"degreesOfFreedom,omitempty" json:"degreesOfFreedom,omitempty"`
+	Target           string      `yaml:"target,omitempty" json:"target,omitempty"`
+	Repair           bool        `yaml:"repair,omitempty" json:"repair,omitempty"`
+	Events           []string    `yaml:"events" json:"events"`
+	Matrix           [][]float64 `yaml:"matrix" json:"matrix"`
+}
+
+type eventFile struct {
+	Name                            string            `yaml:"name" json:"name"`
+	LowerProb                       float64           `yaml:"lowerProb" json:"lowerProb"`
+	UpperProb                       float64           `yaml:"upperProb" json:"upperProb"`
+	LowerProbStdDev                 *float64          `yaml:"lowerProbStdDev,omitempty" json:"lowerProbStdDev,omitempty"`
+	UpperProbStdDev                 *float64          `yaml:"upperProbStdDev,omitempty" json:"upperProbStdDev,omitempty"`
+	Confidence                      float64           `yaml:"confidence,omitempty" json:"confidence,omitempty"`
+	ConfidenceStdDev                *float64          `yaml:"confidenceStdDev,omitempty" json:"confidenceStdDev,omitempty"`
+	Timeframe                       string            `yaml:"timeframe" json:"timeframe"`
+	Frequency                       string            `yaml:"frequency,omitempty" json:"frequency,omitempty"`
+	AnnualRate                      *float64          `yaml:"annualRate,omitempty" json:"annualRate,omitempty"`
+	Dispersion                      *float64          `yaml:"dispersion,omitempty" json:"dispersion,omitempty"`
+	MinImpact                       *float64          `yaml:"minImpact,omitempty" json:"minImpact,omitempty"`
+	MaxImpact                       *float64          `yaml:"maxImpact,omitempty" json:"maxImpact,omitempty"`
+	MinImpactStdDev                 *float64          `yaml:"minImpactStdDev,omitempty" json:"minImpactStdDev,omitempty"`
+	MaxImpactStdDev                 *float64          `yaml:"maxImpactStdDev,omitempty" json:"maxImpactStdDev,omitempty"`
+	ImpactDistribution              *distributionFile `yaml:"impactDistribution,omitempty" json:"impactDistribution,omitempty"`
+	IsCostSaving                    bool              `yaml:"isCostSaving,omitempty" json:"isCostSaving,omitempty"`
+	CostOfImplementationLower       *float64          `yaml:"costOfImplementationLower,omitempty" json:"costOfImplementationLower,omitempty"`
+	CostOfImplementationUpper       *float64          `yaml:"costOfImplementationUpper,omitempty" json:"costOfImplementationUpper,omitempty"`
+	CostOfImplementationLowerStdDev *float64          `yaml:"costOfImplementationLowerStdDev,omitempty" json:"costOfImplementationLowerStdDev,omitempty"`
+	CostOfImplementationUpperStdDev *float64          `yaml:"costOfImplementationUpperStdDev,omitempty" json:"costOfImplementationUpperStdDev,omitempty"`
+	ProbabilityTable                []conditionalFile `yaml:"probabilityTable,omitempty" json:"probabilityTable,omitempty"`
+}
+
+// conditionalFile is a row of a conditional probability table.
+type conditionalFile struct {
+	Given       map[string]bool `yaml:"given" json:"given"`
+	Probability float64         `yaml:"probability" json:"probability"`
+}
+
+// distributionFile is an impact distribution. Lognormal, normal, Pareto and gamma
+// distributions can be given by their parameters or by a 90% confidence interval (lower and
+// upper); they are always saved by their parameters.
+type distributionFile struct {
+	Type  string   `yaml:"type" json:"type"`
+	Min   *float64 `yaml:"min,omitempty" json:"min,omitempty"`
+	Mode  *float64 `yaml:"mode,omitempty" json:"mode,omitempty"`
+	Max   *float64 `yaml:"max,omitempty" json:"max,omitempty"`
+	Mu    *float64 `yaml:"mu,omitempty" json:"mu,omitempty"`
+	Sigma *float64 `yaml:"sigma,omitempty" json:"sigma,omitempty"`
+	Scale *float64 `yaml:"scale,omitempty" json:"scale,omitempty"`
+	Shape *float64 `yaml:"shape,omitempty" json:"shape,omitempty"`
+	Lower *float64 `yaml:"lower,omitempty" json:"lower,omitempty"`
+	Upper *float64 `yaml:"upper,omitempty" json:"upper,omitempty"`
+}
+
+type dependencyFile struct {
+	Event        string          `yaml:"event,omitempty" json:"event,omitempty"`
+	Condition    string          `yaml:"condition,omitempty" json:"condition,omitempty"`
+	IfHappens    *float64        `yaml:"ifHappens,omitempty" json:"ifHappens,omitempty"`
+	IfNotHappens *float64        `yaml:"ifNotHappens,omitempty" json:"ifNotHappens,omitempty"`
+	Requires     *expressionFile `yaml:"requires,omitempty" json:"requires,omitempty"`
+}
+
+// expressionFile is a Requires expression with exactly one of event, and, or, not, or atLeast
+// with of.
+type expressionFile struct {
+	Event   string           `yaml:"event,omitempty" json:"event,omitempty"`
+	And     []expressionFile `yaml:"and,omitempty" json:"and,omitempty"`
+	Or      []expressionFile `yaml:"or,omitempty" json:"or,omitempty"`
+	Not     *expressionFile  `yaml:"not,omitempty" json:"not,omitempty"`
+	AtLeast int              `yaml:"atLeast,omitempty" json:"atLeast,omitempty"`
+	Of      []expressionFile `yaml:"of,omitempty" json:"of,omitempty"`
+}
+
+// LoadModel reads and validates a model file. Files ending in .json are read as JSON, others
+// as YAML. Problems are returned as ModelErrors with their positions in the file.
+func LoadModel(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseModel(data, path)
+}
+
+// ParseModel parses and validates a YAML or JSON model; JSON is read as YAML, which it is a
+// subset of. The file name is only used in errors.
+func ParseModel(data []byte, file string) (*Model, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, ModelErrors{yamlError(file, err)}
+	}
+	if len(root.Content) == 0 {
+		return nil, ModelErrors{{File: file, Message: "empty model"}}
+	}
+
+	var errs ModelErrors
+	var fm modelFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fm); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, ModelErrors{yamlError(file, err)}
+		}
+		// the decoder carries on past type errors, so report all of them
+		for _, message := range typeErr.Errors {
+			errs = append(errs, yamlError(file, errors.New(message)))
+		}
+		return nil, errs
+	}
+
+	positions := newModelPositions(file, root.Content[0])
+	model, errs := fm.model(positions)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return model, nil
+}
+
+// SaveModel writes the model to a file, as JSON if the path ends in .json and as YAML
+// otherwise.
+func SaveModel(path string, model *Model) error {
+	var buf bytes.Buffer
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = model.WriteJSON(&buf)
+	} else {
+		err = model.WriteYAML(&buf)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// WriteYAML writes the model in the YAML model format.
+func (m *Model) WriteYAML(w io.Writer) error {
+	fm, err := newModelFile(m)
+	if err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(fm); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// WriteJSON writes the model in the JSON model format.
+func (m *Model) WriteJSON(w io.Writer) error {
+	fm, err := newModelFile(m)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(fm)
+}
+
+// model converts the file to a Model, validating it as it goes.
+func (fm modelFile) model(positions *modelPositions) (*Model, ModelErrors) {
+	var errs ModelErrors
+	report := func(at position, format string, args ...interface{}) {
+		errs = append(errs, positions.errorAt(at, fmt.Sprintf(format, args...)))
+	}
+
+	model := &Model{Version: fm.Version, Dependencies: make(map[string][]Dependency)}
+	switch {
+	case fm.Version == 0:
+		report(positions.root, "missing version, expected %d", ModelVersion)
+	case fm.Version != ModelVersion:
+		report(positions.value(positions.root, "version"), "unsupported version %d, expected %d", fm.Version, ModelVersion)
+	}
+
+	if fm.Settings != nil {
+		settingsAt := positions.value(positions.root, "settings")
+		settings, settingErrs := fm.Settings.settings()
+		for _, field := range sortedKeys(settingErrs) {
+			report(positions.value(settingsAt, field), "%v", settingErrs[field])
+		}
+		if fm.Settings.Trials < 0 {
+			report(positions.value(settingsAt, "trials"), "trials must not be negative")
+		}
+		if fm.Settings.Workers < 0 {
+			report(positions.value(settingsAt, "workers"), "workers must not be negative")
+		}
+		model.Settings = settings
+	}
+
+	if len(fm.Events) == 0 {
+		report(positions.value(positions.root, "events"), "model has no events")
+	}
+	eventLines := make(map[string]position, len(fm.Events))
+	for i, ef := range fm.Events {
+		at := positions.event(i)
+		event, eventErrs := ef.event()
+		for _, field := range sortedKeys(eventErrs) {
+			report(positions.value(at, field), "event %q: %v", ef.Name, eventErrs[field])
+		}
+		if ef.Name == "" {
+			report(at, "event %d has no name", i+1)
+		} else if first, exists := eventLines[ef.Name]; exists {
+			report(positions.value(at, "name"), "duplicate event %q, first defined on line %d", ef.Name, first.line)
+		} else {
+			eventLines[ef.Name] = at
+		}
+		model.Events = append(model.Events, event)
+	}
+
+	dependenciesAt := positions.value(positions.root, "dependencies")
+	for _, eventName := range sortedKeys(fm.Dependencies) {
+		keyAt := positions.key(dependenciesAt, eventName)
+		if _, exists := eventLines[eventName]; !exists {
+			report(keyAt, "dependencies defined for unknown event %q", eventName)
+		}
+		for i, df := range fm.Dependencies[eventName] {
+			at := positions.item(positions.value(dependenciesAt, eventName), i)
+			dep := Dependency{EventName: df.Event, Condition: df.Condition, IfHappens: df.IfHappens, IfNotHappens: df.IfNotHappens}
+			if df.Requires != nil {
+				requires := df.Requires.expression(positions.value(at, "requires"), positions, func(at position, name string) {
+					if _, exists := eventLines[name]; !exists {
+						report(at, "event %q depends on unknown event %q", eventName, name)
+					}
+				}, report)
+				dep.Requires = &requires
+			} else if _, exists := eventLines[df.Event]; !exists {
+				report(positions.value(at, "event"), "event %q depends on unknown event %q", eventName, df.Event)
+			}
+			if df.Condition != "" && df.Condition != "happens" && df.Condition != "not happens" {
+				report(positions.value(at, "condition"), "unknown condition %q, expected \"happens\" or \"not happens\"", df.Condition)
+			}
+			model.Dependencies[eventName] = append(model.Dependencies[eventName], dep)
+		}
+	}
+
+	if len(errs) == 0 {
+		for _, diagnostic := range Validate(model.Events, model.Dependencies, model.Settings.Horizon).Errors() {
+			if diagnostic.Field == "Dependencies" {
+				report(positions.diagnostic(diagnostic, fm.Events), "%s", diagnostic.Message)
+			} else {
+				report(positions.diagnostic(diagnostic, fm.Events), "event %q: %s", diagnostic.Event, diagnostic.Message)
+			}
+		}
+	}
+	if len(errs) == 0 && model.Settings.Correlation != nil {
+		if _, _, err := resolveCorrelation(*model.Settings.Correlation, model.Events); err != nil {
+			report(positions.value(positions.value(positions.root, "settings"), "correlation"), "%v", err)
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return model, errs
+}
+
+func (sf settingsFile) settings() (ModelSettings, map[string]error) {
+	errs := make(map[string]error)
+	settings := ModelSettings{Trials: sf.Trials, Seed: sf.Seed, Workers: sf.Workers}
+
+	if sf.Horizon != "" {
+		horizon, err := ParseTimeframe(sf.Horizon)
+		if err != nil {
+			errs["horizon"] = err
+		}
+		settings.Horizon = horizon
+	}
+	if sf.Strategy != "" {
+		strategy, err := parseEnum(sf.Strategy, "strategy", StrategyLevels, StrategyJoint)
+		if err != nil {
+			errs["strategy"] = err
+		}
+		settings.Strategy = strategy
+	}
+	if sf.Sampling != "" {
+		sampling, err := parseEnum(sf.Sampling, "probability sampling", SampleMidpoint, SampleUniform, SampleBeta)
+		if err != nil {
+			errs["sampling"] = err
+		}
+		settings.Sampling = sampling
+	}
+	if cf := sf.Correlation; cf != nil {
+		correlation := &Correlation{Events: cf.Events, Matrix: cf.Matrix, DegreesOfFreedom: cf.DegreesOfFreedom, Repair: cf.Repair}
+		var err error
+		if cf.Copula != "" {
+			if correlation.Copula, err = parseEnum(cf.Copula, "copula", CopulaGaussian, CopulaStudentT); err != nil {
+				errs["correlation"] = err
+			}
+		}
+		if cf.Target != "" {
+			if correlation.Target, err = parseEnum(cf.Target, "correlation target", CorrelateOccurrence, CorrelateImpact, CorrelateOccurrenceAndImpact); err != nil {
+				errs["correlation"] = err
+			}
+		}
+		settings.Correlation = correlation
+	}
+	return settings, errs
+}
+
+// event converts the file's event, returning errors by the field they are about.
+func (ef eventFile) event() (Event, map[string]error) {
+	errs := make(map[string]error)
+	event := Event{
+		Name:                            ef.Name,
+		LowerProb:                       ef.LowerProb,
+		UpperProb:                       ef.UpperProb,
+		LowerProbStdDev:                 ef.LowerProbStdDev,
+		UpperProbStdDev:                 ef.UpperProbStdDev,
+		Confidence:                      ef.Confidence,
+		ConfidenceStdDev:                ef.ConfidenceStdDev,
+		AnnualRate:                      ef.AnnualRate,
+		Dispersion:                      ef.Dispersion,
+		MinImpact:                       ef.MinImpact,
+		MaxImpact:                       ef.MaxImpact,
+		MinImpactStdDev:                 ef.MinImpactStdDev,
+		MaxImpactStdDev:                 ef.MaxImpactStdDev,
+		IsCostSaving:                    ef.IsCostSaving,
+		CostOfImplementationLower:       ef.CostOfImplementationLower,
+		CostOfImplementationUpper:       ef.CostOfImplementationUpper,
+		CostOfImplementationLowerStdDev: ef.CostOfImplementationLowerStdDev,
+		CostOfImplementationUpperStdDev: ef.CostOfImplementationUpperStdDev,
+	}
+	for _, row := range ef.ProbabilityTable {
+		event.ProbabilityTable = append(event.ProbabilityTable, ConditionalProbability{Given: row.Given, Probability: row.Probability})
+	}
+
+	if ef.Timeframe == "" {
+		errs["timeframe"] = errors.New("missing timeframe")
+	} else if timeframe, err := ParseTimeframe(ef.Timeframe); err != nil {
+		errs["timeframe"] = err
+	} else {
+		event.Timeframe = timeframe
+	}
+
+	if ef.Frequency != "" {
+		frequency, err := parseEnum(ef.Frequency, "frequency", FrequencyBernoulli, FrequencyPoisson, FrequencyNegativeBinomial)
+		if err != nil {
+			errs["frequency"] = err
+		}
+		event.Frequency = frequency
+	}
+
+	if ef.ImpactDistribution != nil {
+		distribution, err := ef.ImpactDistribution.distribution()
+		if err != nil {
+			errs["impactDistribution"] = err
+		}
+		event.ImpactDistribution = distribution
+	}
+
+	return event, errs
+}
+
+func (df distributionFile) distribution() (Distribution, error) {
+	fromCI := df.Lower != nil || df.Upper != nil
+	need := func(names ...string) error {
+		values := map[string]*float64{"min": df.Min, "mode": df.Mode, "max": df.Max, "mu": df.Mu, "sigma": df.Sigma, "scale": df.Scale, "shape": df.Shape, "lower": df.Lower, "upper": df.Upper}
+		var missing []string
+		for _, name := range names {
+			if values[name] == nil {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%s distribution needs %s", df.Type, strings.Join(missing, " and "))
+		}
+		return nil
+	}
+	fromCIOr := func(params ...string) error {
+		if fromCI {
+			return need("lower", "upper")
+		}
+		return need(params...)
+	}
+
+	switch df.Type {
+	case "uniform":
+		if err := need("min", "max"); err != nil {
+			return nil, err
+		}
+		return Uniform{Min: *df.Min, Max: *df.Max}, nil
+	case "triangular":
+		if err := need("min", "mode", "max"); err != nil {
+			return nil, err
+		}
+		return Triangular{Min: *df.Min, Mode: *df.Mode, Max: *df.Max}, nil
+	case "pert":
+		if err := need("min", "mode", "max"); err != nil {
+			return nil, err
+		}
+		return PERT{Min: *df.Min, Mode: *df.Mode, Max: *df.Max}, nil
+	case "lognormal":
+		if err := fromCIOr("mu", "sigma"); err != nil {
+			return nil, err
+		}
+		if fromCI {
+			return LogNormalFrom90CI(*df.Lower, *df.Upper), nil
+		}
+		return LogNormal{Mu: *df.Mu, Sigma: *df.Sigma}, nil
+	case "normal":
+		if err := fromCIOr("mu", "sigma"); err != nil {
+			return nil, err
+		}
+		if fromCI {
+			return NormalFrom90CI(*df.Lower, *df.Upper), nil
+		}
+		return Normal{Mu: *df.Mu, Sigma: *df.Sigma}, nil
+	case "pareto":
+		if err := fromCIOr("scale", "shape"); err != nil {
+			return nil, err
+		}
+		if fromCI {
+			return ParetoFrom90CI(*df.Lower, *df.Upper), nil
+		}
+		return Pareto{Scale: *df.Scale, Shape: *df.Shape}, nil
+	case "gamma":
+		if err := fromCIOr("shape", "scale"); err != nil {
+			return nil, err
+		}
+		if fromCI {
+			return GammaFrom90CI(*df.Lower, *df.Upper), nil
+		}
+		return Gamma{Shape: *df.Shape, Scale: *df.Scale}, nil
+	case "":
+		return nil, errors.New("distribution has no type")
+	default:
+		return nil, fmt.Errorf("unknown distribution type %q", df.Type)
+	}
+}
+
+// newModelFile converts a model to its file layout. It fails for impact distributions the
+// file format can't describe.
+func newModelFile(m *Model) (modelFile, error) {
+	fm := modelFile{Version: m.Version, Events: make([]eventFile, len(m.Events))}
+	if fm.Version == 0 {
+		fm.Version = ModelVersion
+	}
+
+	if m.Settings != (ModelSettings{}) {
+		fm.Settings = &settingsFile{
+			Trials:   m.Settings.Trials,
+			Seed:     m.Settings.Seed,
+			Workers:  m.Settings.Workers,
+			Strategy: m.Settings.Strategy.String(),
+			Sampling: m.Settings.Sampling.String(),
+		}
+		if m.Settings.Horizon > 0 {
+			fm.Settings.Horizon = m.Settings.Horizon.ISO8601()
+		}
+		if c := m.Settings.Correlation; c != nil {
+			fm.Settings.Correlation = &correlationFile{
+				Copula:           c.Copula.String(),
+				DegreesOfFreedom: c.DegreesOfFreedom,
+				Target:           c.Target.String(),
+				Repair:           c.Repair,
+				Events:           c.Events,
+				Matrix:           c.Matrix,
+			}
+		}
+	}
+
+	for i, event := range m.Events {
+		ef := eventFile{
+			Name:                            event.Name,
+			LowerProb:                       event.LowerProb,
+			UpperProb:                       event.UpperProb,
+			LowerProbStdDev:                 event.LowerProbStdDev,
+			UpperProbStdDev:                 event.UpperProbStdDev,
+			Confidence:                      event.Confidence,
+			ConfidenceStdDev:                event.ConfidenceStdDev,
+			Timeframe:                       event.Timeframe.ISO8601(),
+			AnnualRate:                      event.AnnualRate,
+			Dispersion:                      event.Dispersion,
+			MinImpact:                       event.MinImpact,
+			MaxImpact:                       event.MaxImpact,
+			MinImpactStdDev:                 event.MinImpactStdDev,
+			MaxImpactStdDev:                 event.MaxImpactStdDev,
+			IsCostSaving:                    event.IsCostSaving,
+			CostOfImplementationLower:       event.CostOfImplementationLower,
+			CostOfImplementationUpper:       event.CostOfImplementationUpper,
+			CostOfImplementationLowerStdDev: event.CostOfImplementationLowerStdDev,
+			CostOfImplementationUpperStdDev: event.CostOfImplementationUpperStdDev,
+		}
+		for _, row := range event.ProbabilityTable {
+			ef.ProbabilityTable = append(ef.ProbabilityTable, conditionalFile{Given: row.Given, Probability: row.Probability})
+		}
+		if event.Frequency != FrequencyBernoulli {
+			ef.Frequency = event.Frequency.String()
+		}
+		if event.ImpactDistribution != nil {
+			df, err := newDistributionFile(event.ImpactDistribution)
+			if err != nil {
+				return modelFile{}, fmt.Errorf("event %q: %w", event.Name, err)
+			}
+			ef.ImpactDistribution = &df
+		}
+		fm.Events[i] = ef
+	}
+
+	if len(m.Dependencies) > 0 {
+		fm.Dependencies = make(map[string][]dependencyFile, len(m.Dependencies))
+		for eventName, deps := range m.Dependencies {
+			for _, dep := range deps {
+				df := dependencyFile{Event: dep.EventName, Condition: dep.Condition, IfHappens: dep.IfHappens, IfNotHappens: dep.IfNotHappens}
+				if dep.Requires != nil {
+					requires := newExpressionFile(*dep.Requires)
+					df.Requires = &requires
+				}
+				fm.Dependencies[eventName] = append(fm.Dependencies[eventName], df)
+			}
+		}
+	}
+
+	return fm, nil
+}
+
+// expression converts the file to an Expression, reporting keys that are missing or combined.
+// Every event it names is passed to event with its position.
+func (xf expressionFile) expression(at position, positions *modelPositions, event func(at position, name string), report func(at position, format string, args ...interface{})) Expression {
+	operands := func(key string, files []expressionFile) []Expression {
+		expressions := make([]Expression, len(files))
+		for i, operand := range files {
+			expressions[i] = operand.expression(positions.item(positions.value(at, key), i), positions, event, report)
+		}
+		return expressions
+	}
+
+	var expressions []Expression
+	if xf.Event != "" {
+		event(positions.value(at, "event"), xf.Event)
+		expressions = append(expressions, Happens(xf.Event))
+	}
+	if xf.And != nil {
+		expressions = append(expressions, And(operands("and", xf.And)...))
+	}
+	if xf.Or != nil {
+		expressions = append(expressions, Or(operands("or", xf.Or)...))
+	}
+	if xf.Not != nil {
+		expressions = append(expressions, Not(xf.Not.expression(positions.value(at, "not"), positions, event, report)))
+	}
+	if xf.AtLeast != 0 || xf.Of != nil {
+		expressions = append(expressions, AtLeast(xf.AtLeast, operands("of", xf.Of)...))
+	}
+	if len(expressions) != 1 {
+		report(at, "expression needs exactly one of event, and, or, not, or atLeast with of")
+		return Expression{}
+	}
+	return expressions[0]
+}
+
+// newExpressionFile converts an Expression to its file layout.
+func newExpressionFile(e Expression) expressionFile {
+	operands := make([]expressionFile, len(e.Operands))
+	for i, operand := range e.Operands {
+		operands[i] = newExpressionFile(operand)
+	}
+	switch e.Operator {
+	case ExpressionAnd:
+		return expressionFile{And: operands}
+	case ExpressionOr:
+		return expressionFile{Or: operands}
+	case ExpressionNot:
+		if len(operands) == 1 {
+			return expressionFile{Not: &operands[0]}
+		}
+		return expressionFile{}
+	case ExpressionAtLeast:
+		return expressionFile{AtLeast: e.K, Of: operands}
+	default:
+		return expressionFile{Event: e.Event}
+	}
+}
+
+func newDistributionFile(distribution Distribution) (distributionFile, error) {
+	switch d := distribution.(type) {
+	case Uniform:
+		return distributionFile{Type: "uniform", Min: &d.Min, Max: &d.Max}, nil
+	case Triangular:
+		return distributionFile{Type: "triangular", Min: &d.Min, Mode: &d.Mode, Max: &d.Max}, nil
+	case PERT:
+		return distributionFile{Type: "pert", Min: &d.Min, Mode: &d.Mode, Max: &d.Max}, nil
+	case LogNormal:
+		return distributionFile{Type: "lognormal", Mu: &d.Mu, Sigma: &d.Sigma}, nil
+	case Normal:
+		return distributionFile{Type: "normal", Mu: &d.Mu, Sigma: &d.Sigma}, nil
+	case Pareto:
+		return distributionFile{Type: "pareto", Scale: &d.Scale, Shape: &d.Shape}, nil
+	case Gamma:
+		return distributionFile{Type: "gamma", Shape: &d.Shape, Scale: &d.Scale}, nil
+	default:
+		return distributionFile{}, fmt.Errorf("impact distribution %T can't be saved", distribution)
+	}
+}
+
+// parseEnum finds the value whose String() is name.
+func parseEnum[T fmt.Stringer](name, kind string, values ...T) (T, error) {
+	var known []string
+	for _, value := range values {
+		if value.String() == name {
+			return value, nil
+		}
+		known = append(known, strconv.Quote(value.String()))
+	}
+	var zero T
+	return zero, fmt.Errorf("unknown %s %q, expected one of %s", kind, name, strings.Join(known, ", "))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+var yamlUnknownField = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+var yamlTypeName = regexp.MustCompile(`into (?:\[\])?montecargo\.\w+`)
+
+// yamlError converts a YAML error to a ModelError, extracting its line and making decoder
+// messages refer to the file rather than Go types.
+func yamlError(file string, err error) ModelError {
+	message := err.Error()
+	line := 0
+	if match := yamlLine.FindStringSubmatch(message); match != nil {
+		line, _ = strconv.Atoi(match[1])
+		message = match[2]
+	}
+	if match := yamlUnknownField.FindStringSubmatch(message); match != nil {
+		message = fmt.Sprintf("unknown field %q", match[1])
+	}
+	message = yamlTypeName.ReplaceAllString(message, "into a mapping")
+	return ModelError{File: file, Line: line, Message: message}
+}
+
+// position is a place in a model file. node is the YAML node at the position, if any.
+type position struct {
+	line, column int
+	node         *yaml.Node
+}
+
+// modelPositions finds the positions of parts of a parsed model file.
+type modelPositions struct {
+	file string
+	root position
+}
+
+func newModelPositions(file string, root *yaml.Node) *modelPositions {
+	return &modelPositions{file: file, root: nodePosition(root)}
+}
+
+func nodePosition(node *yaml.Node) position {
+	return position{line: node.Line, column: node.Column, node: node}
+}
+
+func (p *modelPositions) errorAt(at position, message string) ModelError {
+	return ModelError{File: p.file, Line: at.line, Column: at.column, Message: message}
+}
+
+// lookup returns the key and value nodes of a mapping entry.
+func lookup(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// value returns the position of the value of a key, falling back to the parent.
+func (p *modelPositions) value(parent position, key string) position {
+	if _, value := lookup(parent.node, key); value != nil {
+		return nodePosition(value)
+	}
+	return parent
+}
+
+// key returns the position of a key, falling back to the parent.
+func (p *modelPositions) key(parent position, key string) position {
+	if keyNode, _ := lookup(parent.node, key); keyNode != nil {
+		return nodePosition(keyNode)
+	}
+	return parent
+}
+
+// item returns the position of an item of a sequence, falling back to the sequence.
+func (p *modelPositions) item(sequence position, i int) position {
+	if sequence.node != nil && sequence.node.Kind == yaml.SequenceNode && i < len(sequence.node.Content) {
+		return nodePosition(sequence.node.Content[i])
+	}
+	return sequence
+}
+
+func (p *modelPositions) event(i int) position {
+	return p.item(p.value(p.root, "events"), i)
+}
+
+// diagnostic returns the position of the field a diagnostic of Validate is about, falling back
+// to its event.
+func (p *modelPositions) diagnostic(diagnostic Diagnostic, events []eventFile) position {
+	if diagnostic.Field == "Dependencies" {
+		return p.key(p.value(p.root, "dependencies"), diagnostic.Event)
+	}
+	for i, ef := range events {
+		if ef.Name == diagnostic.Event {
+			// the fields of a model file are the fields of Event in camel case
+			key := strings.ToLower(diagnostic.Field[:1]) + diagnostic.Field[1:]
+			return p.value(p.event(i), key)
+		}
+	}
+	return p.root
+}
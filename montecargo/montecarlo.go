@@ -1,25 +1,20 @@
 package montecargo
 
-// MonteCarloSimulation orchestrates the Monte Carlo simulation process.
-func MonteCarloSimulation(events []Event, numSimulations int, dependencies map[string][]Dependency) SimulationResult {
-	// Filter events into independent and dependent categories
-	independentEvents := filterIndependentEvents(events, dependencies)
-	dependentEvents := filterDependentEvents(events, dependencies)
-
-	// Run simulation for independent events
-	independentResults, independentEventStats := simulate(independentEvents, numSimulations, dependencies, make(map[string]EventStat))
-
-	// Run simulation for dependent events
-	// Use the independentEventStats as initial stats for dependent events
-	dependentResults, dependentEventStats := simulateDependent(dependentEvents, numSimulations, dependencies, independentEventStats)
+import "context"
 
-	// Combine results from independent and dependent simulations
-	combinedResults := combineSimulationResults(independentResults, dependentResults)
-
-	// Use the most updated event stats (from dependentEventStats)
-	finalEventStats := combineEventStats(independentEventStats, dependentEventStats)
+// MonteCarloSimulation orchestrates the Monte Carlo simulation process.
+// Events are simulated level by level in dependency order, and each level uses the stats of
+// the levels before it. It returns the Diagnostics of Validate if the events or their
+// dependencies have errors.
+func MonteCarloSimulation(events []Event, numSimulations int, dependencies map[string][]Dependency, opts ...SimulationOption) (SimulationResult, error) {
+	opts = append(opts, WithStrategy(StrategyLevels))
+	return NewSimulator(opts...).Run(context.Background(), events, numSimulations, dependencies)
+}
 
-	// Convert the map of EventResults to a SimulationResult
-	finalResults := SimulationResult{EventResults: combinedResults.EventResults, EventStats: finalEventStats}
-	return finalResults
+// JointMonteCarloSimulation simulates every event in each trial, in dependency order, and
+// evaluates each dependency condition against the outcomes of that same trial.
+// It returns the Diagnostics of Validate if the events or their dependencies have errors.
+func JointMonteCarloSimulation(events []Event, numSimulations int, dependencies map[string][]Dependency, opts ...SimulationOption) (SimulationResult, error) {
+	opts = append(opts, WithStrategy(StrategyJoint))
+	return NewSimulator(opts...).Run(context.Background(), events, numSimulations, dependencies)
 }
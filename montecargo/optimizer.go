@@ -0,0 +1,259 @@
+package montecargo
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+)
+
+// maxExhaustiveControls is the largest number of candidate controls whose every combination is
+// simulated. Larger sets are searched greedily.
+const maxExhaustiveControls = 10
+
+// Objective is the risk measure a portfolio of controls minimises. Like AnalyzeControls, it
+// measures the net loss of each trial: its loss less the savings of cost saving events.
+type Objective struct {
+	// Percentile of the net loss to minimise, e.g. 0.95. Zero minimises the expected net loss.
+	Percentile float64
+}
+
+func (o Objective) measure(result SimulationResult) float64 {
+	distribution := NewLossDistribution(netTrialLosses(result))
+	if o.Percentile <= 0 {
+		return distribution.Mean()
+	}
+	return distribution.Percentile(o.Percentile)
+}
+
+// Portfolio is a set of controls and the risk that remains when they are implemented.
+type Portfolio struct {
+	Controls      []string // Names of the implemented controls, in the order of the events
+	Cost          float64  // Expected implementation cost of the controls, as in ControlAnalysis.ExpectedCost
+	Risk          float64  // Value of the objective with the controls in place
+	RiskReduction float64  // Risk of the baseline without any control less Risk
+	ExpectedLoss  float64  // Mean net loss with the controls in place
+}
+
+// PortfolioOptimization ranks the portfolios of controls that fit a budget.
+type PortfolioOptimization struct {
+	Budget     float64
+	Objective  Objective
+	Exhaustive bool        // Whether every combination of controls within budget was simulated
+	Baseline   Portfolio   // No controls implemented
+	Ranked     []Portfolio // Every simulated portfolio within budget, lowest risk first
+	Frontier   []Portfolio // Portfolios no other is both cheaper and less risky than, cheapest first
+}
+
+// Best returns the portfolio with the lowest risk within budget.
+func (o PortfolioOptimization) Best() Portfolio {
+	if len(o.Ranked) == 0 {
+		return o.Baseline
+	}
+	return o.Ranked[0]
+}
+
+// OptimizeControls picks the cost saving events to implement within a budget so that the
+// objective is as low as possible. Each candidate portfolio is simulated with the other cost
+// saving events switched off, using common random numbers so that portfolios are compared on
+// the same trials. Up to maxExhaustiveControls candidates, every combination within budget is
+// simulated. Beyond that, controls are added greedily by risk reduction per unit of cost.
+func (s *Simulator) OptimizeControls(ctx context.Context, events []Event, numSimulations int, dependencies map[string][]Dependency, budget float64, objective Objective) (PortfolioOptimization, error) {
+	var candidates []int
+	for i, event := range events {
+		if event.IsCostSaving {
+			candidates = append(candidates, i)
+		}
+	}
+
+	paired := s.withCommonRandomNumbers()
+	costs := make([]float64, len(candidates))
+	for k, index := range candidates {
+		costs[k] = expectedImplementationCost(events[index], paired.config.seed, numSimulations)
+	}
+
+	optimizer := &portfolioOptimizer{
+		ctx:            ctx,
+		simulator:      paired,
+		events:         events,
+		numSimulations: numSimulations,
+		dependencies:   dependencies,
+		candidates:     candidates,
+		costs:          costs,
+		objective:      objective,
+		evaluated:      make(map[string]Portfolio),
+	}
+
+	baseline, err := optimizer.evaluate(make([]bool, len(candidates)))
+	if err != nil {
+		return PortfolioOptimization{}, err
+	}
+
+	optimization := PortfolioOptimization{Budget: budget, Objective: objective, Baseline: baseline}
+	if len(candidates) <= maxExhaustiveControls {
+		optimization.Exhaustive = true
+		err = optimizer.searchExhaustively(budget)
+	} else {
+		err = optimizer.searchGreedily(budget)
+	}
+	if err != nil {
+		return PortfolioOptimization{}, err
+	}
+
+	for _, portfolio := range optimizer.evaluated {
+		portfolio.RiskReduction = baseline.Risk - portfolio.Risk
+		optimization.Ranked = append(optimization.Ranked, portfolio)
+	}
+	sort.Slice(optimization.Ranked, func(i, j int) bool {
+		a, b := optimization.Ranked[i], optimization.Ranked[j]
+		if a.Risk != b.Risk {
+			return a.Risk < b.Risk
+		}
+		if a.Cost != b.Cost {
+			return a.Cost < b.Cost
+		}
+		return strings.Join(a.Controls, "\x00") < strings.Join(b.Controls, "\x00")
+	})
+	optimization.Frontier = efficientFrontier(optimization.Ranked)
+
+	return optimization, nil
+}
+
+// portfolioOptimizer simulates portfolios, identified by which candidates they include, and
+// remembers every portfolio it simulated.
+type portfolioOptimizer struct {
+	ctx            context.Context
+	simulator      *Simulator
+	events         []Event
+	numSimulations int
+	dependencies   map[string][]Dependency
+	candidates     []int
+	costs          []float64 // Expected implementation cost of each candidate
+	objective      Objective
+	evaluated      map[string]Portfolio
+}
+
+func (o *portfolioOptimizer) cost(selected []bool) float64 {
+	total := 0.0
+	for k, included := range selected {
+		if included {
+			total += o.costs[k]
+		}
+	}
+	return total
+}
+
+func (o *portfolioOptimizer) evaluate(selected []bool) (Portfolio, error) {
+	key := portfolioKey(selected)
+	if portfolio, exists := o.evaluated[key]; exists {
+		return portfolio, nil
+	}
+
+	events := append([]Event(nil), o.events...)
+	portfolio := Portfolio{Controls: []string{}, Cost: o.cost(selected)}
+	for k, included := range selected {
+		index := o.candidates[k]
+		if included {
+			portfolio.Controls = append(portfolio.Controls, events[index].Name)
+		} else {
+			events[index] = disableEvent(events[index])
+		}
+	}
+
+	result, err := o.simulator.Run(o.ctx, events, o.numSimulations, o.dependencies)
+	if err != nil {
+		return Portfolio{}, err
+	}
+	portfolio.Risk = o.objective.measure(result)
+	portfolio.ExpectedLoss = NewLossDistribution(netTrialLosses(result)).Mean()
+
+	o.evaluated[key] = portfolio
+	return portfolio, nil
+}
+
+// searchExhaustively simulates every combination of candidates that fits the budget.
+func (o *portfolioOptimizer) searchExhaustively(budget float64) error {
+	selected := make([]bool, len(o.candidates))
+	for mask := 0; mask < 1<<uint(len(o.candidates)); mask++ {
+		for k := range selected {
+			selected[k] = mask&(1<<uint(k)) != 0
+		}
+		if o.cost(selected) > budget {
+			continue
+		}
+		if _, err := o.evaluate(selected); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchGreedily starts without controls and keeps adding the affordable control that reduces
+// the risk the most per unit of cost, until no affordable control reduces it further.
+func (o *portfolioOptimizer) searchGreedily(budget float64) error {
+	selected := make([]bool, len(o.candidates))
+	current, err := o.evaluate(selected)
+	if err != nil {
+		return err
+	}
+
+	for {
+		best, bestScore := -1, 0.0
+		var bestPortfolio Portfolio
+		for k := range o.candidates {
+			if selected[k] {
+				continue
+			}
+			selected[k] = true
+			if o.cost(selected) <= budget {
+				portfolio, err := o.evaluate(selected)
+				if err != nil {
+					return err
+				}
+				reduction := current.Risk - portfolio.Risk
+				score := reduction / math.Max(o.costs[k], 1)
+				if reduction > 0 && score > bestScore {
+					best, bestScore, bestPortfolio = k, score, portfolio
+				}
+			}
+			selected[k] = false
+		}
+
+		if best < 0 {
+			return nil
+		}
+		selected[best] = true
+		current = bestPortfolio
+	}
+}
+
+// efficientFrontier returns the portfolios, ranked by risk, that no other portfolio is both
+// cheaper and less risky than, cheapest first.
+func efficientFrontier(ranked []Portfolio) []Portfolio {
+	byCost := append([]Portfolio(nil), ranked...)
+	sort.SliceStable(byCost, func(i, j int) bool {
+		if byCost[i].Cost != byCost[j].Cost {
+			return byCost[i].Cost < byCost[j].Cost
+		}
+		return byCost[i].Risk < byCost[j].Risk
+	})
+
+	var frontier []Portfolio
+	for _, portfolio := range byCost {
+		if len(frontier) == 0 || portfolio.Risk < frontier[len(frontier)-1].Risk {
+			frontier = append(frontier, portfolio)
+		}
+	}
+	return frontier
+}
+
+func portfolioKey(selected []bool) string {
+	key := make([]byte, len(selected))
+	for k, included := range selected {
+		key[k] = '0'
+		if included {
+			key[k] = '1'
+		}
+	}
+	return string(key)
+}
@@ -0,0 +1,165 @@
+package montecargo
+
+import "runtime"
+
+// SimulationOption configures a Simulator.
+type SimulationOption func(*simulationConfig)
+
+// Strategy selects how dependent events are simulated.
+type Strategy int
+
+const (
+	// StrategyLevels simulates events level by level in dependency order. Each level scales the
+	// probability of its events by the aggregate probability of the events they depend on.
+	StrategyLevels Strategy = iota
+	// StrategyJoint simulates every event in each trial and evaluates each dependency against
+	// the outcomes of that same trial.
+	StrategyJoint
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case StrategyLevels:
+		return "levels"
+	case StrategyJoint:
+		return "joint"
+	default:
+		return "unknown strategy"
+	}
+}
+
+// Logger receives diagnostics from simulations as a message and alternating keys and values.
+// A *log/slog.Logger satisfies it. The package never writes to standard output or standard
+// error itself.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// ProgressFunc receives the number of completed trials and the total number of trials of a
+// run. Calls are serialized and completed never decreases.
+type ProgressFunc func(completed, total int)
+
+type simulationConfig struct {
+	seed     int64
+	seeded   bool
+	workers  int
+	progress ProgressFunc
+	strategy Strategy
+	horizon  Timeframe
+	sampling ProbabilitySampling
+
+	commonRandomNumbers bool
+	discardTrialLosses  bool
+	logger              Logger
+	correlation         *Correlation
+}
+
+// WithSeed makes a run reproducible: the same seed, inputs and worker count produce the same
+// SimulationResult. Runs without a seed are seeded from the clock.
+func WithSeed(seed int64) SimulationOption {
+	return func(config *simulationConfig) {
+		config.seed = seed
+		config.seeded = true
+	}
+}
+
+// WithWorkers sets the number of goroutines trials are spread over. It defaults to the number
+// of CPU cores.
+func WithWorkers(workers int) SimulationOption {
+	return func(config *simulationConfig) {
+		config.workers = workers
+	}
+}
+
+// WithProgress registers a callback that is invoked as trials complete.
+func WithProgress(progress ProgressFunc) SimulationOption {
+	return func(config *simulationConfig) {
+		config.progress = progress
+	}
+}
+
+// WithStrategy selects how dependent events are simulated. It defaults to StrategyLevels.
+func WithStrategy(strategy Strategy) SimulationOption {
+	return func(config *simulationConfig) {
+		config.strategy = strategy
+	}
+}
+
+// WithHorizon sets the period a single trial covers. Event probabilities are converted from
+// their own Timeframe to the horizon. It defaults to Yearly, as does a zero horizon.
+func WithHorizon(horizon Timeframe) SimulationOption {
+	return func(config *simulationConfig) {
+		config.horizon = horizon
+	}
+}
+
+// WithProbabilitySampling selects how event probabilities are drawn from their LowerProb and
+// UpperProb range. It defaults to SampleMidpoint.
+func WithProbabilitySampling(sampling ProbabilitySampling) SimulationOption {
+	return func(config *simulationConfig) {
+		config.sampling = sampling
+	}
+}
+
+// WithCommonRandomNumbers gives every event its own random numbers in every trial, derived from
+// the seed, the trial and the event's name. Seeded runs of models that differ in a few events,
+// such as with and without a control, then draw the same numbers for every other event, so
+// their results can be compared trial by trial. Results also no longer depend on the number of
+// workers.
+func WithCommonRandomNumbers() SimulationOption {
+	return func(config *simulationConfig) {
+		config.commonRandomNumbers = true
+	}
+}
+
+// WithoutTrialLosses stops runs from keeping the loss and savings of every trial, which take 16
+// bytes per trial. Results then have no TrialLosses or TrialSavings, so loss reports,
+// percentiles and exports of them are empty; event results and stats are unaffected. Control
+// analyses, optimizations and risk reports keep the trials they compare regardless.
+func WithoutTrialLosses() SimulationOption {
+	return func(config *simulationConfig) {
+		config.discardTrialLosses = true
+	}
+}
+
+// WithLogger sends diagnostics to logger: runs starting and finishing at debug level, and the
+// warnings of Validate and cancelled runs at warn level. Runs log nothing by default.
+func WithLogger(logger Logger) SimulationOption {
+	return func(config *simulationConfig) {
+		config.logger = logger
+	}
+}
+
+// WithCorrelation correlates events through a copula. Every trial draws from the copula with
+// a generator derived from the seed and the trial, so the levels strategy correlates events in
+// different levels too. Runs return ErrInvalidCorrelation for a matrix that doesn't fit the
+// events or, unless Repair is set, isn't positive semi-definite; repairs are logged as warnings.
+func WithCorrelation(correlation Correlation) SimulationOption {
+	return func(config *simulationConfig) {
+		config.correlation = &correlation
+	}
+}
+
+func newSimulationConfig(opts []SimulationOption) simulationConfig {
+	config := simulationConfig{strategy: StrategyLevels, horizon: Yearly, sampling: SampleMidpoint}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.workers < 1 {
+		config.workers = runtime.NumCPU()
+	}
+	if config.horizon == 0 {
+		config.horizon = Yearly
+	}
+	if config.logger == nil {
+		config.logger = discardLogger{}
+	}
+	return config
+}
+
+// discardLogger is the Logger of simulators without one.
+type discardLogger struct{}
+
+func (discardLogger) Debug(string, ...interface{}) {}
+func (discardLogger) Warn(string, ...interface{})  {}
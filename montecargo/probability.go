@@ -0,0 +1,103 @@
+package montecargo
+
+import (
+	"math"
+	"math/rand"
+)
+
+// ProbabilitySampling selects how an event's probability is drawn from its LowerProb and
+// UpperProb range in each trial.
+type ProbabilitySampling int
+
+const (
+	// SampleMidpoint always uses the middle of the range and ignores the standard deviations.
+	SampleMidpoint ProbabilitySampling = iota
+	// SampleUniform perturbs each bound by its standard deviation, widens the range by the
+	// event's Confidence and draws the probability uniformly between the bounds. A range that
+	// widens past 0 or 1 is narrowed on both sides, so the draw stays centred on the range.
+	SampleUniform
+	// SampleBeta draws the probability from a beta distribution whose mean is the middle of the
+	// range and whose spread treats the range as a confidence interval at the event's Confidence
+	// (90% when unset), widened by the standard deviations of the bounds.
+	SampleBeta
+)
+
+func (s ProbabilitySampling) String() string {
+	switch s {
+	case SampleMidpoint:
+		return "midpoint"
+	case SampleUniform:
+		return "uniform"
+	case SampleBeta:
+		return "beta"
+	default:
+		return "unknown probability sampling"
+	}
+}
+
+// sampleProbability draws the event's probability for a trial and converts it to the
+// probability of the event occurring at least once over the simulation horizon.
+func sampleProbability(event Event, sampling ProbabilitySampling, horizon Timeframe, localRand *rand.Rand) float64 {
+	if event.AnnualRate != nil || sampling == SampleMidpoint {
+		return adjustProbabilityForTimeframe(event, horizon)
+	}
+
+	lower, upper := event.LowerProb, event.UpperProb
+	lowerStdDev, upperStdDev := 0.0, 0.0
+	if event.LowerProbStdDev != nil {
+		lowerStdDev = *event.LowerProbStdDev
+	}
+	if event.UpperProbStdDev != nil {
+		upperStdDev = *event.UpperProbStdDev
+	}
+
+	var probability float64
+	switch sampling {
+	case SampleBeta:
+		probability = sampleBetaProbability(lower, upper, lowerStdDev, upperStdDev, confidenceZ(event.Confidence), localRand)
+	default:
+		lower += localRand.NormFloat64() * lowerStdDev
+		upper += localRand.NormFloat64() * upperStdDev
+		if lower > upper {
+			lower, upper = upper, lower
+		}
+		lower, upper = truncateRange(widenRange(lower, upper, event.Confidence))
+		probability = lower + (upper-lower)*localRand.Float64()
+	}
+
+	return ConvertProbability(probability, event.Timeframe, horizon)
+}
+
+// sampleBetaProbability draws from a beta distribution fitted by moments to the range, which is
+// treated as a central confidence interval with the given z-score.
+func sampleBetaProbability(lower, upper, lowerStdDev, upperStdDev, z float64, localRand *rand.Rand) float64 {
+	mean := clampProbability((lower + upper) / 2)
+	rangeStdDev := (upper - lower) / (2 * z)
+	variance := rangeStdDev*rangeStdDev + (lowerStdDev*lowerStdDev+upperStdDev*upperStdDev)/4
+
+	if mean <= 0 || mean >= 1 || variance <= 0 {
+		return mean
+	}
+
+	// a beta distribution can't be wider than mean * (1 - mean)
+	variance = math.Min(variance, mean*(1-mean)*0.999)
+	concentration := mean*(1-mean)/variance - 1
+	x := sampleGamma(mean*concentration, localRand)
+	y := sampleGamma((1-mean)*concentration, localRand)
+	if x+y == 0 {
+		return mean
+	}
+	return x / (x + y)
+}
+
+// truncateRange narrows the range by the same amount on both sides until it fits in [0, 1], so
+// unlike clamping each bound it keeps the middle of the range.
+func truncateRange(lower, upper float64) (float64, float64) {
+	middle := clampProbability((lower + upper) / 2)
+	halfWidth := math.Min((upper-lower)/2, math.Min(middle, 1-middle))
+	return middle - halfWidth, middle + halfWidth
+}
+
+func clampProbability(probability float64) float64 {
+	return math.Max(0, math.Min(1, probability))
+}
@@ -0,0 +1,188 @@
+package montecargo
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed report.html.tmpl
+var reportTemplateText string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"money":   formatAmount,
+	"percent": formatPercent,
+}).Parse(reportTemplateText))
+
+// RiskReport is the content of an HTML risk report: a model, its simulation result, its loss
+// exceedance curve and the analysis of its controls.
+type RiskReport struct {
+	Title    string
+	Model    *Model
+	Result   SimulationResult    // The model simulated with its controls
+	Curve    LossExceedanceCurve // Omitted from the report when it has no points
+	Controls []ControlAnalysis   // Omitted from the report when empty
+	Metadata ExportMetadata
+	Level    float64 // Confidence level of VaR and TVaR, 0.99 when zero
+}
+
+// Report simulates the model with and without its controls, using common random numbers, and
+// analyses each control, gathering everything an HTML risk report shows.
+func (s *Simulator) Report(ctx context.Context, model *Model, numSimulations int, tolerance []TolerancePoint) (RiskReport, error) {
+	paired := s.withCommonRandomNumbers()
+	residual, err := paired.Run(ctx, model.Events, numSimulations, model.Dependencies)
+	if err != nil {
+		return RiskReport{}, err
+	}
+	inherent, err := paired.Run(ctx, WithoutControls(model.Events), numSimulations, model.Dependencies)
+	if err != nil {
+		return RiskReport{}, err
+	}
+	controls, err := paired.AnalyzeControls(ctx, model.Events, numSimulations, model.Dependencies)
+	if err != nil {
+		return RiskReport{}, err
+	}
+	metadata, err := NewExportMetadata(residual, model.Events, model.Dependencies)
+	if err != nil {
+		return RiskReport{}, err
+	}
+
+	return RiskReport{
+		Title:    "Risk Report",
+		Model:    model,
+		Result:   residual,
+		Curve:    NewLossExceedanceCurve(inherent, residual, tolerance, defaultCurvePoints),
+		Controls: controls,
+		Metadata: metadata,
+		Level:    defaultRiskLevel,
+	}, nil
+}
+
+// reportView is what the report template renders.
+type reportView struct {
+	Title      string
+	Horizon    string
+	Loss       LossReport
+	Range      LossRangeExport
+	Tolerance  bool // Whether the curve has a tolerance
+	Exceeds    bool // Whether residual risk exceeds the tolerance
+	Crossings  []ToleranceCrossing
+	Curve      template.HTML
+	Events     []reportEvent
+	Controls   []ControlAnalysis
+	Diagram    template.HTML
+	Metadata   ExportMetadata
+	Settings   ModelSettings
+	Warnings   []TimeframeWarning
+	Incomplete bool
+}
+
+// reportEvent is a row of the events table.
+type reportEvent struct {
+	Name       string
+	CostSaving bool
+	Stat       EventStat
+	Share      float64 // Share of the expected loss of all loss events
+	MinLoss    float64
+	MaxLoss    float64
+	HasRange   bool // Whether the event has a loss range; controls don't
+}
+
+// WriteHTML writes the report as a single HTML file with embedded CSS and inline SVG charts.
+// It loads nothing from the network.
+func (r RiskReport) WriteHTML(w io.Writer) error {
+	if r.Model == nil {
+		return fmt.Errorf("report has no model")
+	}
+	level := r.Level
+	if level == 0 {
+		level = defaultRiskLevel
+	}
+
+	view := reportView{
+		Title:      r.Title,
+		Horizon:    r.Result.Horizon.String(),
+		Loss:       NewLossReport(r.Result, level),
+		Controls:   r.Controls,
+		Metadata:   r.Metadata,
+		Settings:   r.Model.Settings,
+		Warnings:   r.Result.Warnings,
+		Incomplete: r.Result.Incomplete,
+		Crossings:  r.Curve.Crossings,
+		Exceeds:    r.Curve.ExceedsTolerance(),
+		Tolerance:  len(r.Curve.Tolerance) > 0,
+	}
+	if view.Title == "" {
+		view.Title = "Risk Report"
+	}
+
+	minLoss, maxLoss, avgLoss, exceedMin, exceedMax, breakdown := CalculateExpectedLossRange(r.Model.Events, r.Result.EventStats)
+	view.Range = LossRangeExport{MinLoss: minLoss, MaxLoss: maxLoss, AvgLoss: avgLoss, ProbabilityExceedMin: exceedMin, ProbabilityExceedMax: exceedMax}
+
+	total := 0.0
+	for _, event := range r.Model.Events {
+		if !event.IsCostSaving {
+			total += r.Result.EventStats[event.Name].Loss.ExpectedLoss
+		}
+	}
+	for _, event := range r.Model.Events {
+		stat := r.Result.EventStats[event.Name]
+		loss, hasRange := breakdown[event.Name]
+		row := reportEvent{Name: event.Name, CostSaving: event.IsCostSaving, Stat: stat, MinLoss: loss.MinLoss, MaxLoss: loss.MaxLoss, HasRange: hasRange && !event.IsCostSaving}
+		if total > 0 && !event.IsCostSaving {
+			row.Share = stat.Loss.ExpectedLoss / total
+		}
+		view.Events = append(view.Events, row)
+	}
+	// loss events by expected loss, then controls by savings
+	sort.SliceStable(view.Events, func(i, j int) bool {
+		a, b := view.Events[i], view.Events[j]
+		if a.CostSaving != b.CostSaving {
+			return !a.CostSaving
+		}
+		return math.Abs(a.Stat.Loss.ExpectedLoss) > math.Abs(b.Stat.Loss.ExpectedLoss)
+	})
+
+	if len(r.Curve.Points) > 0 {
+		var curve bytes.Buffer
+		if err := r.Curve.WriteSVG(&curve); err != nil {
+			return err
+		}
+		view.Curve = template.HTML(curve.String())
+	}
+	var diagram bytes.Buffer
+	if err := r.Model.WriteSVG(&diagram); err != nil {
+		return err
+	}
+	view.Diagram = template.HTML(diagram.String())
+
+	return reportTemplate.Execute(w, view)
+}
+
+// formatAmount formats an amount in whole units with thousands separators, e.g. $1,234,567.
+func formatAmount(amount float64) string {
+	sign := ""
+	if amount = math.Round(amount); amount < 0 {
+		sign, amount = "-", -amount
+	}
+	digits := strconv.FormatFloat(amount, 'f', 0, 64)
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return sign + "$" + grouped.String()
+}
+
+func formatPercent(fraction float64) string {
+	return strconv.FormatFloat(fraction*100, 'f', 2, 64) + "%"
+}
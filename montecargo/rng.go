@@ -0,0 +1,150 @@
+package montecargo
+
+import (
+	"math/bits"
+	"math/rand"
+)
+
+// xoshiroSource is a xoshiro256** generator. Unlike math/rand's default source it can jump
+// ahead 2^128 draws, which splits one seed into non-overlapping streams.
+type xoshiroSource struct {
+	s [4]uint64
+}
+
+func newXoshiroSource(seed int64) *xoshiroSource {
+	src := &xoshiroSource{}
+	src.Seed(seed)
+	return src
+}
+
+// Seed expands the seed into the generator state with splitmix64, as recommended by the
+// xoshiro authors.
+func (x *xoshiroSource) Seed(seed int64) {
+	state := uint64(seed)
+	for i := range x.s {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		x.s[i] = z ^ (z >> 31)
+	}
+}
+
+func (x *xoshiroSource) Uint64() uint64 {
+	result := bits.RotateLeft64(x.s[1]*5, 7) * 9
+	t := x.s[1] << 17
+
+	x.s[2] ^= x.s[0]
+	x.s[3] ^= x.s[1]
+	x.s[1] ^= x.s[2]
+	x.s[0] ^= x.s[3]
+	x.s[2] ^= t
+	x.s[3] = bits.RotateLeft64(x.s[3], 45)
+
+	return result
+}
+
+func (x *xoshiroSource) Int63() int64 {
+	return int64(x.Uint64() >> 1)
+}
+
+// jump advances the generator by 2^128 draws.
+func (x *xoshiroSource) jump() {
+	jumpPolynomial := [4]uint64{0x180ec6d33cfd0aba, 0xd5a61266f0c9392c, 0xa9582618e03fc9aa, 0x39abdc4529b1661c}
+
+	var s [4]uint64
+	for _, word := range jumpPolynomial {
+		for b := 0; b < 64; b++ {
+			if word&(1<<uint(b)) != 0 {
+				s[0] ^= x.s[0]
+				s[1] ^= x.s[1]
+				s[2] ^= x.s[2]
+				s[3] ^= x.s[3]
+			}
+			x.Uint64()
+		}
+	}
+	x.s = s
+}
+
+// rngStreams hands out independent random number generators derived from a single seed.
+// Streams are handed out in call order, so the same seed and the same sequence of calls
+// always produce the same generators.
+type rngStreams struct {
+	next *xoshiroSource
+}
+
+func newRNGStreams(seed int64) *rngStreams {
+	return &rngStreams{next: newXoshiroSource(seed)}
+}
+
+// Rand returns a generator for the next stream, 2^128 draws after the previous one.
+func (r *rngStreams) Rand() *rand.Rand {
+	stream := *r.next
+	r.next.jump()
+	return rand.New(&stream)
+}
+
+// trialRand hands out the generator each event of a worker draws from in the current trial.
+// Without common random numbers every event draws from the worker's own stream. With them,
+// each event's generator is seeded from the run's seed, the trial's index and the event's name.
+type trialRand struct {
+	worker *rand.Rand
+	common bool
+	seed   uint64
+	keys   []uint64
+	trial  uint64
+	source xoshiroSource
+	event  *rand.Rand
+
+	correlationSource xoshiroSource
+	correlation       *rand.Rand
+}
+
+func newTrialRand(events []Event, firstTrial int, worker *rand.Rand, run *runState) *trialRand {
+	t := &trialRand{worker: worker, common: run.commonRandomNumbers, seed: uint64(run.seed), trial: uint64(firstTrial)}
+	if t.common {
+		t.keys = make([]uint64, len(events))
+		for i, event := range events {
+			t.keys[i] = eventKey(event.Name)
+		}
+		t.event = rand.New(&t.source)
+	}
+	return t
+}
+
+// forEvent returns the generator of the event at the given index in the current trial.
+func (t *trialRand) forEvent(event int) *rand.Rand {
+	if !t.common {
+		return t.worker
+	}
+	t.source.Seed(int64(mix64(mix64(t.seed^t.keys[event]) ^ t.trial)))
+	return t.event
+}
+
+// correlationKey keeps a trial's copula draws apart from the draws of an event.
+var correlationKey = eventKey("montecargo: correlation")
+
+// forCorrelation returns the generator of the current trial's copula draws. It is seeded from
+// the run's seed and the trial's index whether or not common random numbers are on, so every
+// level of a run draws the same correlated uniforms for a trial.
+func (t *trialRand) forCorrelation() *rand.Rand {
+	if t.correlation == nil {
+		t.correlation = rand.New(&t.correlationSource)
+	}
+	t.correlationSource.Seed(int64(mix64(mix64(t.seed^correlationKey) ^ t.trial)))
+	return t.correlation
+}
+
+// nextTrial moves on to the worker's next trial.
+func (t *trialRand) nextTrial() {
+	t.trial++
+}
+
+// mix64 is the splitmix64 finalizer, which scrambles the bits of x.
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
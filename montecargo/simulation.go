@@ -2,153 +2,206 @@ package montecargo
 
 import (
 	"math/rand"
-	"runtime"
 	"sync"
-	"time"
 )
 
-var mutex sync.Mutex
-
 // Simulate events without dependencies
+func simulateEvents(events []Event, firstTrial, trials int, eventStats map[string]EventStat, dependencies map[string][]Dependency, workerRand *rand.Rand, run *runState) SimulationResult {
+	results := make([]EventResult, len(events))
+	recorder := newTrialRecorder(trials, run)
+	rng := newTrialRand(events, firstTrial, workerRand, run)
+	correlated := newCorrelatedTrial(events, run)
+
+	completed := runTrials(trials, run, func() {
+		correlated.draw(rng.forCorrelation())
+		loss, savings := 0.0, 0.0
+		for i, event := range events {
+			localRand := rng.forEvent(i)
+			adjustedProb := sampleProbability(event, run.sampling, run.horizon, localRand)
+			if event.ConfidenceStdDev != nil {
+				adjustedProb = adjustProbabilityWithConfidenceStdDev(adjustedProb, *event.ConfidenceStdDev, localRand)
+			}
 
-func simulateEvents(events []Event, numSimulations int, eventStats map[string]EventStat, dependencies map[string][]Dependency) SimulationResult {
-	localResult := SimulationResult{EventResults: make(map[string]EventResult)}
-	cpuCores := runtime.NumCPU()
-
-	for i := 0; i < cpuCores; i++ {
-		localRand := rand.New(rand.NewSource(time.Now().UnixNano()))
-		for j := 0; j < numSimulations/cpuCores; j++ {
-			for _, event := range events {
-				adjustedProb := adjustProbabilityForTimeframe(event)
-				if event.ConfidenceStdDev != nil {
-					adjustedProb = adjustProbabilityWithConfidenceStdDev(adjustedProb, *event.ConfidenceStdDev, localRand)
-				}
-
-				result := 0
-				if localRand.Float64() < adjustedProb {
-					result = 1
-					impact := calculateImpact(event, adjustedProb, localRand)
-
-					mutex.Lock()
-					eventResult := localResult.EventResults[event.Name]
-					eventResult.Sum += result
-					eventResult.SumOfSquares += float64(result * result)
-					eventResult.ImpactSum += float64(impact)
-					eventResult.ImpactSumOfSquares += float64(impact * impact)
-					localResult.EventResults[event.Name] = eventResult
-					mutex.Unlock()
-				}
+			if occurrences := sampleOccurrences(event, adjustedProb, run.horizon, localRand, correlated.occurrenceQuantile(i)); occurrences > 0 {
+				impact := recordOccurrences(&results[i], occurrences, event, localRand, correlated.impactQuantile(i))
+				loss += trialLoss(event, impact)
+				savings += costSavings(event, impact)
 			}
 		}
-	}
+		recorder.record(loss, savings)
+		rng.nextTrial()
+	})
 
-	return localResult
+	return workerResult(events, results, recorder.losses, recorder.savings, completed)
 }
 
 // Simulate events with dependencies
-func simulateDependentEvents(events []Event, numSimulations int, dependencies map[string][]Dependency, eventStats map[string]EventStat) SimulationResult {
-	localResult := SimulationResult{EventResults: make(map[string]EventResult)}
-	cpuCores := runtime.NumCPU()
-
-	for i := 0; i < cpuCores; i++ {
-		localRand := rand.New(rand.NewSource(time.Now().UnixNano()))
-		for j := 0; j < numSimulations/cpuCores; j++ {
-			for _, event := range events {
-				adjustedProb := adjustProbabilityForTimeframe(event)
-
-				if dependentConditions, exists := dependencies[event.Name]; exists {
-					for _, condition := range dependentConditions {
-						dependencyStats := eventStats[condition.EventName]
-						if condition.Condition == "not happens" {
-							adjustedProb *= (1 - dependencyStats.Probability)
-						} else {
-							adjustedProb *= dependencyStats.Probability
-						}
+func simulateDependentEvents(events []Event, firstTrial, trials int, dependencies map[string][]Dependency, eventStats map[string]EventStat, workerRand *rand.Rand, run *runState) SimulationResult {
+	results := make([]EventResult, len(events))
+	recorder := newTrialRecorder(trials, run)
+	rng := newTrialRand(events, firstTrial, workerRand, run)
+	correlated := newCorrelatedTrial(events, run)
+
+	// the stats of earlier levels don't change, so neither do conditional probabilities or the
+	// probabilities that Requires expressions hold
+	conditionalProbs := make([]*float64, len(events))
+	requiredProbs := make([]float64, len(events))
+	probabilityOf := func(eventName string) float64 { return eventStats[eventName].Probability }
+	for i, event := range events {
+		if table := resolveConditional(event, dependencies[event.Name]); table != nil {
+			probability := table.expectedProbability(event, run.horizon, eventStats)
+			conditionalProbs[i] = &probability
+		}
+		requiredProbs[i] = 1
+		for _, dep := range dependencies[event.Name] {
+			if dep.Requires != nil {
+				requiredProbs[i] *= dep.Requires.probability(probabilityOf)
+			}
+		}
+	}
+
+	completed := runTrials(trials, run, func() {
+		correlated.draw(rng.forCorrelation())
+		loss, savings := 0.0, 0.0
+		for i, event := range events {
+			localRand := rng.forEvent(i)
+			var adjustedProb float64
+			if conditionalProbs[i] != nil {
+				adjustedProb = *conditionalProbs[i]
+			} else {
+				adjustedProb = sampleProbability(event, run.sampling, run.horizon, localRand)
+			}
+			adjustedProb *= requiredProbs[i]
+
+			if dependentConditions, exists := dependencies[event.Name]; exists {
+				for _, condition := range dependentConditions {
+					dependencyStats := eventStats[condition.EventName]
+					switch dependencyCondition(event, condition) {
+					case "not happens":
+						adjustedProb *= (1 - dependencyStats.Probability)
+					case "happens":
+						adjustedProb *= dependencyStats.Probability
 					}
 				}
+			}
 
-				result := 0
-				if localRand.Float64() < adjustedProb {
-					result = 1
-					impact := calculateImpact(event, adjustedProb, localRand)
-
-					mutex.Lock()
-					eventResult := localResult.EventResults[event.Name]
-					eventResult.Sum += result
-					eventResult.SumOfSquares += float64(result * result)
-					eventResult.ImpactSum += float64(impact)
-					eventResult.ImpactSumOfSquares += float64(impact * impact)
-					localResult.EventResults[event.Name] = eventResult
-					mutex.Unlock()
-				}
+			if occurrences := sampleOccurrences(event, adjustedProb, run.horizon, localRand, correlated.occurrenceQuantile(i)); occurrences > 0 {
+				impact := recordOccurrences(&results[i], occurrences, event, localRand, correlated.impactQuantile(i))
+				loss += trialLoss(event, impact)
+				savings += costSavings(event, impact)
 			}
 		}
-	}
+		recorder.record(loss, savings)
+		rng.nextTrial()
+	})
 
-	return localResult
+	return workerResult(events, results, recorder.losses, recorder.savings, completed)
 }
 
-func simulate(events []Event, numSimulations int, dependencies map[string][]Dependency, initialEventStats map[string]EventStat) (SimulationResult, map[string]EventStat) {
-	finalResult := SimulationResult{EventResults: make(map[string]EventResult)}
-	var wg sync.WaitGroup
-	resultsChan := make(chan SimulationResult, runtime.NumCPU())
-
+// simulate runs independent events and returns the private results of every worker, with the
+// stats of the events.
+func simulate(events []Event, numSimulations int, dependencies map[string][]Dependency, initialEventStats map[string]EventStat, streams *rngStreams, run *runState) ([]SimulationResult, map[string]EventStat) {
 	// Use the provided initialEventStats if available; otherwise, create a new map.
 	eventStats := initialEventStats
 	if len(eventStats) == 0 {
 		eventStats = make(map[string]EventStat)
 	}
 
-	for i := 0; i < runtime.NumCPU(); i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			result := simulateEvents(events, numSimulations/runtime.NumCPU(), eventStats, dependencies)
-			resultsChan <- result
-		}()
-	}
+	workerResults := runWorkers(numSimulations, streams, run, func(firstTrial, trials int, localRand *rand.Rand) SimulationResult {
+		return simulateEvents(events, firstTrial, trials, eventStats, dependencies, localRand, run)
+	})
 
-	wg.Wait()
-	close(resultsChan)
+	// Calculate event stats after simulation; every worker records a result for every event
+	eventStats, _ = levelEventStats(workerResults, events)
+	return workerResults, eventStats
+}
 
-	// Aggregate results from all goroutines
-	for result := range resultsChan {
-		for eventName, eventResult := range result.EventResults {
-			finalResult.EventResults[eventName] = aggregateEventResults(finalResult.EventResults[eventName], eventResult)
-		}
-	}
+// simulateDependent runs dependent events given the stats of the events they depend on and
+// returns the private results of every worker, with the stats of the events.
+func simulateDependent(events []Event, numSimulations int, dependencies map[string][]Dependency, updatedEventStats map[string]EventStat, streams *rngStreams, run *runState) ([]SimulationResult, map[string]EventStat) {
+	workerResults := runWorkers(numSimulations, streams, run, func(firstTrial, trials int, localRand *rand.Rand) SimulationResult {
+		return simulateDependentEvents(events, firstTrial, trials, dependencies, updatedEventStats, localRand, run)
+	})
 
-	// Calculate event stats after simulation
-	eventStats = CalculateEventStats(finalResult.EventResults, numSimulations, events)
+	// Update event stats after dependent simulation; every event has a result
+	eventStats, _ := levelEventStats(workerResults, events)
+	return workerResults, eventStats
+}
 
-	return finalResult, eventStats
+// levelEventStats calculates the stats of the events of a level from the results of its workers.
+func levelEventStats(workerResults []SimulationResult, events []Event) (map[string]EventStat, error) {
+	levelResult := SimulationResult{EventResults: mergeEventResults(workerResults)}
+	return CalculateEventStats(levelResult.EventResults, completedTrials(levelResult, events), events)
 }
 
-func simulateDependent(events []Event, numSimulations int, dependencies map[string][]Dependency, updatedEventStats map[string]EventStat) (SimulationResult, map[string]EventStat) {
-	finalResult := SimulationResult{EventResults: make(map[string]EventResult)}
+// runWorkers splits numSimulations trials as evenly as possible over the run's workers and
+// gives each worker its own random number stream and a consecutive range of trials starting at
+// firstTrial. Every worker accumulates into private results, which are returned in worker
+// order so seeded runs merge reproducibly.
+func runWorkers(numSimulations int, streams *rngStreams, run *runState, work func(firstTrial, trials int, localRand *rand.Rand) SimulationResult) []SimulationResult {
 	var wg sync.WaitGroup
-	resultsChan := make(chan SimulationResult, runtime.NumCPU())
+	workerResults := make([]SimulationResult, run.workers)
+
+	firstTrial := 0
+	for i := 0; i < run.workers; i++ {
+		trials := numSimulations / run.workers
+		if i < numSimulations%run.workers {
+			trials++
+		}
 
-	for i := 0; i < runtime.NumCPU(); i++ {
 		wg.Add(1)
-		go func() {
+		go func(i, firstTrial, trials int, localRand *rand.Rand) {
 			defer wg.Done()
-			result := simulateDependentEvents(events, numSimulations/runtime.NumCPU(), dependencies, updatedEventStats)
-			resultsChan <- result
-		}()
+			workerResults[i] = work(firstTrial, trials, localRand)
+		}(i, firstTrial, trials, streams.Rand())
+		firstTrial += trials
 	}
 
 	wg.Wait()
-	close(resultsChan)
+	return workerResults
+}
 
-	// Aggregate results from all goroutines
-	for result := range resultsChan {
-		for eventName, eventResult := range result.EventResults {
-			finalResult.EventResults[eventName] = aggregateEventResults(finalResult.EventResults[eventName], eventResult)
-		}
+// trialRecorder records the loss and savings of every trial a worker runs, unless its run
+// doesn't keep them.
+type trialRecorder struct {
+	losses, savings []float64
+	discard         bool
+}
+
+// newTrialRecorder returns a recorder sized for the worker's trials.
+func newTrialRecorder(trials int, run *runState) *trialRecorder {
+	if run.discardTrialLosses {
+		return &trialRecorder{discard: true}
 	}
+	return &trialRecorder{losses: make([]float64, 0, trials), savings: make([]float64, 0, trials)}
+}
+
+func (r *trialRecorder) record(loss, savings float64) {
+	if r.discard {
+		return
+	}
+	r.losses = append(r.losses, loss)
+	r.savings = append(r.savings, savings)
+}
+
+// runTrials calls trial up to trials times, checking for cancellation and reporting progress
+// every progressInterval trials. It returns the number of trials completed.
+func runTrials(trials int, run *runState, trial func()) int {
+	completed := 0
+	for completed < trials {
+		batch := trials - completed
+		if batch > progressInterval {
+			batch = progressInterval
+		}
+
+		for i := 0; i < batch; i++ {
+			trial()
+		}
+		completed += batch
 
-	// Update event stats after dependent simulation
-	eventStats := CalculateEventStats(finalResult.EventResults, numSimulations, events)
-	return finalResult, eventStats
+		if !run.advance(batch) {
+			break
+		}
+	}
+	return completed
 }
@@ -0,0 +1,179 @@
+package montecargo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is the number of trials a worker runs between checks for cancellation.
+const progressInterval = 1024
+
+// Simulator runs Monte Carlo simulations with a fixed configuration. A Simulator can be
+// reused for any number of runs.
+type Simulator struct {
+	config simulationConfig
+}
+
+// NewSimulator returns a Simulator configured by the given options.
+func NewSimulator(opts ...SimulationOption) *Simulator {
+	return &Simulator{config: newSimulationConfig(opts)}
+}
+
+// Run simulates the events numSimulations times. It refuses to run events that Validate finds
+// errors in and returns those errors as Diagnostics, returns ErrInvalidTrials when
+// numSimulations is below one and ErrInvalidCorrelation for an invalid WithCorrelation. If
+// ctx is cancelled or times out before the run completes, Run stops the workers and returns
+// the results of the trials completed so far, marked as Incomplete, together with the
+// context's error.
+func (s *Simulator) Run(ctx context.Context, events []Event, numSimulations int, dependencies map[string][]Dependency) (SimulationResult, error) {
+	seed := s.config.seed
+	if !s.config.seeded {
+		seed = time.Now().UnixNano()
+	}
+
+	if numSimulations < 1 {
+		return SimulationResult{Seed: seed}, errorf(ErrInvalidTrials, "number of trials %d must be positive", numSimulations)
+	}
+	diagnostics := Validate(events, dependencies, s.config.horizon)
+	if diagnostics.HasErrors() {
+		return SimulationResult{Seed: seed}, diagnostics.Errors()
+	}
+	for _, warning := range diagnostics.Warnings() {
+		s.config.logger.Warn("simulating an event with a warning", "event", warning.Event, "field", warning.Field, "warning", warning.Message)
+	}
+	graph, err := BuildDependencyGraph(events, dependencies)
+	if err != nil {
+		return SimulationResult{Seed: seed}, err
+	}
+	var correlated *correlation
+	if s.config.correlation != nil {
+		var repaired bool
+		correlated, repaired, err = resolveCorrelation(*s.config.correlation, events)
+		if err != nil {
+			return SimulationResult{Seed: seed}, err
+		}
+		if repaired {
+			s.config.logger.Warn("correlation matrix is not positive semi-definite, using the nearest one that is", "events", len(s.config.correlation.Events))
+		}
+	}
+
+	started := time.Now()
+	s.config.logger.Debug("simulation started", "events", len(events), "trials", numSimulations, "strategy", s.config.strategy.String(), "workers", s.config.workers, "seed", seed, "horizon", s.config.horizon.ISO8601())
+
+	run := &runState{ctx: ctx, workers: s.config.workers, progress: s.config.progress, horizon: s.config.horizon, sampling: s.config.sampling, seed: seed, commonRandomNumbers: s.config.commonRandomNumbers, discardTrialLosses: s.config.discardTrialLosses, correlation: correlated}
+	streams := newRNGStreams(seed)
+
+	var result SimulationResult
+	switch s.config.strategy {
+	case StrategyJoint:
+		run.total = int64(numSimulations)
+		results, eventStats := simulateJoint(events, numSimulations, dependencies, graph, streams, run)
+		result = SimulationResult{EventResults: results.EventResults, EventStats: eventStats, CoOccurrences: results.CoOccurrences, TrialLosses: results.TrialLosses, TrialSavings: results.TrialSavings, Trials: completedTrials(results, events)}
+	default:
+		result = s.runLevels(events, numSimulations, dependencies, streams, run)
+	}
+	result.Seed = seed
+	result.Horizon = s.config.horizon
+	result.Warnings = CheckTimeframes(events, s.config.horizon)
+
+	if err := ctx.Err(); err != nil {
+		result.Incomplete = true
+		s.config.logger.Warn("simulation cancelled", "trials", result.Trials, "error", err)
+		return result, err
+	}
+	s.config.logger.Debug("simulation finished", "trials", result.Trials, "duration", time.Since(started))
+	return result, nil
+}
+
+// withCommonRandomNumbers returns a copy of the simulator that uses common random numbers and
+// a fixed seed and keeps every trial's losses, so that its runs can be compared trial by trial.
+func (s *Simulator) withCommonRandomNumbers() *Simulator {
+	paired := &Simulator{config: s.config}
+	paired.config.commonRandomNumbers = true
+	paired.config.discardTrialLosses = false
+	if !paired.config.seeded {
+		paired.config.seed, paired.config.seeded = time.Now().UnixNano(), true
+	}
+	return paired
+}
+
+// runLevels simulates events level by level in dependency order, each level using the stats
+// of the levels before it.
+func (s *Simulator) runLevels(events []Event, numSimulations int, dependencies map[string][]Dependency, streams *rngStreams, run *runState) SimulationResult {
+	levels := dependencyLevels(events, dependencies)
+	run.total = int64(numSimulations * len(levels))
+
+	combinedResults := SimulationResult{EventResults: make(map[string]EventResult)}
+	eventStats := make(map[string]EventStat)
+	var workerTrials []int
+	var workerLosses, workerSavings [][]float64
+
+	for i, levelEvents := range levels {
+		if run.ctx.Err() != nil {
+			// the events of this level weren't simulated in any trial
+			workerTrials, workerLosses, workerSavings = nil, nil, nil
+			break
+		}
+
+		var workerResults []SimulationResult
+		var levelStats map[string]EventStat
+
+		if i == 0 && len(dependencies[levelEvents[0].Name]) == 0 {
+			// Run simulation for independent events
+			workerResults, levelStats = simulate(levelEvents, numSimulations, dependencies, make(map[string]EventStat), streams, run)
+		} else {
+			// Run simulation for dependent events using the stats of every earlier level
+			workerResults, levelStats = simulateDependent(levelEvents, numSimulations, dependencies, eventStats, streams, run)
+		}
+
+		combinedResults = combineSimulationResults(combinedResults, SimulationResult{EventResults: mergeEventResults(workerResults)})
+		eventStats = combineEventStats(eventStats, levelStats)
+		workerTrials = addLevelTrials(workerTrials, workerResults, levelEvents)
+		workerLosses = addLevelTrialLosses(workerLosses, workerResults, func(result SimulationResult) []float64 { return result.TrialLosses })
+		workerSavings = addLevelTrialLosses(workerSavings, workerResults, func(result SimulationResult) []float64 { return result.TrialSavings })
+	}
+
+	trials := 0
+	for _, completed := range workerTrials {
+		trials += completed
+	}
+	return SimulationResult{EventResults: combinedResults.EventResults, EventStats: eventStats, TrialLosses: concatTrialLosses(workerLosses), TrialSavings: concatTrialLosses(workerSavings), Trials: trials}
+}
+
+// runState is shared by the workers of a single run.
+type runState struct {
+	ctx      context.Context
+	workers  int
+	progress ProgressFunc
+	horizon  Timeframe
+	sampling ProbabilitySampling
+	total    int64
+
+	seed                int64
+	commonRandomNumbers bool
+	discardTrialLosses  bool
+	correlation         *correlation
+
+	completed  int64
+	reportMu   sync.Mutex
+	reportedAt int64
+}
+
+// advance records completed trials, reports progress and returns false once the run has been
+// cancelled.
+func (r *runState) advance(trials int) bool {
+	completed := atomic.AddInt64(&r.completed, int64(trials))
+
+	if r.progress != nil && trials > 0 {
+		r.reportMu.Lock()
+		if completed > r.reportedAt {
+			r.reportedAt = completed
+			r.progress(int(completed), int(r.total))
+		}
+		r.reportMu.Unlock()
+	}
+
+	return r.ctx.Err() == nil
+}
@@ -1,68 +1,217 @@
 package montecargo
 
-func ParseTimeframe(input string) Timeframe {
-	switch input {
-	case "daily":
-		return Daily
-	case "weekly":
-		return Weekly
-	case "monthly":
-		return Monthly
-	case "yearly":
-		return Yearly
-	case "2 years":
-		return EveryTwoYears
-	case "5 years":
-		return EveryFiveYears
-	case "10 years":
-		return EveryTenYears
-	default:
-		return Yearly // Default or throw an error
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	iso8601Duration   = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)Y)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)W)?(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+	friendlyTimeframe = regexp.MustCompile(`^(?:(?:once\s+)?(?:every|per|each|a|an)\s+)?(\d+(?:\.\d+)?)?\s*-?\s*(hour|day|week|fortnight|month|quarter|year|decade)s?(?:\s+(?:contract|term|period|cycle|window))?$`)
+)
+
+var timeframeUnits = map[string]Timeframe{
+	"hour":      Timeframe(time.Hour),
+	"day":       Daily,
+	"week":      Weekly,
+	"fortnight": 2 * Weekly,
+	"month":     Monthly,
+	"quarter":   Quarterly,
+	"year":      Yearly,
+	"decade":    EveryTenYears,
+}
+
+var timeframeAdverbs = map[string]Timeframe{
+	"hourly":       Timeframe(time.Hour),
+	"daily":        Daily,
+	"weekly":       Weekly,
+	"fortnightly":  2 * Weekly,
+	"biweekly":     2 * Weekly,
+	"monthly":      Monthly,
+	"quarterly":    Quarterly,
+	"semiannually": Yearly / 2,
+	"yearly":       Yearly,
+	"annually":     Yearly,
+	"annual":       Yearly,
+	"biennially":   EveryTwoYears,
+}
+
+// ParseTimeframe parses a timeframe from an ISO-8601 duration ("P1Y", "P18M", "P1Y6M",
+// "P2W", "PT12H"), an adverb ("daily", "quarterly", "annually") or a friendly description
+// ("18 months", "every 18 months", "per 3-year contract", "10 years"). Years are 365 days
+// and months a twelfth of a year. It returns an error for anything else, including friendly
+// descriptions followed by other words ("1 year please").
+func ParseTimeframe(input string) (Timeframe, error) {
+	normalized := strings.ToLower(strings.TrimSpace(input))
+
+	if tf, ok := timeframeAdverbs[normalized]; ok {
+		return tf, nil
+	}
+
+	if match := iso8601Duration.FindStringSubmatch(strings.ToUpper(normalized)); match != nil && normalized != "p" && !strings.HasSuffix(normalized, "t") {
+		units := []Timeframe{Yearly, Monthly, Weekly, Daily, Timeframe(time.Hour), Timeframe(time.Minute), Timeframe(time.Second)}
+		total := 0.0
+		for i, unit := range units {
+			if match[i+1] == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(match[i+1], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid timeframe %q: %w", input, err)
+			}
+			total += value * float64(unit)
+		}
+		if total <= 0 {
+			return 0, fmt.Errorf("invalid timeframe %q: duration must be positive", input)
+		}
+		return Timeframe(math.Round(total)), nil
+	}
+
+	if match := friendlyTimeframe.FindStringSubmatch(normalized); match != nil {
+		count := 1.0
+		if match[1] != "" {
+			value, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid timeframe %q: %w", input, err)
+			}
+			count = value
+		}
+		if count <= 0 {
+			return 0, fmt.Errorf("invalid timeframe %q: duration must be positive", input)
+		}
+		return Timeframe(math.Round(count * float64(timeframeUnits[match[2]]))), nil
+	}
+
+	return 0, fmt.Errorf("unrecognized timeframe %q", input)
+}
+
+// ISO8601 formats the timeframe as an ISO-8601 duration that ParseTimeframe reads back. A zero
+// Timeframe is a year, as in Years.
+func (tf Timeframe) ISO8601() string {
+	if tf == 0 {
+		tf = Yearly
+	}
+	if tf < 0 {
+		return "P0D"
+	}
+
+	units := []struct {
+		length     Timeframe
+		designator string
+	}{
+		{Yearly, "Y"},
+		{Monthly, "M"},
+		{Weekly, "W"},
+		{Daily, "D"},
+	}
+	for _, unit := range units {
+		if tf%unit.length == 0 {
+			return fmt.Sprintf("P%d%s", int64(tf/unit.length), unit.designator)
+		}
+	}
+
+	return fmt.Sprintf("PT%sS", strconv.FormatFloat(time.Duration(tf).Seconds(), 'f', -1, 64))
+}
+
+func (tf Timeframe) String() string {
+	return TimeframeToString(tf)
+}
+
+// Years returns the length of the timeframe in years. A zero Timeframe is a year long.
+func (tf Timeframe) Years() float64 {
+	if tf == 0 {
+		return 1
 	}
+	return float64(tf) / float64(Yearly)
 }
 
-func adjustProbabilityForTimeframe(event Event) float64 {
+// adjustProbabilityForTimeframe converts the event's probability, stated over its own
+// timeframe, into the probability of it occurring at least once over the simulation horizon.
+func adjustProbabilityForTimeframe(event Event, horizon Timeframe) float64 {
+	if event.AnnualRate != nil {
+		return rateToProbability(*event.AnnualRate * horizon.Years())
+	}
+
 	// Adjust the probability based on the event's timeframe
 	probRange := event.UpperProb - event.LowerProb
 	avgProb := event.LowerProb + probRange/2
 
-	switch event.Timeframe {
-	case Daily:
-		return avgProb / 365
-	case Weekly:
-		return avgProb / 52
-	case Monthly:
-		return avgProb / 12
-	case Yearly:
-		return avgProb
-	case EveryTwoYears:
-		return avgProb * 2
-	case EveryFiveYears:
-		return avgProb * 5
-	case EveryTenYears:
-		return avgProb * 10
-	default:
-		return avgProb
-	}
+	return ConvertProbability(avgProb, event.Timeframe, horizon)
 }
 
-func GetOccurrencesPerYear(timeframe Timeframe) float64 {
-	switch timeframe {
-	case Daily:
-		return 365
-	case Weekly:
-		return 52
-	case Monthly:
-		return 12
-	case Yearly:
+// ConvertProbability converts the probability of an event occurring at least once within one
+// timeframe into the probability of it occurring at least once within another, assuming
+// occurrences are spread evenly over time: 1-(1-p)^(to/from). Converting the result back
+// returns the original probability. Probabilities outside [0, 1] are clamped first.
+func ConvertProbability(probability float64, from, to Timeframe) float64 {
+	probability = math.Max(0, math.Min(1, probability))
+	if probability == 1 {
 		return 1
-	case EveryTwoYears:
-		return 0.5
-	case EveryFiveYears:
-		return 0.2
-	case EveryTenYears:
-		return 0.1
-	default:
-		return 1 // Default to yearly if unknown
 	}
+
+	ratio := to.Years() / from.Years()
+	return -math.Expm1(ratio * math.Log1p(-probability))
+}
+
+// TimeframeWarning flags an event whose probability can't be represented faithfully over the
+// simulation horizon.
+type TimeframeWarning struct {
+	EventName string
+	Message   string
+}
+
+func (w TimeframeWarning) String() string {
+	return fmt.Sprintf("%s: %s", w.EventName, w.Message)
+}
+
+// CheckTimeframes reports the events whose probabilities can't be represented as a probability
+// over the given horizon: values outside [0, 1], certain events that would become certain over
+// any shorter horizon, and events that become indistinguishable from certain over a longer one.
+func CheckTimeframes(events []Event, horizon Timeframe) []TimeframeWarning {
+	var warnings []TimeframeWarning
+	warn := func(event Event, format string, args ...interface{}) {
+		warnings = append(warnings, TimeframeWarning{EventName: event.Name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, event := range events {
+		if event.Timeframe < 0 {
+			warn(event, "timeframe %s is negative", time.Duration(event.Timeframe))
+			continue
+		}
+
+		if event.AnnualRate != nil {
+			if *event.AnnualRate < 0 {
+				warn(event, "annual rate %g is negative", *event.AnnualRate)
+			}
+			continue
+		}
+
+		if event.LowerProb < 0 || event.LowerProb > 1 || event.UpperProb < 0 || event.UpperProb > 1 {
+			warn(event, "probability range [%g, %g] per %s is not a probability; use a Frequency model with an AnnualRate for events that occur more than once per %s",
+				event.LowerProb, event.UpperProb, TimeframeToString(event.Timeframe), TimeframeToString(event.Timeframe))
+			continue
+		}
+
+		avgProb := event.LowerProb + (event.UpperProb-event.LowerProb)/2
+		horizonYears, eventYears := horizon.Years(), event.Timeframe.Years()
+
+		if avgProb == 1 && horizonYears < eventYears {
+			warn(event, "certain to occur within %s, which makes it certain within every %s of the simulation horizon",
+				TimeframeToString(event.Timeframe), TimeframeToString(horizon))
+		} else if avgProb < 1 && ConvertProbability(avgProb, event.Timeframe, horizon) > maxRateProbability {
+			warn(event, "probability %g per %s rounds to certainty over %s; use a Frequency model to count repeated occurrences",
+				avgProb, TimeframeToString(event.Timeframe), TimeframeToString(horizon))
+		}
+	}
+
+	return warnings
+}
+
+// GetOccurrencesPerYear returns how many times the timeframe fits into a year.
+func GetOccurrencesPerYear(timeframe Timeframe) float64 {
+	return 1 / timeframe.Years()
 }
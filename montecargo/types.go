@@ -1,15 +1,20 @@
 package montecargo
 
-type Timeframe int
+import "time"
+
+// Timeframe is a length of time, such as the period an event's probability is stated over.
+// Any duration can be used; a zero Timeframe is treated as Yearly.
+type Timeframe time.Duration
 
 const (
-	Daily Timeframe = iota
-	Weekly
-	Monthly
-	Yearly
-	EveryTwoYears
-	EveryFiveYears
-	EveryTenYears
+	Daily          = Timeframe(24 * time.Hour)
+	Weekly         = 7 * Daily
+	Yearly         = 365 * Daily
+	Monthly        = Yearly / 12
+	Quarterly      = Yearly / 4
+	EveryTwoYears  = 2 * Yearly
+	EveryFiveYears = 5 * Yearly
+	EveryTenYears  = 10 * Yearly
 )
 
 type Event struct {
@@ -18,44 +23,103 @@ type Event struct {
 	UpperProb                       float64
 	LowerProbStdDev                 *float64 // Optional standard deviation for LowerProb
 	UpperProbStdDev                 *float64 // Optional standard deviation for UpperProb
-	Confidence                      float64
-	ConfidenceStdDev                *float64 // Optional standard deviation for Confidence
+	Confidence                      float64  // Optional confidence (0 to 1) that the probability and impact ranges hold the true value; lower values widen them
+	ConfidenceStdDev                *float64 // Optional standard deviation of noise added to the sampled probability
 	Timeframe                       Timeframe
+	Frequency                       Frequency // How many times the event can occur per trial, defaults to at most once
+	AnnualRate                      *float64  // Optional expected number of occurrences per year, replaces LowerProb and UpperProb
+	Dispersion                      *float64  // Optional negative binomial dispersion, lower values mean more variance between years (default 1)
 	Results                         []int
-	MinImpact                       *float64 // Optional minimum financial impact
-	MaxImpact                       *float64 // Optional maximum financial impact
-	MinImpactStdDev                 *float64 // Optional standard deviation for MinImpact
-	MaxImpactStdDev                 *float64 // Optional standard deviation for MaxImpact
+	MinImpact                       *float64     // Optional minimum financial impact
+	MaxImpact                       *float64     // Optional maximum financial impact
+	MinImpactStdDev                 *float64     // Optional standard deviation for MinImpact
+	MaxImpactStdDev                 *float64     // Optional standard deviation for MaxImpact
+	ImpactDistribution              Distribution // Optional impact distribution, defaults to uniform between MinImpact and MaxImpact
 	IsCostSaving                    bool
 	CostOfImplementationLower       *float64 // Optional lower bound of cost of implementation
 	CostOfImplementationUpper       *float64 // Optional upper bound of cost of implementation
 	CostOfImplementationLowerStdDev *float64 // Optional standard deviation for lower bound of cost
 	CostOfImplementationUpperStdDev *float64 // Optional standard deviation for upper bound of cost
+	// Optional probabilities of the event for every combination of outcomes of the events it
+	// depends on without a Condition. They replace LowerProb and UpperProb.
+	ProbabilityTable []ConditionalProbability
 
+	disabled bool // Set by disableEvent, so conditional probabilities don't apply either
 }
 
 type SimulationResult struct {
 	EventResults map[string]EventResult
 	EventStats   map[string]EventStat // Added field to store event statistics
+	// CoOccurrences counts, per event, the trials in which each other event also occurred.
+	// Only populated by joint simulations.
+	CoOccurrences map[string]map[string]int
+	// TrialLosses is the total loss of every loss event in each trial, in trial order. Savings
+	// of cost saving events aren't subtracted. Runs with WithoutTrialLosses leave it and
+	// TrialSavings nil. See NewLossReport.
+	TrialLosses []float64
+	// TrialSavings is the total savings of every cost saving event in each trial, in trial order.
+	TrialSavings []float64
+	Seed         int64              // Seed the run was started with, pass it to WithSeed to reproduce the run
+	Trials       int                // Number of trials completed for every event
+	Incomplete   bool               // Set when the run was cancelled before every trial completed
+	Horizon      Timeframe          // Period a single trial covers
+	Warnings     []TimeframeWarning // Events whose probabilities don't convert cleanly to the horizon
 }
 
 type EventResult struct {
-	Sum                int
-	SumOfSquares       float64
-	ImpactSum          float64
-	ImpactSumOfSquares float64
-	MinCostLowerBound  float64 // Minimum of the lower bound of cost of implementation
-	MaxCostUpperBound  float64 // Maximum of the upper bound of cost of implementation
+	Trials               int // Number of trials the event was simulated in
+	Sum                  int // Number of trials the event occurred in
+	Occurrences          int // Number of times the event occurred, counting repeats within a trial
+	SumOfSquares         float64
+	ImpactSum            float64 // Total loss over the trials the event occurred in
+	ImpactSumOfSquares   float64 // Sum of the squared loss of each trial the event occurred in
+	SeveritySumOfSquares float64 // Sum of the squared loss of each occurrence
 }
 
 type EventStat struct {
 	Probability             float64
 	StdDev                  float64
+	MeanFrequency           float64 // Mean number of occurrences per trial
+	Loss                    LossStats
 	MinCostOfImplementation float64 // Minimum estimated cost of implementation
 	MaxCostOfImplementation float64 // Maximum estimated cost of implementation
 }
 
+// LossStats separates the loss of an event given that it occurred from its expected loss.
+// Losses of cost saving events are negative.
+type LossStats struct {
+	SeverityMean       float64 // Mean loss of a single occurrence
+	SeverityStdDev     float64
+	ConditionalMean    float64 // Mean loss in a trial, given the event occurred in it
+	ConditionalStdDev  float64
+	ExpectedLoss       float64 // Mean loss over all trials, including those without the event
+	ExpectedLossStdDev float64
+}
+
+// Dependency makes an event depend on the outcome of another in the same trial. A Condition is
+// shorthand that only lets the event occur when the other event happens, or doesn't. Without a
+// Condition, IfHappens and IfNotHappens state the probability of the event either way, or the
+// event's ProbabilityTable does; with none of them the Condition is "happens". A dependency with Requires instead of an EventName only lets
+// the event occur when the expression over other events holds.
 type Dependency struct {
-	EventName string
-	Condition string // "happens" or "not happens"
+	EventName    string
+	Condition    string      // "happens" or "not happens", or empty
+	IfHappens    *float64    // Optional probability of the dependent event over its timeframe when EventName happens
+	IfNotHappens *float64    // Optional probability of the dependent event over its timeframe when EventName doesn't happen
+	Requires     *Expression // Optional expression the outcomes of other events must satisfy, replacing EventName
+}
+
+// events returns the names of the events the dependency is on.
+func (d Dependency) events() []string {
+	if d.Requires != nil {
+		return d.Requires.Events()
+	}
+	return []string{d.EventName}
+}
+
+// ConditionalProbability is a row of a conditional probability table: the probability of an
+// event over its timeframe in trials where the events it depends on have the given outcomes.
+type ConditionalProbability struct {
+	Given       map[string]bool // Whether each event of the table happens
+	Probability float64
 }
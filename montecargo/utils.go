@@ -1,42 +1,112 @@
 package montecargo
 
-func collectResults(eventResults map[string]*EventResult, events []Event, resultsChan chan [][3]int) {
-	for batch := range resultsChan {
-		for _, result := range batch {
-			eventIndex := result[0]
-			outcome := result[1]
-			impact := result[2] // Extract impact
-
-			eventName := events[eventIndex].Name // Assuming you can map index to name
-
-			// Initialize EventResult if not already done
-			if _, exists := eventResults[eventName]; !exists {
-				eventResults[eventName] = &EventResult{}
-			}
+import "math/rand"
 
-			// Aggregate results
-			eventResults[eventName].Sum += outcome
-			eventResults[eventName].SumOfSquares += float64(outcome * outcome)
+// recordOccurrences draws the impact of each occurrence of an event in a trial, adds the trial
+// to its result and returns the trial's impact. quantile is the impact value a correlated event
+// drew from the copula, or nil.
+func recordOccurrences(eventResult *EventResult, occurrences int, event Event, localRand *rand.Rand, quantile *float64) float64 {
+	impact, severitySumOfSquares := sampleTrialImpact(event, occurrences, localRand, quantile)
+	eventResult.Sum++
+	eventResult.SumOfSquares++
+	eventResult.Occurrences += occurrences
+	eventResult.ImpactSum += impact
+	eventResult.ImpactSumOfSquares += impact * impact
+	eventResult.SeveritySumOfSquares += severitySumOfSquares
+	return impact
+}
 
-			// Accumulate impact values
-			if outcome == 1 {
-				eventResults[eventName].ImpactSum += float64(impact)
-				eventResults[eventName].ImpactSumOfSquares += float64(impact * impact)
-			}
+// trialLoss returns the loss an event adds to the total loss of a trial. Savings of cost
+// saving events don't offset losses.
+func trialLoss(event Event, impact float64) float64 {
+	if event.IsCostSaving {
+		return 0
+	}
+	return impact
+}
+
+// costSavings returns the savings a cost saving event adds to the total savings of a trial.
+func costSavings(event Event, impact float64) float64 {
+	if !event.IsCostSaving {
+		return 0
+	}
+	return -impact
+}
+
+// workerResult converts a worker's private per-event results, indexed like events, and the
+// total loss and savings of each of its trials into a SimulationResult.
+func workerResult(events []Event, results []EventResult, trialLosses, trialSavings []float64, trials int) SimulationResult {
+	localResult := SimulationResult{EventResults: make(map[string]EventResult, len(events)), TrialLosses: trialLosses, TrialSavings: trialSavings}
+	for i, event := range events {
+		results[i].Trials = trials
+		localResult.EventResults[event.Name] = results[i]
+	}
+	return localResult
+}
+
+// mergeWorkerResults combines the private results of every worker of a run. Workers run
+// consecutive trials, so their trial losses and savings are concatenated in worker order.
+func mergeWorkerResults(workerResults []SimulationResult) SimulationResult {
+	finalResult := SimulationResult{EventResults: mergeEventResults(workerResults)}
+	for _, result := range workerResults {
+		finalResult.TrialLosses = append(finalResult.TrialLosses, result.TrialLosses...)
+		finalResult.TrialSavings = append(finalResult.TrialSavings, result.TrialSavings...)
+		if result.CoOccurrences == nil {
+			continue
+		}
+		if finalResult.CoOccurrences == nil {
+			finalResult.CoOccurrences = make(map[string]map[string]int)
+		}
+		for eventName, pairs := range result.CoOccurrences {
+			finalResult.CoOccurrences[eventName] = aggregateCoOccurrences(finalResult.CoOccurrences[eventName], pairs)
 		}
 	}
+	return finalResult
+}
+
+// mergeEventResults adds up the results of every event over the workers of a run.
+func mergeEventResults(workerResults []SimulationResult) map[string]EventResult {
+	eventResults := make(map[string]EventResult)
+	for _, result := range workerResults {
+		for eventName, eventResult := range result.EventResults {
+			eventResults[eventName] = aggregateEventResults(eventResults[eventName], eventResult)
+		}
+	}
+	return eventResults
+}
+
+// completedTrials returns the number of trials the given events were simulated in.
+func completedTrials(result SimulationResult, events []Event) int {
+	if len(events) == 0 {
+		return 0
+	}
+	return result.EventResults[events[0].Name].Trials
 }
 
 func aggregateEventResults(a, b EventResult) EventResult {
 	// Logic to aggregate two EventResult instances
 	return EventResult{
-		Sum:                a.Sum + b.Sum,
-		SumOfSquares:       a.SumOfSquares + b.SumOfSquares,
-		ImpactSum:          a.ImpactSum + b.ImpactSum,
-		ImpactSumOfSquares: a.ImpactSumOfSquares + b.ImpactSumOfSquares,
+		Trials:               a.Trials + b.Trials,
+		Sum:                  a.Sum + b.Sum,
+		Occurrences:          a.Occurrences + b.Occurrences,
+		SumOfSquares:         a.SumOfSquares + b.SumOfSquares,
+		ImpactSum:            a.ImpactSum + b.ImpactSum,
+		ImpactSumOfSquares:   a.ImpactSumOfSquares + b.ImpactSumOfSquares,
+		SeveritySumOfSquares: a.SeveritySumOfSquares + b.SeveritySumOfSquares,
 	}
 }
 
+func aggregateCoOccurrences(a, b map[string]int) map[string]int {
+	combined := make(map[string]int, len(a))
+	for eventName, count := range a {
+		combined[eventName] = count
+	}
+	for eventName, count := range b {
+		combined[eventName] += count
+	}
+	return combined
+}
+
 func combineSimulationResults(independentResults, dependentResults SimulationResult) SimulationResult {
 	combinedResults := SimulationResult{EventResults: make(map[string]EventResult)}
 	for eventName, eventResult := range independentResults.EventResults {
@@ -52,6 +122,65 @@ func combineSimulationResults(independentResults, dependentResults SimulationRes
 	return combinedResults
 }
 
+// addLevelTrials returns the number of trials each worker has completed in every level so far,
+// given the results of its workers in the next level.
+func addLevelTrials(workerTrials []int, workerResults []SimulationResult, events []Event) []int {
+	if workerTrials == nil {
+		workerTrials = make([]int, len(workerResults))
+		for i, result := range workerResults {
+			workerTrials[i] = completedTrials(result, events)
+		}
+		return workerTrials
+	}
+	for i, result := range workerResults {
+		if completed := completedTrials(result, events); completed < workerTrials[i] {
+			workerTrials[i] = completed
+		}
+	}
+	return workerTrials
+}
+
+// addLevelTrialLosses adds the losses, or savings, of the workers of a level to those of the
+// same workers in the levels before it. Every level splits the trials over the workers in the
+// same way, so each worker's trials line up across levels. A level cancelled partway completes
+// fewer of them, and only the trials completed in every level are kept.
+func addLevelTrialLosses(losses [][]float64, workerResults []SimulationResult, levelLosses func(SimulationResult) []float64) [][]float64 {
+	if losses == nil {
+		losses = make([][]float64, len(workerResults))
+		for i, result := range workerResults {
+			losses[i] = levelLosses(result)
+		}
+		return losses
+	}
+	for i, result := range workerResults {
+		level := levelLosses(result)
+		if len(level) < len(losses[i]) {
+			losses[i] = losses[i][:len(level)]
+		}
+		for trial := range losses[i] {
+			losses[i][trial] += level[trial]
+		}
+	}
+	return losses
+}
+
+// concatTrialLosses joins the losses, or savings, of every worker in trial order. It returns nil
+// when the run didn't keep them.
+func concatTrialLosses(losses [][]float64) []float64 {
+	trials := 0
+	for _, worker := range losses {
+		trials += len(worker)
+	}
+	if trials == 0 {
+		return nil
+	}
+	combined := make([]float64, 0, trials)
+	for _, worker := range losses {
+		combined = append(combined, worker...)
+	}
+	return combined
+}
+
 func combineEventStats(independentStats, dependentStats map[string]EventStat) map[string]EventStat {
 	combinedStats := make(map[string]EventStat)
 
@@ -67,6 +196,8 @@ func combineEventStats(independentStats, dependentStats map[string]EventStat) ma
 			combinedStat := EventStat{
 				Probability:             stat.Probability,
 				StdDev:                  stat.StdDev,
+				MeanFrequency:           stat.MeanFrequency,
+				Loss:                    stat.Loss,
 				MinCostOfImplementation: existingStat.MinCostOfImplementation,
 				MaxCostOfImplementation: existingStat.MaxCostOfImplementation,
 			}
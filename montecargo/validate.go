@@ -0,0 +1,413 @@
+package montecargo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Severity is how serious a Diagnostic is.
+type Severity int
+
+const (
+	// SeverityWarning flags an input that runs but is probably not what was meant.
+	SeverityWarning Severity = iota
+	// SeverityError flags an input that can't be simulated; simulations refuse to run it.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "unknown severity"
+	}
+}
+
+// Diagnostic is a problem with an event or its dependencies.
+type Diagnostic struct {
+	Severity Severity
+	Event    string // Name of the event the problem is about, empty when it isn't about one
+	Field    string // Event field the problem is about, e.g. "LowerProb", or "Dependencies"
+	Message  string
+	Err      error // Sentinel error the problem matches, such as ErrInvalidProbability
+}
+
+func (d Diagnostic) String() string {
+	if d.Event == "" {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Severity, d.Event, d.Message)
+}
+
+// Diagnostics are the problems Validate finds. As an error, they are the errors among them,
+// one per line.
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	var lines []string
+	for _, diagnostic := range d.Errors() {
+		lines = append(lines, diagnostic.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Is reports whether any of the errors matches target, so that errors.Is finds the sentinel
+// errors of the diagnostics.
+func (d Diagnostics) Is(target error) bool {
+	for _, diagnostic := range d.Errors() {
+		if diagnostic.Err != nil && errors.Is(diagnostic.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func (d Diagnostics) HasErrors() bool {
+	return len(d.Errors()) > 0
+}
+
+// Errors returns the diagnostics that are errors.
+func (d Diagnostics) Errors() Diagnostics {
+	return d.filter(SeverityError)
+}
+
+// Warnings returns the diagnostics that are warnings.
+func (d Diagnostics) Warnings() Diagnostics {
+	return d.filter(SeverityWarning)
+}
+
+func (d Diagnostics) filter(severity Severity) Diagnostics {
+	var filtered Diagnostics
+	for _, diagnostic := range d {
+		if diagnostic.Severity == severity {
+			filtered = append(filtered, diagnostic)
+		}
+	}
+	return filtered
+}
+
+// Validate checks events and their dependencies before they are simulated over the given
+// horizon, where a zero horizon is a year like a zero Timeframe. Errors are inputs that can't
+// be simulated: a negative horizon, an unknown Frequency, probabilities outside [0, 1] once
+// stated over the event's timeframe, a LowerProb above its UpperProb, negative impacts, costs,
+// rates, timeframes or standard deviations, an impact bound without the other, impact
+// distribution parameters that can't be sampled, duplicate or missing names, dependencies on
+// unknown events, dependency cycles, incomplete conditional probabilities and malformed
+// Requires expressions. Warnings are the timeframe problems CheckTimeframes finds in otherwise
+// valid events and Pareto impact distributions with an infinite mean.
+func Validate(events []Event, dependencies map[string][]Dependency, horizon Timeframe) Diagnostics {
+	var diagnostics Diagnostics
+	invalid := make(map[string]bool)
+	report := func(event, field string, err error, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Event: event, Field: field, Message: fmt.Sprintf(format, args...), Err: err})
+		invalid[event] = true
+	}
+
+	known := make(map[string]bool, len(events))
+	for i, event := range events {
+		switch {
+		case event.Name == "":
+			report("", "Name", ErrInvalidValue, "event %d has no name", i+1)
+		case known[event.Name]:
+			report(event.Name, "Name", ErrDuplicateEvent, "duplicate event name %q; events are looked up by name, so only the first would be used", event.Name)
+		}
+		known[event.Name] = true
+		eventReport := func(field string, err error, format string, args ...interface{}) {
+			report(event.Name, field, err, format, args...)
+		}
+		validateEvent(event, eventReport)
+		validateConditional(event, dependencies[event.Name], eventReport)
+	}
+
+	dependencyErrors := false
+	for _, eventName := range sortedKeys(dependencies) {
+		if !known[eventName] {
+			report(eventName, "Dependencies", ErrUnknownEvent, "dependencies defined for unknown event %q", eventName)
+			dependencyErrors = true
+		}
+		for _, dep := range dependencies[eventName] {
+			for _, parent := range dep.events() {
+				switch {
+				case parent == eventName:
+					report(eventName, "Dependencies", ErrInvalidDependency, "event %q depends on itself", eventName)
+					dependencyErrors = true
+				case !known[parent]:
+					report(eventName, "Dependencies", ErrUnknownEvent, "event %q depends on unknown event %q", eventName, parent)
+					dependencyErrors = true
+				}
+			}
+		}
+	}
+	if !dependencyErrors {
+		if cycle := findDependencyCycle(events, dependencies); cycle != nil {
+			report(cycle[0], "Dependencies", ErrInvalidDependency, "dependency cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	if horizon == 0 {
+		horizon = Yearly
+	}
+	if horizon < 0 {
+		report("", "Horizon", ErrInvalidValue, "horizon %s is negative", time.Duration(horizon))
+	} else {
+		for _, warning := range CheckTimeframes(events, horizon) {
+			if !invalid[warning.EventName] {
+				diagnostics = append(diagnostics, Diagnostic{Severity: SeverityWarning, Event: warning.EventName, Field: "Timeframe", Message: warning.Message})
+			}
+		}
+	}
+	for _, event := range events {
+		if pareto, ok := event.ImpactDistribution.(Pareto); ok && !invalid[event.Name] && pareto.Shape <= 1 {
+			diagnostics = append(diagnostics, Diagnostic{Severity: SeverityWarning, Event: event.Name, Field: "ImpactDistribution",
+				Message: fmt.Sprintf("Pareto distribution Shape %g is at most 1, so its mean is infinite and expected losses won't converge", pareto.Shape)})
+		}
+	}
+
+	return diagnostics
+}
+
+// validateEvent reports the problems with the values of a single event by field.
+func validateEvent(event Event, report func(field string, err error, format string, args ...interface{})) {
+	nonNegative := func(field string, err error, value *float64) {
+		if value != nil && *value < 0 {
+			report(field, err, "%s %g is negative", field, *value)
+		}
+	}
+
+	if event.Timeframe < 0 {
+		report("Timeframe", ErrInvalidValue, "timeframe %s is negative", time.Duration(event.Timeframe))
+	}
+
+	if event.AnnualRate != nil {
+		nonNegative("AnnualRate", ErrInvalidValue, event.AnnualRate)
+	} else {
+		for _, bound := range []struct {
+			field string
+			value float64
+		}{{"LowerProb", event.LowerProb}, {"UpperProb", event.UpperProb}} {
+			switch {
+			case bound.value < 0:
+				report(bound.field, ErrInvalidProbability, "%s %g is negative", bound.field, bound.value)
+			case bound.value > 1:
+				report(bound.field, ErrInvalidProbability, "%s %g per %s is greater than 1; use a Frequency model with an AnnualRate for events that occur more than once per %s",
+					bound.field, bound.value, TimeframeToString(event.Timeframe), TimeframeToString(event.Timeframe))
+			}
+		}
+		if event.LowerProb > event.UpperProb {
+			report("LowerProb", ErrInvalidProbability, "LowerProb %g is greater than UpperProb %g", event.LowerProb, event.UpperProb)
+		}
+	}
+	switch event.Frequency {
+	case FrequencyBernoulli, FrequencyPoisson, FrequencyNegativeBinomial:
+	default:
+		report("Frequency", ErrInvalidValue, "unknown Frequency %d", int(event.Frequency))
+	}
+	if event.Dispersion != nil && *event.Dispersion <= 0 {
+		report("Dispersion", ErrInvalidValue, "Dispersion %g must be positive", *event.Dispersion)
+	}
+	if event.Confidence < 0 || event.Confidence > 1 {
+		report("Confidence", ErrInvalidValue, "Confidence %g is not between 0 and 1", event.Confidence)
+	}
+
+	nonNegative("LowerProbStdDev", ErrInvalidValue, event.LowerProbStdDev)
+	nonNegative("UpperProbStdDev", ErrInvalidValue, event.UpperProbStdDev)
+	nonNegative("ConfidenceStdDev", ErrInvalidValue, event.ConfidenceStdDev)
+
+	if event.ImpactDistribution == nil {
+		switch {
+		case event.MinImpact != nil && event.MaxImpact == nil:
+			report("MinImpact", ErrInvalidImpact, "MinImpact is set without MaxImpact")
+		case event.MinImpact == nil && event.MaxImpact != nil:
+			report("MaxImpact", ErrInvalidImpact, "MaxImpact is set without MinImpact")
+		case event.MinImpact != nil && *event.MinImpact > *event.MaxImpact:
+			report("MinImpact", ErrInvalidImpact, "MinImpact %g is greater than MaxImpact %g", *event.MinImpact, *event.MaxImpact)
+		}
+	}
+	if event.ImpactDistribution != nil {
+		validateDistribution(event.ImpactDistribution, func(format string, args ...interface{}) {
+			report("ImpactDistribution", ErrInvalidImpact, format, args...)
+		})
+	}
+	// savings are positive amounts too, IsCostSaving makes them negative
+	nonNegative("MinImpact", ErrInvalidImpact, event.MinImpact)
+	nonNegative("MaxImpact", ErrInvalidImpact, event.MaxImpact)
+	nonNegative("MinImpactStdDev", ErrInvalidImpact, event.MinImpactStdDev)
+	nonNegative("MaxImpactStdDev", ErrInvalidImpact, event.MaxImpactStdDev)
+
+	nonNegative("CostOfImplementationLower", ErrInvalidValue, event.CostOfImplementationLower)
+	nonNegative("CostOfImplementationUpper", ErrInvalidValue, event.CostOfImplementationUpper)
+	nonNegative("CostOfImplementationLowerStdDev", ErrInvalidValue, event.CostOfImplementationLowerStdDev)
+	nonNegative("CostOfImplementationUpperStdDev", ErrInvalidValue, event.CostOfImplementationUpperStdDev)
+	if lower, upper := event.CostOfImplementationLower, event.CostOfImplementationUpper; lower != nil && upper != nil && *lower > *upper {
+		report("CostOfImplementationLower", ErrInvalidValue, "CostOfImplementationLower %g is greater than CostOfImplementationUpper %g", *lower, *upper)
+	}
+}
+
+// validateDistribution reports the parameters of the package's impact distributions that can't
+// be sampled, such as a negative Sigma or a Mode outside [Min, Max]. Other implementations of
+// Distribution are left to check themselves.
+func validateDistribution(distribution Distribution, report func(format string, args ...interface{})) {
+	finite := func(name string, values ...float64) bool {
+		for _, value := range values {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				report("%s distribution has a parameter that is not a finite number", name)
+				return false
+			}
+		}
+		return true
+	}
+	ordered := func(name string, min, mode, max float64) {
+		switch {
+		case min > max:
+			report("%s distribution Min %g is greater than Max %g", name, min, max)
+		case mode < min || mode > max:
+			report("%s distribution Mode %g is not between Min %g and Max %g", name, mode, min, max)
+		}
+	}
+
+	switch d := distribution.(type) {
+	case Uniform:
+		if finite("uniform", d.Min, d.Max) && d.Min > d.Max {
+			report("uniform distribution Min %g is greater than Max %g", d.Min, d.Max)
+		}
+	case Triangular:
+		if finite("triangular", d.Min, d.Mode, d.Max) {
+			ordered("triangular", d.Min, d.Mode, d.Max)
+		}
+	case PERT:
+		if finite("PERT", d.Min, d.Mode, d.Max) {
+			ordered("PERT", d.Min, d.Mode, d.Max)
+		}
+	case LogNormal:
+		if finite("lognormal", d.Mu, d.Sigma) && d.Sigma < 0 {
+			report("lognormal distribution Sigma %g is negative", d.Sigma)
+		}
+	case Normal:
+		if finite("normal", d.Mu, d.Sigma) && d.Sigma < 0 {
+			report("normal distribution Sigma %g is negative", d.Sigma)
+		}
+	case Pareto:
+		if finite("Pareto", d.Scale, d.Shape) {
+			if d.Scale <= 0 {
+				report("Pareto distribution Scale %g must be positive", d.Scale)
+			}
+			if d.Shape <= 0 {
+				report("Pareto distribution Shape %g must be positive", d.Shape)
+			}
+		}
+	case Gamma:
+		if finite("gamma", d.Shape, d.Scale) {
+			if d.Shape <= 0 {
+				report("gamma distribution Shape %g must be positive", d.Shape)
+			}
+			if d.Scale <= 0 {
+				report("gamma distribution Scale %g must be positive", d.Scale)
+			}
+		}
+	}
+}
+
+// maxTableParents is the largest number of events a ProbabilityTable can be over.
+const maxTableParents = 16
+
+// validateConditional reports the problems with the dependencies of an event and its
+// conditional probabilities.
+func validateConditional(event Event, dependencies []Dependency, report func(field string, err error, format string, args ...interface{})) {
+	explicit := 0
+	for _, dep := range dependencies {
+		hasProbabilities := dep.IfHappens != nil || dep.IfNotHappens != nil
+		switch {
+		case dep.Requires != nil && (dep.EventName != "" || dep.Condition != "" || hasProbabilities):
+			report("Dependencies", ErrInvalidDependency, "dependency requiring %s also has an event name, a condition or conditional probabilities", dep.Requires)
+		case dep.Requires != nil:
+			validateExpression(*dep.Requires, func(format string, args ...interface{}) {
+				report("Dependencies", ErrInvalidDependency, format, args...)
+			})
+		case dep.Condition != "" && dep.Condition != "happens" && dep.Condition != "not happens":
+			report("Dependencies", ErrInvalidDependency, "unknown condition %q on %q, expected \"happens\" or \"not happens\"", dep.Condition, dep.EventName)
+		case dep.Condition != "" && hasProbabilities:
+			report("Dependencies", ErrInvalidDependency, "dependency on %q has both a condition and conditional probabilities", dep.EventName)
+		case hasProbabilities && (dep.IfHappens == nil || dep.IfNotHappens == nil):
+			report("Dependencies", ErrInvalidDependency, "dependency on %q needs both IfHappens and IfNotHappens", dep.EventName)
+		case hasProbabilities:
+			explicit++
+			for _, probability := range []struct {
+				field string
+				value float64
+			}{{"IfHappens", *dep.IfHappens}, {"IfNotHappens", *dep.IfNotHappens}} {
+				if probability.value < 0 || probability.value > 1 {
+					report("Dependencies", ErrInvalidProbability, "%s %g of the dependency on %q is not between 0 and 1", probability.field, probability.value, dep.EventName)
+				}
+			}
+		}
+	}
+
+	parents := tableParents(dependencies)
+	switch {
+	case explicit > 1:
+		report("Dependencies", ErrInvalidDependency, "%d dependencies have IfHappens and IfNotHappens; use a ProbabilityTable to depend on several events", explicit)
+	case explicit == 1 && len(event.ProbabilityTable) > 0:
+		report("ProbabilityTable", ErrInvalidDependency, "ProbabilityTable can't be combined with a dependency with IfHappens and IfNotHappens")
+	case len(event.ProbabilityTable) > 0:
+		validateProbabilityTable(event.ProbabilityTable, parents, report)
+	}
+	if event.AnnualRate != nil && (explicit > 0 || len(event.ProbabilityTable) > 0) {
+		report("AnnualRate", ErrInvalidValue, "AnnualRate can't be combined with conditional probabilities")
+	}
+}
+
+// validateProbabilityTable reports the problems with a table over the given parents: every
+// combination of their outcomes must have exactly one row.
+func validateProbabilityTable(table []ConditionalProbability, parents []string, report func(field string, err error, format string, args ...interface{})) {
+	switch {
+	case len(parents) == 0:
+		report("ProbabilityTable", ErrInvalidDependency, "ProbabilityTable is over no events; add dependencies on them without a condition")
+		return
+	case len(parents) > maxTableParents:
+		report("ProbabilityTable", ErrInvalidDependency, "ProbabilityTable is over %d events, more than %d", len(parents), maxTableParents)
+		return
+	}
+
+	known := make(map[string]bool, len(parents))
+	for _, parent := range parents {
+		known[parent] = true
+	}
+	lookup := &conditional{parents: parents}
+	rows := make(map[int]int, len(table))
+	for i, row := range table {
+		complete := true
+		for _, name := range sortedKeys(row.Given) {
+			if !known[name] {
+				report("ProbabilityTable", ErrInvalidDependency, "row %d gives the outcome of %q, which the event doesn't depend on without a condition", i+1, name)
+			}
+		}
+		for _, parent := range parents {
+			if _, exists := row.Given[parent]; !exists {
+				report("ProbabilityTable", ErrInvalidDependency, "row %d doesn't give the outcome of %q", i+1, parent)
+				complete = false
+			}
+		}
+		if row.Probability < 0 || row.Probability > 1 {
+			report("ProbabilityTable", ErrInvalidProbability, "row %d has probability %g, which is not between 0 and 1", i+1, row.Probability)
+		}
+		if !complete {
+			continue
+		}
+		index := lookup.outcomeIndex(func(parent int) bool { return row.Given[parents[parent]] })
+		if first, exists := rows[index]; exists {
+			report("ProbabilityTable", ErrInvalidDependency, "row %d repeats the outcomes of row %d", i+1, first+1)
+		} else {
+			rows[index] = i
+		}
+	}
+	if combinations := 1 << len(parents); len(rows) < combinations {
+		report("ProbabilityTable", ErrInvalidDependency, "ProbabilityTable has %d of the %d combinations of outcomes of %s", len(rows), combinations, strings.Join(parents, ", "))
+	}
+}
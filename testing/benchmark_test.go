@@ -0,0 +1,34 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+)
+
+// BenchmarkSimulatorWorkers measures trial throughput as the number of workers grows.
+// Compare the trials/s metric across sub-benchmarks to see how the engine scales.
+func BenchmarkSimulatorWorkers(b *testing.B) {
+	numSimulations := 100_000
+
+	for _, strategy := range []montecargo.Strategy{montecargo.StrategyLevels, montecargo.StrategyJoint} {
+		for _, workers := range []int{1, 2, 4, 8, 16} {
+			name := fmt.Sprintf("strategy=%s/workers=%d", strategy, workers)
+			b.Run(name, func(b *testing.B) {
+				simulator := montecargo.NewSimulator(montecargo.WithSeed(1), montecargo.WithWorkers(workers), montecargo.WithStrategy(strategy))
+
+				b.ResetTimer()
+				started := time.Now()
+				for i := 0; i < b.N; i++ {
+					if _, err := simulator.Run(context.Background(), events, numSimulations, nil); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(numSimulations*b.N)/time.Since(started).Seconds(), "trials/s")
+			})
+		}
+	}
+}
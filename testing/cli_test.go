@@ -0,0 +1,180 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/internal/cli"
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+// runCLI runs the montecargo command and returns its exit code and output.
+func runCLI(args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = cli.Run(args, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func writeModelFile(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCLIInitWritesAValidStarterModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.yaml")
+
+	code, _, _ := runCLI("init", path)
+	assert.Equal(t, cli.ExitOK, code)
+	model, err := montecargo.LoadModel(path)
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, model.Events)
+		assert.NotEmpty(t, model.Dependencies)
+	}
+
+	code, _, stderr := runCLI("init", path)
+	assert.Equal(t, cli.ExitRuntime, code, "init must not overwrite a model without -force")
+	assert.Contains(t, stderr, "already exists")
+
+	jsonPath := filepath.Join(t.TempDir(), "model.json")
+	code, _, _ = runCLI("init", jsonPath)
+	assert.Equal(t, cli.ExitOK, code)
+	_, err = montecargo.LoadModel(jsonPath)
+	assert.NoError(t, err)
+}
+
+func TestCLIExitCodes(t *testing.T) {
+	valid := writeModelFile(t, "breach.yaml", breachModel)
+	invalid := writeModelFile(t, "invalid.yaml", "version: 1\nevents:\n  - name: A\n    lowerProb: 0.1\n    upperProb: 0.2\n    timeframe: P1Y\n    bogus: 1\n")
+
+	code, stdout, _ := runCLI("validate", valid)
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, "ok (3 events, 1 dependencies)")
+
+	code, _, stderr := runCLI("validate", valid, invalid)
+	assert.Equal(t, cli.ExitValidation, code)
+	assert.Contains(t, stderr, "invalid.yaml:7:")
+
+	code, _, _ = runCLI("run", invalid)
+	assert.Equal(t, cli.ExitValidation, code)
+
+	code, _, _ = runCLI("run", filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Equal(t, cli.ExitRuntime, code)
+
+	for _, args := range [][]string{
+		{},
+		{"simulate", valid},
+		{"run"},
+		{"run", valid, valid},
+		{"run", "-format", "xml", valid},
+		{"run", "-trials", "0", valid},
+		{"run", "-horizon", "sometimes", valid},
+		{"run", "-horizon", "P0D", valid},
+	} {
+		code, _, _ = runCLI(args...)
+		assert.Equal(t, cli.ExitUsage, code, "%v", args)
+	}
+
+	code, stdout, _ = runCLI("run", "-h")
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, "-trials")
+}
+
+func TestCLIRunFlagsOverrideModelSettings(t *testing.T) {
+	path := writeModelFile(t, "breach.yaml", breachModel)
+
+	run := func() map[string]interface{} {
+		code, stdout, stderr := runCLI("run", path, "-format", "json", "-trials", "2000", "-seed", "7", "-workers", "3", "-horizon", "P6M")
+		if !assert.Equal(t, cli.ExitOK, code, stderr) {
+			return nil
+		}
+		var output map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(stdout), &output))
+		return output
+	}
+
+	first, second := run(), run()
+	if first == nil || second == nil {
+		return
+	}
+	assert.Equal(t, first["events"], second["events"], "seeded runs must be reproducible")
+	assert.Equal(t, first["loss"], second["loss"], "seeded runs must be reproducible")
+	metadata := first["metadata"].(map[string]interface{})
+	assert.Equal(t, 7.0, metadata["seed"])
+	assert.Equal(t, 2000.0, metadata["trials"])
+	assert.Equal(t, "P6M", metadata["horizon"])
+	assert.Len(t, first["events"], 3)
+}
+
+func TestCLIRunCSVWritesEventsAndPercentiles(t *testing.T) {
+	path := writeModelFile(t, "breach.yaml", breachModel)
+	output := filepath.Join(t.TempDir(), "results.csv")
+
+	code, _, _ := runCLI("run", "-format", "csv", "-trials", "1000", path)
+	assert.Equal(t, cli.ExitUsage, code, "csv output needs -o")
+
+	code, _, stderr := runCLI("run", "-format", "csv", "-trials", "1000", "-o", output, path)
+	assert.Equal(t, cli.ExitOK, code, stderr)
+	assert.FileExists(t, output)
+	assert.FileExists(t, filepath.Join(filepath.Dir(output), "results-percentiles.csv"))
+}
+
+func TestCLICompareIdenticalModels(t *testing.T) {
+	baseline := writeModelFile(t, "baseline.yaml", breachModel)
+	candidate := writeModelFile(t, "candidate.yaml", breachModel)
+
+	code, stdout, stderr := runCLI("compare", "-format", "json", "-trials", "2000", baseline, candidate)
+	if !assert.Equal(t, cli.ExitOK, code, stderr) {
+		return
+	}
+
+	var output struct {
+		Loss struct {
+			Baseline struct{ Mean float64 }
+			Model    struct{ Mean float64 }
+		}
+	}
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &output))
+	assert.Greater(t, output.Loss.Baseline.Mean, 0.0)
+	assert.Equal(t, output.Loss.Baseline.Mean, output.Loss.Model.Mean, "both models run with the same random numbers")
+}
+
+func TestCLIGraph(t *testing.T) {
+	path := writeModelFile(t, "breach.yaml", breachModel)
+
+	code, stdout, _ := runCLI("graph", path)
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, `"Network-Level Breach Detected" -> "Data Breach" [label="not happens", style=dashed];`)
+
+	code, stdout, _ = runCLI("graph", "-format", "mermaid", path)
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, `e2 -.->|"not happens"| e0`)
+
+	code, stdout, _ = runCLI("graph", "-format", "svg", path)
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, "<svg")
+}
+
+func TestCLIReportHTML(t *testing.T) {
+	path := writeModelFile(t, "breach.yaml", breachModel)
+	output := filepath.Join(t.TempDir(), "report.html")
+
+	code, _, stderr := runCLI("report", "-format", "html", "-trials", "2000", "-tolerance", "1e6:0.1,1e7:0.01", "-title", "Breach <Q3>", "-o", output, path)
+	if !assert.Equal(t, cli.ExitOK, code, stderr) {
+		return
+	}
+	html, err := os.ReadFile(output)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(html), "<title>Breach &lt;Q3&gt;</title>")
+		assert.Contains(t, string(html), ">Tolerance</text>")
+	}
+
+	code, _, _ = runCLI("report", "-format", "html", "-tolerance", "1e6", path)
+	assert.Equal(t, cli.ExitUsage, code)
+}
@@ -0,0 +1,272 @@
+package testing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/bcdannyboy/montecargo/testing/testing_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var conditionalEvents = []montecargo.Event{
+	{Name: "Data Breach", LowerProb: 0.3, UpperProb: 0.3, Timeframe: montecargo.Yearly},
+	{Name: "Ransomware Attack", Timeframe: montecargo.Yearly},
+}
+
+// a breach makes ransomware six times as likely
+var conditionalDependencies = map[string][]montecargo.Dependency{
+	"Ransomware Attack": {
+		{EventName: "Data Breach", IfHappens: testing_utils.Float64Pointer(0.6), IfNotHappens: testing_utils.Float64Pointer(0.1)},
+	},
+}
+
+func TestConditionalDependencyRaisesProbability(t *testing.T) {
+	numSimulations := 100_000
+	result, err := montecargo.JointMonteCarloSimulation(conditionalEvents, numSimulations, conditionalDependencies, montecargo.WithSeed(23))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	breach := result.EventResults["Data Breach"]
+	ransomware := result.EventResults["Ransomware Attack"]
+	both := result.CoOccurrences["Ransomware Attack"]["Data Breach"]
+
+	// P(ransomware) = 0.3 * 0.6 + 0.7 * 0.1
+	assert.InDelta(t, 0.25, float64(ransomware.Sum)/float64(numSimulations), 0.01)
+	assert.InDelta(t, 0.6, float64(both)/float64(breach.Sum), 0.015)
+	assert.InDelta(t, 0.1, float64(ransomware.Sum-both)/float64(numSimulations-breach.Sum), 0.01)
+
+	// the levels strategy mixes the conditional probabilities by the probability of the breach
+	levels, err := montecargo.MonteCarloSimulation(conditionalEvents, numSimulations, conditionalDependencies, montecargo.WithSeed(23))
+	if assert.NoError(t, err) {
+		assert.InDelta(t, 0.25, levels.EventStats["Ransomware Attack"].Probability, 0.01)
+	}
+}
+
+func TestDependencyWithoutConditionHappens(t *testing.T) {
+	events := []montecargo.Event{
+		{Name: "Data Breach", LowerProb: 0.3, UpperProb: 0.3, Timeframe: montecargo.Yearly},
+		{Name: "Ransomware Attack", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+	}
+	bare := map[string][]montecargo.Dependency{"Ransomware Attack": {{EventName: "Data Breach"}}}
+	happens := map[string][]montecargo.Dependency{"Ransomware Attack": {{EventName: "Data Breach", Condition: "happens"}}}
+
+	assert.Empty(t, montecargo.Validate(events, bare, montecargo.Yearly))
+	for _, simulate := range []func([]montecargo.Event, int, map[string][]montecargo.Dependency, ...montecargo.SimulationOption) (montecargo.SimulationResult, error){
+		montecargo.MonteCarloSimulation, montecargo.JointMonteCarloSimulation,
+	} {
+		withoutCondition, err := simulate(events, 20_000, bare, montecargo.WithSeed(29))
+		assert.NoError(t, err)
+		withCondition, err := simulate(events, 20_000, happens, montecargo.WithSeed(29))
+		assert.NoError(t, err)
+		assert.Equal(t, withCondition.EventResults, withoutCondition.EventResults)
+		assert.InDelta(t, 0.15, withoutCondition.EventStats["Ransomware Attack"].Probability, 0.01)
+	}
+}
+
+func TestProbabilityTable(t *testing.T) {
+	events := []montecargo.Event{
+		{Name: "Phishing", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+		{Name: "Unpatched Server", LowerProb: 0.4, UpperProb: 0.4, Timeframe: montecargo.Yearly},
+		{
+			Name:      "Data Breach",
+			Timeframe: montecargo.Yearly,
+			ProbabilityTable: []montecargo.ConditionalProbability{
+				{Given: map[string]bool{"Phishing": true, "Unpatched Server": true}, Probability: 0.9},
+				{Given: map[string]bool{"Phishing": true, "Unpatched Server": false}, Probability: 0.5},
+				{Given: map[string]bool{"Phishing": false, "Unpatched Server": true}, Probability: 0.3},
+				{Given: map[string]bool{"Phishing": false, "Unpatched Server": false}, Probability: 0.05},
+			},
+		},
+	}
+	dependencies := map[string][]montecargo.Dependency{
+		"Data Breach": {{EventName: "Unpatched Server"}, {EventName: "Phishing"}},
+	}
+
+	numSimulations := 100_000
+	result, err := montecargo.JointMonteCarloSimulation(events, numSimulations, dependencies, montecargo.WithSeed(7))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 0.2 * 0.9 + 0.3 * 0.5 + 0.2 * 0.3 + 0.3 * 0.05
+	assert.InDelta(t, 0.405, float64(result.EventResults["Data Breach"].Sum)/float64(numSimulations), 0.01)
+	// 0.2 * 0.9 + 0.3 * 0.5
+	assert.InDelta(t, 0.33, float64(result.CoOccurrences["Data Breach"]["Phishing"])/float64(numSimulations), 0.01)
+
+	levels, err := montecargo.MonteCarloSimulation(events, numSimulations, dependencies, montecargo.WithSeed(7))
+	if assert.NoError(t, err) {
+		assert.InDelta(t, 0.405, levels.EventStats["Data Breach"].Probability, 0.01)
+	}
+}
+
+func TestValidateConditionalProbabilities(t *testing.T) {
+	parents := []montecargo.Event{
+		{Name: "Phishing", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+		{Name: "Unpatched Server", LowerProb: 0.4, UpperProb: 0.4, Timeframe: montecargo.Yearly},
+	}
+	rows := func(probabilities ...float64) []montecargo.ConditionalProbability {
+		table := make([]montecargo.ConditionalProbability, len(probabilities))
+		for i, probability := range probabilities {
+			table[i] = montecargo.ConditionalProbability{Given: map[string]bool{"Phishing": i&1 != 0, "Unpatched Server": i&2 != 0}, Probability: probability}
+		}
+		return table
+	}
+	bothParents := []montecargo.Dependency{{EventName: "Phishing"}, {EventName: "Unpatched Server"}}
+
+	tests := []struct {
+		name         string
+		table        []montecargo.ConditionalProbability
+		dependencies []montecargo.Dependency
+		field        string
+		err          error
+		contains     string
+	}{
+		{
+			name:         "only one conditional probability",
+			dependencies: []montecargo.Dependency{{EventName: "Phishing", IfHappens: testing_utils.Float64Pointer(0.5)}},
+			field:        "Dependencies",
+			err:          montecargo.ErrInvalidDependency,
+			contains:     `dependency on "Phishing" needs both IfHappens and IfNotHappens`,
+		},
+		{
+			name:         "conditional probability above 1",
+			dependencies: []montecargo.Dependency{{EventName: "Phishing", IfHappens: testing_utils.Float64Pointer(1.5), IfNotHappens: testing_utils.Float64Pointer(0.1)}},
+			field:        "Dependencies",
+			err:          montecargo.ErrInvalidProbability,
+			contains:     "IfHappens 1.5 of the dependency on \"Phishing\" is not between 0 and 1",
+		},
+		{
+			name:         "condition and conditional probabilities",
+			dependencies: []montecargo.Dependency{{EventName: "Phishing", Condition: "happens", IfHappens: testing_utils.Float64Pointer(0.5), IfNotHappens: testing_utils.Float64Pointer(0.1)}},
+			field:        "Dependencies",
+			err:          montecargo.ErrInvalidDependency,
+			contains:     "has both a condition and conditional probabilities",
+		},
+		{
+			name:         "missing combination",
+			table:        rows(0.05, 0.5, 0.3),
+			dependencies: bothParents,
+			field:        "ProbabilityTable",
+			err:          montecargo.ErrInvalidDependency,
+			contains:     "ProbabilityTable has 3 of the 4 combinations of outcomes of Phishing, Unpatched Server",
+		},
+		{
+			name:         "probability above 1",
+			table:        rows(0.05, 0.5, 0.3, 1.2),
+			dependencies: bothParents,
+			field:        "ProbabilityTable",
+			err:          montecargo.ErrInvalidProbability,
+			contains:     "row 4 has probability 1.2",
+		},
+		{
+			name:         "row over an event that isn't a parent",
+			table:        append(rows(0.05, 0.5, 0.3, 0.9)[:3], montecargo.ConditionalProbability{Given: map[string]bool{"Phishing": true, "Unpatched Server": true, "Outage": true}, Probability: 0.9}),
+			dependencies: bothParents,
+			field:        "ProbabilityTable",
+			err:          montecargo.ErrInvalidDependency,
+			contains:     `row 4 gives the outcome of "Outage"`,
+		},
+		{
+			name:         "repeated row",
+			table:        append(rows(0.05, 0.5, 0.3, 0.9), rows(0.1)...),
+			dependencies: bothParents,
+			field:        "ProbabilityTable",
+			err:          montecargo.ErrInvalidDependency,
+			contains:     "row 5 repeats the outcomes of row 1",
+		},
+		{
+			name:         "table and conditional probabilities",
+			table:        rows(0.05, 0.5, 0.3, 0.9),
+			dependencies: append([]montecargo.Dependency{{EventName: "Unpatched Server", IfHappens: testing_utils.Float64Pointer(0.5), IfNotHappens: testing_utils.Float64Pointer(0.1)}}, bothParents[0]),
+			field:        "ProbabilityTable",
+			err:          montecargo.ErrInvalidDependency,
+			contains:     "can't be combined with a dependency with IfHappens and IfNotHappens",
+		},
+	}
+
+	valid := montecargo.Event{Name: "Data Breach", Timeframe: montecargo.Yearly, ProbabilityTable: rows(0.05, 0.5, 0.3, 0.9)}
+	assert.Empty(t, montecargo.Validate(append(parents, valid), map[string][]montecargo.Dependency{"Data Breach": bothParents}, montecargo.Yearly))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := append(append([]montecargo.Event{}, parents...), montecargo.Event{Name: "Data Breach", Timeframe: montecargo.Yearly, ProbabilityTable: tt.table})
+			diagnostics := montecargo.Validate(events, map[string][]montecargo.Dependency{"Data Breach": tt.dependencies}, montecargo.Yearly)
+			if assert.True(t, diagnostics.HasErrors()) {
+				first := diagnostics.Errors()[0]
+				assert.Equal(t, "Data Breach", first.Event)
+				assert.Equal(t, tt.field, first.Field)
+				assert.ErrorIs(t, first.Err, tt.err)
+				assert.Contains(t, first.Message, tt.contains)
+			}
+		})
+	}
+}
+
+func TestConditionalModelFile(t *testing.T) {
+	model := `version: 1
+events:
+  - name: Data Breach
+    lowerProb: 0.3
+    upperProb: 0.3
+    timeframe: P1Y
+  - name: Ransomware Attack
+    timeframe: P1Y
+  - name: Extortion
+    timeframe: P1Y
+    probabilityTable:
+      - given: {Ransomware Attack: true}
+        probability: 0.7
+      - given: {Ransomware Attack: false}
+        probability: 0.02
+dependencies:
+  Ransomware Attack:
+    - event: Data Breach
+      ifHappens: 0.6
+      ifNotHappens: 0.1
+  Extortion:
+    - event: Ransomware Attack
+`
+	parsed, err := montecargo.ParseModel([]byte(model), "conditional.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, conditionalDependencies["Ransomware Attack"], parsed.Dependencies["Ransomware Attack"])
+	assert.Equal(t, []montecargo.ConditionalProbability{
+		{Given: map[string]bool{"Ransomware Attack": true}, Probability: 0.7},
+		{Given: map[string]bool{"Ransomware Attack": false}, Probability: 0.02},
+	}, parsed.Events[2].ProbabilityTable)
+
+	var yamlOutput bytes.Buffer
+	assert.NoError(t, parsed.WriteYAML(&yamlOutput))
+	fromYAML, err := montecargo.ParseModel(yamlOutput.Bytes(), "saved.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, parsed, fromYAML)
+
+	var dot bytes.Buffer
+	assert.NoError(t, parsed.WriteDOT(&dot))
+	assert.Contains(t, dot.String(), `"Data Breach" -> "Ransomware Attack" [label="0.6 if happens, 0.1 if not"];`)
+	assert.Contains(t, dot.String(), `"Ransomware Attack" -> "Extortion" [label="probability table"];`)
+
+	_, err = montecargo.ParseModel([]byte(model[:len(model)-len("    - event: Ransomware Attack\n")]+"    - event: Data Breach\n"), "model.yaml")
+	var modelErrs montecargo.ModelErrors
+	if assert.ErrorAs(t, err, &modelErrs) {
+		assert.Contains(t, modelErrs.Error(), `model.yaml:12:7: event "Extortion": row 1 gives the outcome of "Ransomware Attack"`)
+	}
+}
+
+func TestWithoutControlsDisablesConditionalProbabilities(t *testing.T) {
+	events := []montecargo.Event{
+		{Name: "Phishing", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+		{Name: "Email Filtering", Timeframe: montecargo.Yearly, IsCostSaving: true},
+	}
+	dependencies := map[string][]montecargo.Dependency{
+		"Email Filtering": {{EventName: "Phishing", IfHappens: testing_utils.Float64Pointer(0.9), IfNotHappens: testing_utils.Float64Pointer(0.9)}},
+	}
+
+	result, err := montecargo.JointMonteCarloSimulation(montecargo.WithoutControls(events), 1_000, dependencies, montecargo.WithSeed(3))
+	if assert.NoError(t, err) {
+		assert.Zero(t, result.EventResults["Email Filtering"].Sum)
+	}
+}
@@ -0,0 +1,57 @@
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeControls(t *testing.T) {
+	simulator := montecargo.NewSimulator(montecargo.WithSeed(31), montecargo.WithStrategy(montecargo.StrategyJoint))
+	analyses, err := simulator.AnalyzeControls(context.Background(), controlledEvents, 100_000, controlledDependencies)
+	assert.NoError(t, err)
+	if !assert.Len(t, analyses, 1) {
+		return
+	}
+
+	// detection halves the chance of a $550,000 average breach
+	analysis := analyses[0]
+	assert.Equal(t, "Breach Detected", analysis.Control)
+	assert.Equal(t, montecargo.Yearly, analysis.Horizon)
+	assert.InEpsilon(t, 0.25*550_000, analysis.AvoidedLoss.Mean, 0.03)
+	assert.Equal(t, 20_000.0, analysis.CostLower)
+	assert.Equal(t, 40_000.0, analysis.CostUpper)
+	assert.InEpsilon(t, 30_000, analysis.ExpectedCost, 0.01)
+	assert.InDelta(t, analysis.AvoidedLoss.Mean-analysis.ExpectedCost, analysis.NetBenefit, 1e-6)
+	assert.InDelta(t, analysis.NetBenefit/analysis.ExpectedCost, analysis.ROSI, 1e-9)
+
+	// with common random numbers the control never makes a trial worse, and it pays for itself
+	// exactly when it stops a breach
+	assert.Zero(t, analysis.AvoidedLoss.P50)
+	assert.InDelta(t, 0.25, analysis.PaybackProbability, 0.01)
+}
+
+func TestCommonRandomNumbersPairTrials(t *testing.T) {
+	simulator := montecargo.NewSimulator(montecargo.WithSeed(37), montecargo.WithStrategy(montecargo.StrategyJoint), montecargo.WithCommonRandomNumbers())
+	residual, err := simulator.Run(context.Background(), controlledEvents, 10_000, controlledDependencies)
+	assert.NoError(t, err)
+	inherent, err := simulator.Run(context.Background(), montecargo.WithoutControls(controlledEvents), 10_000, controlledDependencies)
+	assert.NoError(t, err)
+
+	for i := range residual.TrialLosses {
+		if residual.TrialLosses[i] > 0 {
+			assert.Equal(t, residual.TrialLosses[i], inherent.TrialLosses[i])
+		}
+	}
+
+	// the worker count doesn't change the draws
+	oneWorker := montecargo.NewSimulator(montecargo.WithSeed(37), montecargo.WithStrategy(montecargo.StrategyJoint), montecargo.WithCommonRandomNumbers(), montecargo.WithWorkers(1))
+	fourWorkers := montecargo.NewSimulator(montecargo.WithSeed(37), montecargo.WithStrategy(montecargo.StrategyJoint), montecargo.WithCommonRandomNumbers(), montecargo.WithWorkers(4))
+	first, err := oneWorker.Run(context.Background(), controlledEvents, 10_000, controlledDependencies)
+	assert.NoError(t, err)
+	second, err := fourWorkers.Run(context.Background(), controlledEvents, 10_000, controlledDependencies)
+	assert.NoError(t, err)
+	assert.Equal(t, first.TrialLosses, second.TrialLosses)
+}
@@ -0,0 +1,384 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	testing_utils "github.com/bcdannyboy/montecargo/testing/testing_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// sampleOnly is a distribution without a Quantile method.
+type sampleOnly struct{}
+
+func (sampleOnly) Sample(localRand *rand.Rand) float64 { return localRand.Float64() }
+func (sampleOnly) Mean() float64                       { return 0.5 }
+
+func TestDistributionQuantiles(t *testing.T) {
+	distributions := map[string]montecargo.Distribution{
+		"uniform":    montecargo.Uniform{Min: 10_000, Max: 50_000},
+		"triangular": montecargo.Triangular{Min: 10_000, Mode: 20_000, Max: 100_000},
+		"pert":       montecargo.PERT{Min: 10_000, Mode: 20_000, Max: 100_000},
+		"lognormal":  montecargo.LogNormalFrom90CI(10_000, 1_000_000),
+		"normal":     montecargo.Normal{Mu: 50_000, Sigma: 5_000},
+		"pareto":     montecargo.Pareto{Scale: 10_000, Shape: 3},
+		"gamma":      montecargo.Gamma{Shape: 0.5, Scale: 40_000},
+	}
+
+	for name, distribution := range distributions {
+		t.Run(name, func(t *testing.T) {
+			quantiler, ok := distribution.(montecargo.Quantiler)
+			if !assert.True(t, ok) {
+				return
+			}
+			samples := sampleDistribution(distribution, 200_000)
+			for _, p := range []float64{0.1, 0.5, 0.9, 0.99} {
+				expected := samples[int(p*float64(len(samples)))]
+				assert.InEpsilon(t, expected, quantiler.Quantile(p), 0.03, "quantile %g", p)
+			}
+		})
+	}
+
+	normal := montecargo.Normal{Mu: 0, Sigma: 1}
+	assert.InDelta(t, 1.6448536, normal.Quantile(0.95), 1e-6)
+	assert.InDelta(t, 15_000, montecargo.Uniform{Min: 10_000, Max: 20_000}.Quantile(0.5), 1e-9)
+	// a symmetric PERT has its median at the mode, a gamma with shape 1 is exponential
+	assert.InDelta(t, 15_000, montecargo.PERT{Min: 10_000, Mode: 15_000, Max: 20_000}.Quantile(0.5), 1e-6)
+	assert.InDelta(t, -1_000*math.Log(0.01), montecargo.Gamma{Shape: 1, Scale: 1_000}.Quantile(0.99), 1e-6)
+}
+
+func TestNearestCorrelationMatrix(t *testing.T) {
+	valid := [][]float64{{1, 0.5, 0.2}, {0.5, 1, 0.3}, {0.2, 0.3, 1}}
+	assert.True(t, montecargo.IsPositiveSemiDefinite(valid))
+	for i, row := range montecargo.NearestCorrelationMatrix(valid) {
+		assert.InDeltaSlice(t, valid[i], row, 1e-9)
+	}
+
+	// A and B and A and C move together, but B and C move apart
+	invalid := [][]float64{{1, 0.9, 0.9}, {0.9, 1, -0.9}, {0.9, -0.9, 1}}
+	assert.False(t, montecargo.IsPositiveSemiDefinite(invalid))
+
+	nearest := montecargo.NearestCorrelationMatrix(invalid)
+	assert.True(t, montecargo.IsPositiveSemiDefinite(nearest))
+	for i := range nearest {
+		assert.InDelta(t, 1, nearest[i][i], 1e-12)
+		for j := range nearest {
+			assert.InDelta(t, nearest[i][j], nearest[j][i], 1e-12)
+		}
+	}
+	// the nearest matrix keeps the signs of the original correlations
+	assert.Greater(t, nearest[0][1], 0.0)
+	assert.Less(t, nearest[1][2], 0.0)
+	assert.Equal(t, 0.9, invalid[0][1], "the original matrix is left unchanged")
+
+	// Higham's example, whose nearest correlation matrix is known
+	example := [][]float64{{1, 1, 0}, {1, 1, 1}, {0, 1, 1}}
+	expected := [][]float64{{1, 0.76068, 0.15729}, {0.76068, 1, 0.76068}, {0.15729, 0.76068, 1}}
+	assert.False(t, montecargo.IsPositiveSemiDefinite(example))
+	repaired := montecargo.NearestCorrelationMatrix(example)
+	assert.True(t, montecargo.IsPositiveSemiDefinite(repaired))
+	for i := range repaired {
+		assert.InDelta(t, 1, repaired[i][i], 1e-12)
+		assert.InDeltaSlice(t, expected[i], repaired[i], 1e-4)
+	}
+
+	// perfectly correlated events make a singular matrix, which is still positive semi-definite
+	assert.True(t, montecargo.IsPositiveSemiDefinite([][]float64{{1, 1}, {1, 1}}))
+}
+
+// correlatedPair is two events that each occur with probability p and have a fixed loss, so a
+// trial's total loss tells which of them occurred.
+func correlatedPair(p float64) []montecargo.Event {
+	return []montecargo.Event{
+		{Name: "Vendor A Breach", LowerProb: p, UpperProb: p, Timeframe: montecargo.Yearly, MinImpact: testing_utils.Float64Pointer(1), MaxImpact: testing_utils.Float64Pointer(1)},
+		{Name: "Vendor B Breach", LowerProb: p, UpperProb: p, Timeframe: montecargo.Yearly, MinImpact: testing_utils.Float64Pointer(2), MaxImpact: testing_utils.Float64Pointer(2)},
+	}
+}
+
+// pairCorrelation correlates the events of correlatedPair by rho.
+func pairCorrelation(rho float64, copula montecargo.Copula) montecargo.Correlation {
+	return montecargo.Correlation{
+		Events: []string{"Vendor A Breach", "Vendor B Breach"},
+		Matrix: [][]float64{{1, rho}, {rho, 1}},
+		Copula: copula,
+	}
+}
+
+// bothOccurred returns the fraction of trials in which both events of correlatedPair occurred.
+func bothOccurred(result montecargo.SimulationResult) float64 {
+	both := 0
+	for _, loss := range result.TrialLosses {
+		if loss == 3 {
+			both++
+		}
+	}
+	return float64(both) / float64(len(result.TrialLosses))
+}
+
+func TestCorrelatedOccurrence(t *testing.T) {
+	numSimulations := 100_000
+	events := correlatedPair(0.3)
+	correlation := montecargo.WithCorrelation(pairCorrelation(0.8, montecargo.CopulaGaussian))
+
+	independent, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(5))
+	if !assert.NoError(t, err) {
+		return
+	}
+	// 0.3 * 0.3
+	assert.InDelta(t, 0.09, bothOccurred(independent), 0.005)
+
+	joint, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(5), correlation)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the marginal probabilities don't change, only how often the events occur together
+	assert.InDelta(t, 0.3, joint.EventStats["Vendor A Breach"].Probability, 0.01)
+	assert.InDelta(t, 0.3, joint.EventStats["Vendor B Breach"].Probability, 0.01)
+	assert.Greater(t, bothOccurred(joint), 0.2)
+	assert.InDelta(t, bothOccurred(joint), float64(joint.CoOccurrences["Vendor A Breach"]["Vendor B Breach"])/float64(numSimulations), 1e-9)
+
+	// the events are in the same level, and the levels strategy draws from the same copula
+	levels, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(5), correlation)
+	if assert.NoError(t, err) {
+		assert.InDelta(t, bothOccurred(joint), bothOccurred(levels), 0.01)
+	}
+
+	// negative correlation makes the events occur together less often than independent ones
+	apart, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(5), montecargo.WithCorrelation(pairCorrelation(-0.8, montecargo.CopulaGaussian)))
+	if assert.NoError(t, err) {
+		assert.Less(t, bothOccurred(apart), 0.02)
+	}
+
+	// perfectly correlated events have a singular matrix and always occur together
+	together, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(5), montecargo.WithCorrelation(pairCorrelation(1, montecargo.CopulaGaussian)))
+	if assert.NoError(t, err) {
+		assert.InDelta(t, 0.3, bothOccurred(together), 0.01)
+	}
+}
+
+func TestStudentTCopulaTailDependence(t *testing.T) {
+	numSimulations := 200_000
+	events := correlatedPair(0.02)
+
+	gaussian, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(9), montecargo.WithCorrelation(pairCorrelation(0.5, montecargo.CopulaGaussian)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	studentT := pairCorrelation(0.5, montecargo.CopulaStudentT)
+	studentT.DegreesOfFreedom = 2
+	tailDependent, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(9), montecargo.WithCorrelation(studentT))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.InDelta(t, 0.02, tailDependent.EventStats["Vendor A Breach"].Probability, 0.002)
+	// rare events occur together more often with the t copula's tail dependence
+	assert.Greater(t, bothOccurred(tailDependent), 1.5*bothOccurred(gaussian))
+}
+
+func TestCorrelatedImpact(t *testing.T) {
+	numSimulations := 50_000
+	events := []montecargo.Event{
+		{Name: "Cloud Outage", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.Yearly, MinImpact: testing_utils.Float64Pointer(0), MaxImpact: testing_utils.Float64Pointer(100)},
+		{Name: "SaaS Outage", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.Yearly, ImpactDistribution: montecargo.Uniform{Min: 0, Max: 100}},
+	}
+	variance := func(result montecargo.SimulationResult) float64 {
+		sum, sumOfSquares := 0.0, 0.0
+		for _, loss := range result.TrialLosses {
+			sum += loss
+			sumOfSquares += loss * loss
+		}
+		mean := sum / float64(len(result.TrialLosses))
+		return sumOfSquares/float64(len(result.TrialLosses)) - mean*mean
+	}
+
+	independent, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(3))
+	if !assert.NoError(t, err) {
+		return
+	}
+	correlation := montecargo.Correlation{
+		Events: []string{"Cloud Outage", "SaaS Outage"},
+		Matrix: [][]float64{{1, 0.9}, {0.9, 1}},
+		Target: montecargo.CorrelateImpact,
+	}
+	correlated, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(3), montecargo.WithCorrelation(correlation))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// each impact has variance 100^2 / 12, so their sum has twice that when independent
+	assert.InEpsilon(t, 2*100.0*100/12, variance(independent), 0.03)
+	assert.Greater(t, variance(correlated), 1.8*variance(independent))
+	// the expected loss stays the same, only its spread grows
+	assert.InDelta(t, 100, correlated.EventStats["Cloud Outage"].Loss.ExpectedLoss+correlated.EventStats["SaaS Outage"].Loss.ExpectedLoss, 1)
+}
+
+func TestInvalidCorrelation(t *testing.T) {
+	events := correlatedPair(0.3)
+	names := []string{"Vendor A Breach", "Vendor B Breach"}
+	nonPSD := montecargo.Correlation{
+		Events: []string{"Vendor A Breach", "Vendor B Breach", "Vendor C Breach"},
+		Matrix: [][]float64{{1, 0.9, 0.9}, {0.9, 1, -0.9}, {0.9, -0.9, 1}},
+	}
+	eventsWithC := append(correlatedPair(0.3), montecargo.Event{Name: "Vendor C Breach", LowerProb: 0.3, UpperProb: 0.3, Timeframe: montecargo.Yearly})
+
+	tests := []struct {
+		name        string
+		events      []montecargo.Event
+		correlation montecargo.Correlation
+		err         error
+		message     string
+	}{
+		{
+			name:        "single event",
+			correlation: montecargo.Correlation{Events: names[:1], Matrix: [][]float64{{1}}},
+			err:         montecargo.ErrInvalidCorrelation,
+			message:     "correlation needs at least two events, got 1",
+		},
+		{
+			name:        "unknown event",
+			correlation: montecargo.Correlation{Events: []string{"Vendor A Breach", "Vendor Z Breach"}, Matrix: [][]float64{{1, 0.5}, {0.5, 1}}},
+			err:         montecargo.ErrUnknownEvent,
+			message:     `correlation of unknown event "Vendor Z Breach"`,
+		},
+		{
+			name:        "wrong size",
+			correlation: montecargo.Correlation{Events: names, Matrix: [][]float64{{1, 0.5}}},
+			err:         montecargo.ErrInvalidCorrelation,
+			message:     "correlation matrix has 1 rows for 2 events",
+		},
+		{
+			name:        "diagonal",
+			correlation: montecargo.Correlation{Events: names, Matrix: [][]float64{{1, 0.5}, {0.5, 0.9}}},
+			err:         montecargo.ErrInvalidCorrelation,
+			message:     `correlation of "Vendor B Breach" with itself is 0.9, expected 1`,
+		},
+		{
+			name:        "not symmetric",
+			correlation: montecargo.Correlation{Events: names, Matrix: [][]float64{{1, 0.5}, {0.4, 1}}},
+			err:         montecargo.ErrInvalidCorrelation,
+			message:     "correlation matrix isn't symmetric",
+		},
+		{
+			name:        "out of range",
+			correlation: montecargo.Correlation{Events: names, Matrix: [][]float64{{1, 1.5}, {1.5, 1}}},
+			err:         montecargo.ErrInvalidCorrelation,
+			message:     `correlation 1.5 of "Vendor A Breach" and "Vendor B Breach" is not between -1 and 1`,
+		},
+		{
+			name:        "not positive semi-definite",
+			events:      eventsWithC,
+			correlation: nonPSD,
+			err:         montecargo.ErrInvalidCorrelation,
+			message:     "correlation matrix is not positive semi-definite",
+		},
+		{
+			name: "impact without quantiles",
+			events: []montecargo.Event{
+				events[0],
+				{Name: "Vendor B Breach", LowerProb: 0.3, UpperProb: 0.3, Timeframe: montecargo.Yearly, ImpactDistribution: sampleOnly{}},
+			},
+			correlation: montecargo.Correlation{Events: names, Matrix: [][]float64{{1, 0.5}, {0.5, 1}}, Target: montecargo.CorrelateOccurrenceAndImpact},
+			err:         montecargo.ErrInvalidCorrelation,
+			message:     `the impact distribution of "Vendor B Breach" has no Quantile method`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runEvents := tt.events
+			if runEvents == nil {
+				runEvents = events
+			}
+			_, err := montecargo.NewSimulator(montecargo.WithCorrelation(tt.correlation)).Run(context.Background(), runEvents, 100, nil)
+			assert.ErrorIs(t, err, tt.err)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.message)
+			}
+		})
+	}
+
+	logger := &recordingLogger{}
+	nonPSD.Repair = true
+	_, err := montecargo.NewSimulator(montecargo.WithCorrelation(nonPSD), montecargo.WithLogger(logger), montecargo.WithSeed(1)).Run(context.Background(), eventsWithC, 100, nil)
+	if assert.NoError(t, err) && assert.NotEmpty(t, logger.lines) {
+		assert.Equal(t, "WARN correlation matrix is not positive semi-definite, using the nearest one that is events 3", logger.lines[0])
+	}
+}
+
+func TestCorrelationModelFile(t *testing.T) {
+	model := `version: 1
+settings:
+  seed: 5
+  strategy: joint
+  correlation:
+    copula: student-t
+    degreesOfFreedom: 3
+    target: occurrence and impact
+    events: [Vendor A Breach, Vendor B Breach]
+    matrix:
+      - [1, 0.8]
+      - [0.8, 1]
+events:
+  - name: Vendor A Breach
+    lowerProb: 0.3
+    upperProb: 0.3
+    timeframe: P1Y
+    minImpact: 1000
+    maxImpact: 5000
+  - name: Vendor B Breach
+    lowerProb: 0.3
+    upperProb: 0.3
+    timeframe: P1Y
+    impactDistribution:
+      type: lognormal
+      mu: 8
+      sigma: 1
+`
+	parsed, err := montecargo.ParseModel([]byte(model), "correlation.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &montecargo.Correlation{
+		Events:           []string{"Vendor A Breach", "Vendor B Breach"},
+		Matrix:           [][]float64{{1, 0.8}, {0.8, 1}},
+		Copula:           montecargo.CopulaStudentT,
+		DegreesOfFreedom: 3,
+		Target:           montecargo.CorrelateOccurrenceAndImpact,
+	}, parsed.Settings.Correlation)
+
+	var yamlOutput, jsonOutput bytes.Buffer
+	assert.NoError(t, parsed.WriteYAML(&yamlOutput))
+	fromYAML, err := montecargo.ParseModel(yamlOutput.Bytes(), "saved.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, parsed, fromYAML)
+	assert.NoError(t, parsed.WriteJSON(&jsonOutput))
+	fromJSON, err := montecargo.ParseModel(jsonOutput.Bytes(), "saved.json")
+	assert.NoError(t, err)
+	assert.Equal(t, parsed, fromJSON)
+
+	result, err := montecargo.NewSimulator(parsed.Settings.Options()...).Run(context.Background(), parsed.Events, 20_000, parsed.Dependencies)
+	if assert.NoError(t, err) {
+		together := float64(result.CoOccurrences["Vendor A Breach"]["Vendor B Breach"]) / float64(result.Trials)
+		assert.Greater(t, together, 0.15)
+		assert.False(t, math.IsNaN(result.EventStats["Vendor B Breach"].Loss.ExpectedLoss))
+	}
+
+	invalid := bytes.Replace([]byte(model), []byte("copula: student-t"), []byte("copula: clayton"), 1)
+	invalid = bytes.Replace(invalid, []byte("- [0.8, 1]"), []byte("- [0.7, 1]"), 1)
+	_, err = montecargo.ParseModel(invalid, "model.yaml")
+	var modelErrs montecargo.ModelErrors
+	if assert.ErrorAs(t, err, &modelErrs) {
+		assert.Equal(t, []string{`model.yaml:6:5: unknown copula "clayton", expected one of "gaussian", "student-t"`}, errorStrings(modelErrs))
+	}
+
+	asymmetric := bytes.Replace([]byte(model), []byte("- [0.8, 1]"), []byte("- [0.7, 1]"), 1)
+	_, err = montecargo.ParseModel(asymmetric, "model.yaml")
+	if assert.ErrorAs(t, err, &modelErrs) {
+		assert.Equal(t, []string{`model.yaml:6:5: correlation matrix isn't symmetric: "Vendor A Breach" and "Vendor B Breach" are correlated by 0.8 and 0.7`}, errorStrings(modelErrs))
+	}
+}
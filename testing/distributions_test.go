@@ -0,0 +1,127 @@
+package testing
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	testing_utils "github.com/bcdannyboy/montecargo/testing/testing_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleDistribution(distribution montecargo.Distribution, n int) []float64 {
+	localRand := rand.New(rand.NewSource(1))
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = distribution.Sample(localRand)
+	}
+	sort.Float64s(samples)
+	return samples
+}
+
+func TestDistributionSampleMeansMatchMean(t *testing.T) {
+	distributions := map[string]montecargo.Distribution{
+		"uniform":    montecargo.Uniform{Min: 10_000, Max: 50_000},
+		"triangular": montecargo.Triangular{Min: 10_000, Mode: 20_000, Max: 100_000},
+		"pert":       montecargo.PERT{Min: 10_000, Mode: 20_000, Max: 100_000},
+		"lognormal":  montecargo.LogNormalFrom90CI(10_000, 1_000_000),
+		"normal":     montecargo.Normal{Mu: 50_000, Sigma: 5_000},
+		"pareto":     montecargo.Pareto{Scale: 10_000, Shape: 3},
+		"gamma":      montecargo.Gamma{Shape: 0.5, Scale: 40_000},
+	}
+
+	for name, distribution := range distributions {
+		t.Run(name, func(t *testing.T) {
+			samples := sampleDistribution(distribution, 200_000)
+			sum := 0.0
+			for _, sample := range samples {
+				sum += sample
+			}
+			assert.InEpsilon(t, distribution.Mean(), sum/float64(len(samples)), 0.02)
+		})
+	}
+}
+
+func TestFrom90CIFitsBounds(t *testing.T) {
+	distributions := map[string]montecargo.Distribution{
+		"lognormal": montecargo.LogNormalFrom90CI(275_000, 251_000_000),
+		"normal":    montecargo.NormalFrom90CI(275_000, 251_000_000),
+	}
+
+	for name, distribution := range distributions {
+		t.Run(name, func(t *testing.T) {
+			samples := sampleDistribution(distribution, 200_000)
+			assert.InEpsilon(t, 251_000_000, samples[len(samples)*95/100], 0.05)
+		})
+	}
+
+	lognormal := sampleDistribution(montecargo.LogNormalFrom90CI(275_000, 251_000_000), 200_000)
+	assert.InEpsilon(t, 275_000, lognormal[len(lognormal)*5/100], 0.05)
+
+	pareto := sampleDistribution(montecargo.ParetoFrom90CI(10_000, 1_000_000), 200_000)
+	assert.GreaterOrEqual(t, pareto[0], 10_000.0)
+	assert.InEpsilon(t, 1_000_000, pareto[len(pareto)*95/100], 0.05)
+
+	// gamma only matches the mean and spread of the interval
+	gamma := montecargo.GammaFrom90CI(275_000, 251_000_000)
+	assert.InEpsilon(t, (275_000+251_000_000)/2.0, gamma.Mean(), 1e-9)
+}
+
+func TestFrom90CIRejectsInvalidIntervals(t *testing.T) {
+	fits := map[string]montecargo.Distribution{
+		"lognormal reversed":    montecargo.LogNormalFrom90CI(1_000_000, 10_000),
+		"lognormal from zero":   montecargo.LogNormalFrom90CI(0, 10_000),
+		"pareto reversed":       montecargo.ParetoFrom90CI(1_000_000, 10_000),
+		"pareto from negative":  montecargo.ParetoFrom90CI(-10, 10_000),
+		"pareto without spread": montecargo.ParetoFrom90CI(10_000, 10_000),
+		"normal reversed":       montecargo.NormalFrom90CI(1_000_000, 10_000),
+	}
+
+	for name, distribution := range fits {
+		t.Run(name, func(t *testing.T) {
+			events := []montecargo.Event{{Name: "Data Breach", LowerProb: 0.1, UpperProb: 0.3, Timeframe: montecargo.Yearly, ImpactDistribution: distribution}}
+			assert.ErrorIs(t, montecargo.Validate(events, nil, montecargo.Yearly).Errors(), montecargo.ErrInvalidImpact)
+		})
+	}
+
+	// a wide interval fits a Pareto distribution with an infinite mean, which runs with a warning
+	wide := montecargo.ParetoFrom90CI(10_000, 1_000_000)
+	assert.Less(t, wide.Shape, 1.0)
+	events := []montecargo.Event{{Name: "Data Breach", LowerProb: 0.1, UpperProb: 0.3, Timeframe: montecargo.Yearly, ImpactDistribution: wide}}
+	diagnostics := montecargo.Validate(events, nil, montecargo.Yearly)
+	assert.False(t, diagnostics.HasErrors())
+	if assert.Len(t, diagnostics.Warnings(), 1) {
+		assert.Equal(t, "ImpactDistribution", diagnostics[0].Field)
+		assert.Contains(t, diagnostics[0].Message, "its mean is infinite")
+	}
+}
+
+func TestEventImpactDistribution(t *testing.T) {
+	numSimulations := 100_000
+	events := []montecargo.Event{
+		{
+			Name:               "Heavy Tailed Breach",
+			LowerProb:          1,
+			UpperProb:          1,
+			Timeframe:          montecargo.Yearly,
+			ImpactDistribution: montecargo.LogNormalFrom90CI(100_000, 10_000_000),
+		},
+		{
+			Name:      "Uniform Breach",
+			LowerProb: 1,
+			UpperProb: 1,
+			Timeframe: montecargo.Yearly,
+			MinImpact: testing_utils.Float64Pointer(100_000),
+			MaxImpact: testing_utils.Float64Pointer(10_000_000),
+		},
+	}
+
+	result, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(3))
+	assert.NoError(t, err)
+
+	_, _, heavyMean, _ := montecargo.MeanSTD(result.EventResults["Heavy Tailed Breach"], numSimulations)
+	_, _, uniformMean, _ := montecargo.MeanSTD(result.EventResults["Uniform Breach"], numSimulations)
+	assert.InEpsilon(t, events[0].ImpactDistribution.Mean(), heavyMean, 0.05)
+	assert.InEpsilon(t, 5_050_000, uniformMean, 0.02)
+}
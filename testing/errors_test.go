@@ -0,0 +1,69 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLogger records the messages and arguments it is sent, like a *slog.Logger would
+// log them.
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {
+	l.lines = append(l.lines, "DEBUG "+msg+" "+strings.TrimSpace(fmt.Sprintln(args...)))
+}
+
+func (l *recordingLogger) Warn(msg string, args ...interface{}) {
+	l.lines = append(l.lines, "WARN "+msg+" "+strings.TrimSpace(fmt.Sprintln(args...)))
+}
+
+func TestSentinelErrors(t *testing.T) {
+	events := []montecargo.Event{{Name: "Outage", LowerProb: 0.4, UpperProb: 1.2, Timeframe: montecargo.Yearly}}
+
+	_, err := montecargo.MonteCarloSimulation(events, 100, nil)
+	assert.ErrorIs(t, err, montecargo.ErrInvalidProbability)
+	assert.NotErrorIs(t, err, montecargo.ErrUnknownEvent)
+
+	events[0].UpperProb = 0.6
+	_, err = montecargo.MonteCarloSimulation(events, 100, map[string][]montecargo.Dependency{"Outage": {{EventName: "Flood", Condition: "happens"}}})
+	assert.ErrorIs(t, err, montecargo.ErrUnknownEvent)
+
+	_, err = montecargo.BuildDependencyGraph(append(events, events[0]), nil)
+	assert.ErrorIs(t, err, montecargo.ErrDuplicateEvent)
+
+	_, err = montecargo.NewSimulator().Run(context.Background(), events, 0, nil)
+	assert.ErrorIs(t, err, montecargo.ErrInvalidTrials)
+}
+
+func TestCalculateEventStatsReportsMissingResults(t *testing.T) {
+	events := []montecargo.Event{
+		{Name: "Outage", LowerProb: 0.1, UpperProb: 0.1, Timeframe: montecargo.Yearly},
+		{Name: "Flood", LowerProb: 0.1, UpperProb: 0.1, Timeframe: montecargo.Yearly},
+	}
+	results := map[string]montecargo.EventResult{"Outage": {Trials: 10, Sum: 2, SumOfSquares: 2}}
+
+	stats, err := montecargo.CalculateEventStats(results, 10, events)
+	assert.ErrorIs(t, err, montecargo.ErrUnknownEvent)
+	assert.EqualError(t, err, `no result for event "Flood"`)
+	assert.InDelta(t, 0.2, stats["Outage"].Probability, 1e-9)
+	assert.NotContains(t, stats, "Flood")
+}
+
+func TestSimulatorLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	events := []montecargo.Event{{Name: "Certain Decade", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.EveryTenYears}}
+
+	_, err := montecargo.NewSimulator(montecargo.WithLogger(logger), montecargo.WithSeed(1)).Run(context.Background(), events, 100, nil)
+	if assert.NoError(t, err) && assert.Len(t, logger.lines, 3) {
+		assert.Contains(t, logger.lines[0], "WARN simulating an event with a warning event Certain Decade field Timeframe")
+		assert.Contains(t, logger.lines[1], "DEBUG simulation started")
+		assert.Contains(t, logger.lines[2], "DEBUG simulation finished")
+	}
+}
@@ -0,0 +1,151 @@
+package testing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+func exportedBreachModel(t *testing.T) (*montecargo.Model, montecargo.SimulationResult, montecargo.ExportMetadata) {
+	model, err := montecargo.ParseModel([]byte(breachModel), "breach.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := montecargo.NewSimulator(model.Settings.Options()...).Run(context.Background(), model.Events, 2_000, model.Dependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := montecargo.NewExportMetadata(result, model.Events, model.Dependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model, result, metadata
+}
+
+func TestExportMetadata(t *testing.T) {
+	model, _, metadata := exportedBreachModel(t)
+
+	assert.Equal(t, "montecargo", metadata.Tool)
+	assert.Equal(t, montecargo.Version, metadata.Version)
+	assert.Equal(t, int64(42), metadata.Seed)
+	assert.Equal(t, 2_000, metadata.Trials)
+	assert.Equal(t, "P1Y", metadata.Horizon)
+	assert.False(t, metadata.Timestamp.IsZero())
+
+	// the hash depends on the inputs, not on how they were written
+	var buf bytes.Buffer
+	assert.NoError(t, model.WriteJSON(&buf))
+	reloaded, err := montecargo.ParseModel(buf.Bytes(), "breach.json")
+	if assert.NoError(t, err) {
+		hash, err := montecargo.InputHash(reloaded.Events, reloaded.Dependencies)
+		assert.NoError(t, err)
+		assert.Equal(t, metadata.InputHash, hash)
+	}
+
+	model.Events[0].UpperProb = 0.8
+	hash, err := montecargo.InputHash(model.Events, model.Dependencies)
+	assert.NoError(t, err)
+	assert.NotEqual(t, metadata.InputHash, hash)
+}
+
+func TestResultExportJSON(t *testing.T) {
+	model, result, metadata := exportedBreachModel(t)
+	export := montecargo.NewResultExport(metadata, result, model.Events)
+
+	var buf bytes.Buffer
+	assert.NoError(t, export.WriteJSON(&buf))
+
+	var decoded map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded)) {
+		return
+	}
+	assert.Equal(t, montecargo.ResultSchema, decoded["schema"])
+	for _, key := range []string{"metadata", "incomplete", "events", "loss", "lossRange", "coOccurrences", "warnings"} {
+		assert.Contains(t, decoded, key)
+	}
+
+	events := decoded["events"].([]interface{})
+	assert.Len(t, events, len(model.Events))
+	breach := events[0].(map[string]interface{})
+	assert.Equal(t, "Data Breach", breach["name"])
+	assert.InDelta(t, result.EventStats["Data Breach"].Loss.ExpectedLoss, breach["expectedLoss"], 1e-6)
+
+	loss := decoded["loss"].(map[string]interface{})
+	assert.InDelta(t, montecargo.NewLossReport(result, 0.99).P90, percentileOf(loss, 0.9), 1e-6)
+}
+
+func percentileOf(loss map[string]interface{}, p float64) float64 {
+	for _, entry := range loss["percentiles"].([]interface{}) {
+		percentile := entry.(map[string]interface{})
+		if percentile["percentile"] == p {
+			return percentile["loss"].(float64)
+		}
+	}
+	return -1
+}
+
+func TestResultExportCSV(t *testing.T) {
+	model, result, metadata := exportedBreachModel(t)
+	export := montecargo.NewResultExport(metadata, result, model.Events)
+
+	readCSV := func(write func(w io.Writer) error) (string, [][]string) {
+		var buf bytes.Buffer
+		assert.NoError(t, write(&buf))
+		reader := csv.NewReader(strings.NewReader(buf.String()))
+		reader.Comment = '#'
+		records, err := reader.ReadAll()
+		assert.NoError(t, err)
+		return buf.String(), records
+	}
+
+	raw, events := readCSV(export.WriteEventsCSV)
+	assert.Contains(t, raw, "# seed: 42\n")
+	assert.Contains(t, raw, "# inputHash: "+metadata.InputHash+"\n")
+	if assert.Len(t, events, len(model.Events)+1) {
+		assert.Equal(t, "name", events[0][0])
+		assert.Equal(t, "Phishing", events[2][0])
+		assert.Len(t, events[2], len(events[0]))
+	}
+
+	_, percentiles := readCSV(export.WritePercentilesCSV)
+	assert.Equal(t, []string{"measure", "percentile", "loss"}, percentiles[0])
+	assert.Contains(t, percentiles, []string{"percentile", "0.99", strconv.FormatFloat(export.Loss.Percentiles[4].Loss, 'g', -1, 64)})
+}
+
+func TestWriteTrialsJSONL(t *testing.T) {
+	_, result, metadata := exportedBreachModel(t)
+
+	var buf bytes.Buffer
+	assert.NoError(t, montecargo.WriteTrialsJSONL(&buf, metadata, result))
+
+	scanner := bufio.NewScanner(&buf)
+	if !assert.True(t, scanner.Scan()) {
+		return
+	}
+	var header struct{ Metadata montecargo.ExportMetadata }
+	assert.NoError(t, json.Unmarshal(scanner.Bytes(), &header))
+	assert.Equal(t, metadata.InputHash, header.Metadata.InputHash)
+
+	trials, total := 0, 0.0
+	for scanner.Scan() {
+		var trial struct {
+			Trial         int
+			Loss, Savings float64
+		}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &trial))
+		assert.Equal(t, trials, trial.Trial)
+		total += trial.Loss
+		trials++
+	}
+	assert.Equal(t, len(result.TrialLosses), trials)
+	assert.InDelta(t, montecargo.NewLossReport(result, 0.99).Mean*float64(trials), total, 1e-3)
+}
@@ -0,0 +1,224 @@
+package testing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+var expressionEvents = []montecargo.Event{
+	{Name: "Phishing", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+	{Name: "RDP Exposure", LowerProb: 0.4, UpperProb: 0.4, Timeframe: montecargo.Yearly},
+	{Name: "Ransomware Attack", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.Yearly},
+	{Name: "Data Breach", LowerProb: 0.6, UpperProb: 0.6, Timeframe: montecargo.Yearly},
+	{Name: "Host Detection", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly, IsCostSaving: true},
+	{Name: "System Compromise", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.Yearly},
+	{Name: "Site A Failure", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+	{Name: "Site B Failure", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+	{Name: "Site C Failure", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+	{Name: "Outage", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.Yearly},
+}
+
+func requires(expression montecargo.Expression) []montecargo.Dependency {
+	return []montecargo.Dependency{{Requires: &expression}}
+}
+
+var expressionDependencies = map[string][]montecargo.Dependency{
+	"Ransomware Attack": requires(montecargo.Or(montecargo.Happens("Phishing"), montecargo.Happens("RDP Exposure"))),
+	"System Compromise": requires(montecargo.And(montecargo.Happens("Data Breach"), montecargo.Not(montecargo.Happens("Host Detection")))),
+	"Outage":            requires(montecargo.AtLeast(2, montecargo.Happens("Site A Failure"), montecargo.Happens("Site B Failure"), montecargo.Happens("Site C Failure"))),
+}
+
+func TestRequiresExpressions(t *testing.T) {
+	numSimulations := 100_000
+	result, err := montecargo.JointMonteCarloSimulation(expressionEvents, numSimulations, expressionDependencies, montecargo.WithSeed(11))
+	if !assert.NoError(t, err) {
+		return
+	}
+	frequency := func(eventName string) float64 {
+		return float64(result.EventResults[eventName].Sum) / float64(numSimulations)
+	}
+
+	// 1 - 0.5 * 0.6
+	assert.InDelta(t, 0.7, frequency("Ransomware Attack"), 0.01)
+	// 0.6 * 0.5
+	compromise := result.EventResults["System Compromise"].Sum
+	assert.InDelta(t, 0.3, frequency("System Compromise"), 0.01)
+	assert.Equal(t, compromise, result.CoOccurrences["System Compromise"]["Data Breach"])
+	assert.Zero(t, result.CoOccurrences["System Compromise"]["Host Detection"])
+	// 3 * 0.5^3 + 0.5^3
+	assert.InDelta(t, 0.5, frequency("Outage"), 0.01)
+
+	// the levels strategy uses the probability that the expressions hold
+	levels, err := montecargo.MonteCarloSimulation(expressionEvents, numSimulations, expressionDependencies, montecargo.WithSeed(11))
+	if assert.NoError(t, err) {
+		assert.InDelta(t, 0.7, levels.EventStats["Ransomware Attack"].Probability, 0.01)
+		assert.InDelta(t, 0.3, levels.EventStats["System Compromise"].Probability, 0.01)
+		assert.InDelta(t, 0.5, levels.EventStats["Outage"].Probability, 0.01)
+	}
+}
+
+func TestExpressionStringAndEvents(t *testing.T) {
+	expression := montecargo.Or(
+		montecargo.And(montecargo.Happens("Data Breach"), montecargo.Not(montecargo.Happens("Host Detection"))),
+		montecargo.AtLeast(2, montecargo.Happens("Site A"), montecargo.Happens("Site B"), montecargo.Not(montecargo.Or(montecargo.Happens("Data Breach")))),
+	)
+	assert.Equal(t, "(Data Breach and not Host Detection) or (at least 2 of Site A, Site B, not (Data Breach))", expression.String())
+	assert.Equal(t, []string{"Data Breach", "Host Detection", "Site A", "Site B"}, expression.Events())
+
+	graph, err := montecargo.BuildDependencyGraph(expressionEvents, expressionDependencies)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"Site A Failure", "Site B Failure", "Site C Failure"}, graph.Parents("Outage"))
+		assert.Equal(t, []string{"System Compromise"}, graph.Children("Host Detection"))
+	}
+}
+
+func TestValidateRequiresExpressions(t *testing.T) {
+	events := []montecargo.Event{
+		{Name: "Phishing", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+		{Name: "Ransomware Attack", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+	}
+
+	tests := []struct {
+		name         string
+		dependencies []montecargo.Dependency
+		err          error
+		contains     string
+	}{
+		{
+			name:         "or without operands",
+			dependencies: requires(montecargo.Or()),
+			err:          montecargo.ErrInvalidDependency,
+			contains:     `"or" expression has no operands`,
+		},
+		{
+			name:         "at least more than the operands",
+			dependencies: requires(montecargo.AtLeast(2, montecargo.Happens("Phishing"))),
+			err:          montecargo.ErrInvalidDependency,
+			contains:     `"at least 2" expression has 1 operands`,
+		},
+		{
+			name:         "not with two operands",
+			dependencies: requires(montecargo.Expression{Operator: montecargo.ExpressionNot, Operands: []montecargo.Expression{montecargo.Happens("Phishing"), montecargo.Happens("Phishing")}}),
+			err:          montecargo.ErrInvalidDependency,
+			contains:     `"not" expression has 2 operands, expected 1`,
+		},
+		{
+			name:         "unknown event",
+			dependencies: requires(montecargo.And(montecargo.Happens("Phishing"), montecargo.Not(montecargo.Happens("RDP Exposure")))),
+			err:          montecargo.ErrUnknownEvent,
+			contains:     `event "Ransomware Attack" depends on unknown event "RDP Exposure"`,
+		},
+		{
+			name:         "self reference",
+			dependencies: requires(montecargo.Or(montecargo.Happens("Phishing"), montecargo.Happens("Ransomware Attack"))),
+			err:          montecargo.ErrInvalidDependency,
+			contains:     `event "Ransomware Attack" depends on itself`,
+		},
+		{
+			name:         "requires with an event name",
+			dependencies: []montecargo.Dependency{{EventName: "Phishing", Requires: &montecargo.Expression{Operator: montecargo.ExpressionEvent, Event: "Phishing"}}},
+			err:          montecargo.ErrInvalidDependency,
+			contains:     "dependency requiring Phishing also has an event name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := montecargo.Validate(events, map[string][]montecargo.Dependency{"Ransomware Attack": tt.dependencies}, montecargo.Yearly)
+			if assert.True(t, diagnostics.HasErrors()) {
+				first := diagnostics.Errors()[0]
+				assert.Equal(t, "Ransomware Attack", first.Event)
+				assert.Equal(t, "Dependencies", first.Field)
+				assert.ErrorIs(t, first.Err, tt.err)
+				assert.Contains(t, first.Message, tt.contains)
+			}
+		})
+	}
+
+	cycle := map[string][]montecargo.Dependency{
+		"Ransomware Attack": requires(montecargo.Not(montecargo.Happens("Phishing"))),
+		"Phishing":          requires(montecargo.AtLeast(1, montecargo.Happens("Ransomware Attack"))),
+	}
+	_, err := montecargo.BuildDependencyGraph(events, cycle)
+	assert.ErrorIs(t, err, montecargo.ErrInvalidDependency)
+	assert.Contains(t, err.Error(), "dependency cycle detected")
+}
+
+func TestRequiresModelFile(t *testing.T) {
+	model := `version: 1
+events:
+  - name: Data Breach
+    lowerProb: 0.6
+    upperProb: 0.6
+    timeframe: P1Y
+  - name: Host Detection
+    lowerProb: 0.5
+    upperProb: 0.5
+    timeframe: P1Y
+  - name: Phishing
+    lowerProb: 0.5
+    upperProb: 0.5
+    timeframe: P1Y
+  - name: System Compromise
+    lowerProb: 1
+    upperProb: 1
+    timeframe: P1Y
+dependencies:
+  System Compromise:
+    - requires:
+        or:
+          - and:
+              - event: Data Breach
+              - not: {event: Host Detection}
+          - atLeast: 2
+            of:
+              - event: Data Breach
+              - event: Phishing
+`
+	parsed, err := montecargo.ParseModel([]byte(model), "requires.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, requires(montecargo.Or(
+		montecargo.And(montecargo.Happens("Data Breach"), montecargo.Not(montecargo.Happens("Host Detection"))),
+		montecargo.AtLeast(2, montecargo.Happens("Data Breach"), montecargo.Happens("Phishing")),
+	)), parsed.Dependencies["System Compromise"])
+
+	var yamlOutput bytes.Buffer
+	assert.NoError(t, parsed.WriteYAML(&yamlOutput))
+	fromYAML, err := montecargo.ParseModel(yamlOutput.Bytes(), "saved.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, parsed, fromYAML)
+
+	var dot, mermaid, svg bytes.Buffer
+	assert.NoError(t, parsed.WriteDOT(&dot))
+	assert.NoError(t, parsed.WriteMermaid(&mermaid))
+	assert.NoError(t, parsed.WriteSVG(&svg))
+	for _, line := range []string{
+		`"_gate0" [label="OR", shape=diamond, style=solid];`,
+		`"_gate1" [label="AND", shape=diamond, style=solid];`,
+		`"_gate2" [label="NOT", shape=diamond, style=solid];`,
+		`"Host Detection" -> "_gate2" [style=dashed];`,
+		`"_gate3" [label="2 of 2", shape=diamond, style=solid];`,
+		`"_gate0" -> "System Compromise" [label="requires"];`,
+	} {
+		assert.Contains(t, dot.String(), line)
+	}
+	assert.Contains(t, mermaid.String(), `g0{"OR"}`)
+	assert.Contains(t, mermaid.String(), `e1 -.-> g2`)
+	assert.Contains(t, mermaid.String(), `g0 -->|"requires"| e3`)
+	assert.Contains(t, svg.String(), "System Compromise requires (Data Breach and not Host Detection) or (at least 2 of Data Breach, Phishing)")
+
+	invalid := model[:len(model)-len("              - event: Phishing\n")] + "              - event: Phishing\n                not: {event: Data Breach}\n              - event: Vishing\n"
+	_, err = montecargo.ParseModel([]byte(invalid), "model.yaml")
+	var modelErrs montecargo.ModelErrors
+	if assert.ErrorAs(t, err, &modelErrs) {
+		assert.Equal(t, []string{
+			"model.yaml:29:17: expression needs exactly one of event, and, or, not, or atLeast with of",
+			`model.yaml:31:24: event "System Compromise" depends on unknown event "Vishing"`,
+		}, errorStrings(modelErrs))
+	}
+}
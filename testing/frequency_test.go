@@ -0,0 +1,64 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	testing_utils "github.com/bcdannyboy/montecargo/testing/testing_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrequencyModels(t *testing.T) {
+	numSimulations := 200_000
+	events := []montecargo.Event{
+		{
+			Name:       "Phishing",
+			Timeframe:  montecargo.Yearly,
+			Frequency:  montecargo.FrequencyPoisson,
+			AnnualRate: testing_utils.Float64Pointer(12),
+			MinImpact:  testing_utils.Float64Pointer(1_000),
+			MaxImpact:  testing_utils.Float64Pointer(3_000),
+		},
+		{
+			Name:       "Credential Stuffing",
+			Timeframe:  montecargo.Yearly,
+			Frequency:  montecargo.FrequencyNegativeBinomial,
+			AnnualRate: testing_utils.Float64Pointer(2),
+			Dispersion: testing_utils.Float64Pointer(0.5),
+		},
+		{
+			Name:       "Rare Outage",
+			Timeframe:  montecargo.Yearly,
+			Frequency:  montecargo.FrequencyPoisson,
+			AnnualRate: testing_utils.Float64Pointer(0.1),
+		},
+		{
+			Name:       "Large Rate",
+			Timeframe:  montecargo.Yearly,
+			Frequency:  montecargo.FrequencyPoisson,
+			AnnualRate: testing_utils.Float64Pointer(250),
+		},
+	}
+
+	result, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(11))
+	assert.NoError(t, err)
+
+	phishing := result.EventStats["Phishing"]
+	assert.InEpsilon(t, 12, phishing.MeanFrequency, 0.01)
+	assert.InDelta(t, 1, phishing.Probability, 0.001)
+
+	// each occurrence draws its own impact, so the annual loss is about 12 * $2,000
+	assert.InEpsilon(t, 2_000, phishing.Loss.SeverityMean, 0.01)
+	assert.InEpsilon(t, 24_000, phishing.Loss.ExpectedLoss, 0.01)
+
+	// negative binomial: P(N = 0) = (k / (k + mean))^k = 0.2^0.5
+	credentialStuffing := result.EventStats["Credential Stuffing"]
+	assert.InEpsilon(t, 2, credentialStuffing.MeanFrequency, 0.02)
+	assert.InDelta(t, 1-0.4472, credentialStuffing.Probability, 0.005)
+
+	rareOutage := result.EventStats["Rare Outage"]
+	assert.InEpsilon(t, 0.1, rareOutage.MeanFrequency, 0.03)
+	assert.InDelta(t, 0.0952, rareOutage.Probability, 0.003)
+
+	assert.InEpsilon(t, 250, result.EventStats["Large Rate"].MeanFrequency, 0.005)
+}
@@ -0,0 +1,91 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+var chainEvents = []montecargo.Event{
+	{Name: "Ransomware Attack", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+	{Name: "System Compromise", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+	{Name: "Data Breach", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+	{Name: "Network Detection", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly},
+}
+
+var chainDependencies = map[string][]montecargo.Dependency{
+	"Ransomware Attack": {{EventName: "System Compromise", Condition: "happens"}},
+	"System Compromise": {{EventName: "Data Breach", Condition: "happens"}},
+	"Data Breach":       {{EventName: "Network Detection", Condition: "not happens"}},
+}
+
+func TestDependencyGraphOrdersChains(t *testing.T) {
+	graph, err := montecargo.BuildDependencyGraph(chainEvents, chainDependencies)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"Network Detection", "Data Breach", "System Compromise", "Ransomware Attack"}, graph.Order())
+	assert.Equal(t, [][]string{{"Network Detection"}, {"Data Breach"}, {"System Compromise"}, {"Ransomware Attack"}}, graph.Levels())
+	assert.Equal(t, []string{"Data Breach"}, graph.Parents("System Compromise"))
+	assert.Equal(t, []string{"System Compromise"}, graph.Children("Data Breach"))
+}
+
+func TestDependencyGraphRejectsInvalidDependencies(t *testing.T) {
+	tests := []struct {
+		name         string
+		dependencies map[string][]montecargo.Dependency
+		errContains  string
+	}{
+		{
+			name: "cycle",
+			dependencies: map[string][]montecargo.Dependency{
+				"Data Breach":       {{EventName: "System Compromise", Condition: "happens"}},
+				"System Compromise": {{EventName: "Data Breach", Condition: "happens"}},
+			},
+			errContains: "dependency cycle detected",
+		},
+		{
+			name: "self reference",
+			dependencies: map[string][]montecargo.Dependency{
+				"Data Breach": {{EventName: "Data Breach", Condition: "happens"}},
+			},
+			errContains: `event "Data Breach" depends on itself`,
+		},
+		{
+			name: "unknown parent",
+			dependencies: map[string][]montecargo.Dependency{
+				"Data Breach": {{EventName: "Phishing", Condition: "happens"}},
+			},
+			errContains: `event "Data Breach" depends on unknown event "Phishing"`,
+		},
+		{
+			name: "unknown dependent",
+			dependencies: map[string][]montecargo.Dependency{
+				"Phishing": {{EventName: "Data Breach", Condition: "happens"}},
+			},
+			errContains: `dependencies defined for unknown event "Phishing"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := montecargo.BuildDependencyGraph(chainEvents, tt.dependencies)
+			assert.ErrorContains(t, err, tt.errContains)
+
+			_, err = montecargo.JointMonteCarloSimulation(chainEvents, 10, tt.dependencies)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMonteCarloSimulationUsesEveryDependencyLevel(t *testing.T) {
+	numSimulations := 200_000
+	result, err := montecargo.MonteCarloSimulation(chainEvents, numSimulations, chainDependencies)
+	assert.NoError(t, err)
+
+	// each level halves the probability of the one before it
+	assert.InDelta(t, 0.5, result.EventStats["Network Detection"].Probability, 0.01)
+	assert.InDelta(t, 0.25, result.EventStats["Data Breach"].Probability, 0.01)
+	assert.InDelta(t, 0.125, result.EventStats["System Compromise"].Probability, 0.01)
+	assert.InDelta(t, 0.0625, result.EventStats["Ransomware Attack"].Probability, 0.01)
+}
@@ -0,0 +1,138 @@
+package testing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	testing_utils "github.com/bcdannyboy/montecargo/testing/testing_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpactBoundStdDevsScaleWithImpact(t *testing.T) {
+	numSimulations := 100_000
+	events := []montecargo.Event{
+		{
+			Name:            "Small Loss",
+			LowerProb:       1,
+			UpperProb:       1,
+			Timeframe:       montecargo.Yearly,
+			MinImpact:       testing_utils.Float64Pointer(10_000),
+			MaxImpact:       testing_utils.Float64Pointer(10_000),
+			MinImpactStdDev: testing_utils.Float64Pointer(1_000),
+			MaxImpactStdDev: testing_utils.Float64Pointer(1_000),
+		},
+		{
+			Name:            "Large Loss",
+			LowerProb:       1,
+			UpperProb:       1,
+			Timeframe:       montecargo.Yearly,
+			MinImpact:       testing_utils.Float64Pointer(100_000_000),
+			MaxImpact:       testing_utils.Float64Pointer(100_000_000),
+			MinImpactStdDev: testing_utils.Float64Pointer(10_000_000),
+			MaxImpactStdDev: testing_utils.Float64Pointer(10_000_000),
+		},
+	}
+
+	result, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(5))
+	assert.NoError(t, err)
+
+	_, _, smallMean, smallStdDev := montecargo.MeanSTD(result.EventResults["Small Loss"], numSimulations)
+	_, _, largeMean, largeStdDev := montecargo.MeanSTD(result.EventResults["Large Loss"], numSimulations)
+
+	// a uniform draw between two N(m, s) bounds has a standard deviation of s * sqrt(2/3)
+	assert.InEpsilon(t, 10_000, smallMean, 0.01)
+	assert.InEpsilon(t, 1_000*math.Sqrt(2.0/3), smallStdDev, 0.05)
+	assert.InEpsilon(t, 100_000_000, largeMean, 0.01)
+	assert.InEpsilon(t, 10_000_000*math.Sqrt(2.0/3), largeStdDev, 0.05)
+}
+
+func TestConfidenceWidensImpactRange(t *testing.T) {
+	numSimulations := 100_000
+	events := []montecargo.Event{
+		{
+			Name:      "Certain Estimate",
+			LowerProb: 1,
+			UpperProb: 1,
+			Timeframe: montecargo.Yearly,
+			MinImpact: testing_utils.Float64Pointer(100_000),
+			MaxImpact: testing_utils.Float64Pointer(200_000),
+		},
+		{
+			Name:       "Unsure Estimate",
+			LowerProb:  1,
+			UpperProb:  1,
+			Confidence: 0.5,
+			Timeframe:  montecargo.Yearly,
+			MinImpact:  testing_utils.Float64Pointer(100_000),
+			MaxImpact:  testing_utils.Float64Pointer(200_000),
+		},
+	}
+
+	result, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(9))
+	assert.NoError(t, err)
+
+	_, _, certainMean, certainStdDev := montecargo.MeanSTD(result.EventResults["Certain Estimate"], numSimulations)
+	_, _, unsureMean, unsureStdDev := montecargo.MeanSTD(result.EventResults["Unsure Estimate"], numSimulations)
+
+	// at 50% confidence the stated range is half of the sampled range
+	assert.InEpsilon(t, 150_000, certainMean, 0.01)
+	assert.InEpsilon(t, 150_000, unsureMean, 0.01)
+	assert.InEpsilon(t, 2*certainStdDev, unsureStdDev, 0.05)
+}
+
+func TestLossGivenOccurrenceIsNotScaledByProbability(t *testing.T) {
+	numSimulations := 200_000
+	events := []montecargo.Event{
+		{
+			Name:      "Occasional Breach",
+			LowerProb: 0.25,
+			UpperProb: 0.25,
+			Timeframe: montecargo.Yearly,
+			MinImpact: testing_utils.Float64Pointer(100_000),
+			MaxImpact: testing_utils.Float64Pointer(200_000),
+		},
+		{
+			Name:       "Repeated Outage",
+			Timeframe:  montecargo.Yearly,
+			Frequency:  montecargo.FrequencyPoisson,
+			AnnualRate: testing_utils.Float64Pointer(2),
+			MinImpact:  testing_utils.Float64Pointer(100_000),
+			MaxImpact:  testing_utils.Float64Pointer(200_000),
+		},
+	}
+
+	result, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(13))
+	assert.NoError(t, err)
+
+	breach := result.EventStats["Occasional Breach"]
+	_, _, lossGivenOccurrence, _ := montecargo.MeanSTD(result.EventResults["Occasional Breach"], numSimulations)
+	assert.InEpsilon(t, 150_000, lossGivenOccurrence, 0.01)
+	assert.InEpsilon(t, 150_000, breach.Loss.SeverityMean, 0.01)
+	assert.InEpsilon(t, 150_000, breach.Loss.ConditionalMean, 0.01)
+	assert.InEpsilon(t, 0.25*150_000, breach.Loss.ExpectedLoss, 0.02)
+
+	// a year with outages has 2 / (1 - e^-2) of them on average
+	outage := result.EventStats["Repeated Outage"]
+	assert.InEpsilon(t, 150_000, outage.Loss.SeverityMean, 0.01)
+	assert.InEpsilon(t, 150_000*2/(1-math.Exp(-2)), outage.Loss.ConditionalMean, 0.01)
+	assert.InEpsilon(t, 300_000, outage.Loss.ExpectedLoss, 0.01)
+}
+
+func TestLargeImpactsDoNotOverflow(t *testing.T) {
+	events := []montecargo.Event{{
+		Name:      "Catastrophe",
+		LowerProb: 1,
+		UpperProb: 1,
+		Timeframe: montecargo.Yearly,
+		MinImpact: testing_utils.Float64Pointer(1e13),
+		MaxImpact: testing_utils.Float64Pointer(1e13),
+	}}
+
+	result, err := montecargo.MonteCarloSimulation(events, 1_000, nil, montecargo.WithSeed(1))
+	assert.NoError(t, err)
+
+	_, _, impactMean, impactStdDev := montecargo.MeanSTD(result.EventResults["Catastrophe"], 1_000)
+	assert.InEpsilon(t, 1e13, impactMean, 1e-9)
+	assert.False(t, math.IsNaN(impactStdDev))
+}
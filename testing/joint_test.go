@@ -0,0 +1,78 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+var jointEvents = []montecargo.Event{
+	{
+		Name:      "Network Detection",
+		LowerProb: 0.4,
+		UpperProb: 0.6,
+		Timeframe: montecargo.Yearly,
+	},
+	{
+		Name:      "Data Breach",
+		LowerProb: 0.5,
+		UpperProb: 0.7,
+		Timeframe: montecargo.Yearly,
+	},
+	{
+		Name:      "Ransomware Attack",
+		LowerProb: 0.3,
+		UpperProb: 0.5,
+		Timeframe: montecargo.Yearly,
+	},
+}
+
+var jointDependencies = map[string][]montecargo.Dependency{
+	"Ransomware Attack": {
+		{EventName: "Data Breach", Condition: "happens"},
+	},
+	"Data Breach": {
+		{EventName: "Network Detection", Condition: "not happens"},
+	},
+}
+
+func TestJointSimulationRespectsTrialOutcomes(t *testing.T) {
+	numSimulations := 100_000
+	result, err := montecargo.JointMonteCarloSimulation(jointEvents, numSimulations, jointDependencies)
+	assert.NoError(t, err)
+
+	ransomware := result.EventResults["Ransomware Attack"]
+	breach := result.EventResults["Data Breach"]
+
+	// every ransomware trial must also be a breach trial
+	assert.Equal(t, ransomware.Sum, result.CoOccurrences["Ransomware Attack"]["Data Breach"])
+	assert.Equal(t, ransomware.Sum, result.CoOccurrences["Data Breach"]["Ransomware Attack"])
+
+	// a breach never happens in a trial where it was detected
+	assert.Zero(t, result.CoOccurrences["Data Breach"]["Network Detection"])
+
+	// P(breach) = P(no detection) * 0.6, P(ransomware) = P(breach) * 0.4
+	assert.InDelta(t, 0.3, float64(breach.Sum)/float64(numSimulations), 0.01)
+	joint, err := montecargo.JointProbability(result, numSimulations, "Data Breach", "Ransomware Attack")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.12, joint, 0.01)
+
+	_, err = montecargo.JointProbability(result, numSimulations, "Data Breach", "Phishing")
+	assert.ErrorIs(t, err, montecargo.ErrUnknownEvent)
+
+	// the levels strategy doesn't record co-occurrences
+	_, err = montecargo.JointProbability(montecargo.SimulationResult{EventResults: result.EventResults}, numSimulations, "Data Breach", "Ransomware Attack")
+	assert.ErrorIs(t, err, montecargo.ErrNoCoOccurrences)
+}
+
+func TestJointSimulationRunsEveryTrial(t *testing.T) {
+	numSimulations := 10_007
+	events := []montecargo.Event{{Name: "Certain Event", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.Yearly}}
+
+	result, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, numSimulations, result.EventResults["Certain Event"].Sum)
+	assert.Equal(t, 1.0, result.EventStats["Certain Event"].Probability)
+}
@@ -0,0 +1,108 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	testing_utils "github.com/bcdannyboy/montecargo/testing/testing_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var controlledEvents = []montecargo.Event{
+	{
+		Name:      "Data Breach",
+		LowerProb: 0.5,
+		UpperProb: 0.5,
+		Timeframe: montecargo.Yearly,
+		MinImpact: testing_utils.Float64Pointer(100_000),
+		MaxImpact: testing_utils.Float64Pointer(1_000_000),
+	},
+	{
+		Name:                      "Breach Detected",
+		LowerProb:                 0.5,
+		UpperProb:                 0.5,
+		Timeframe:                 montecargo.Yearly,
+		IsCostSaving:              true,
+		CostOfImplementationLower: testing_utils.Float64Pointer(20_000),
+		CostOfImplementationUpper: testing_utils.Float64Pointer(40_000),
+	},
+}
+
+var controlledDependencies = map[string][]montecargo.Dependency{
+	"Data Breach": {
+		{EventName: "Breach Detected", Condition: "not happens"},
+	},
+}
+
+var breachTolerance = []montecargo.TolerancePoint{
+	{Loss: 1_000_000, Probability: 0.01},
+	{Loss: 10_000, Probability: 0.5},
+}
+
+func TestLossExceedanceCurve(t *testing.T) {
+	simulator := montecargo.NewSimulator(montecargo.WithSeed(23), montecargo.WithStrategy(montecargo.StrategyJoint))
+	curve, err := simulator.LossExceedance(context.Background(), controlledEvents, 100_000, controlledDependencies, breachTolerance)
+	assert.NoError(t, err)
+
+	first := curve.Points[0]
+	assert.InDelta(t, 100_000, first.Loss, 1_000)
+	assert.InDelta(t, 0.5, first.Inherent, 0.01)
+	assert.InDelta(t, 0.25, first.Residual, 0.01)
+
+	for i := 1; i < len(curve.Points); i++ {
+		assert.Greater(t, curve.Points[i].Loss, curve.Points[i-1].Loss)
+		assert.LessOrEqual(t, curve.Points[i].Residual, curve.Points[i-1].Residual)
+		assert.LessOrEqual(t, curve.Points[i].Residual, curve.Points[i].Inherent)
+	}
+
+	// residual risk rises above tolerance in the middle of the range and falls back below it
+	assert.True(t, curve.ExceedsTolerance())
+	if assert.Len(t, curve.Crossings, 2) {
+		assert.True(t, curve.Crossings[0].Exceeds)
+		assert.Greater(t, curve.Crossings[0].Loss, 100_000.0)
+		assert.Less(t, curve.Crossings[0].Loss, 300_000.0)
+		assert.False(t, curve.Crossings[1].Exceeds)
+	}
+}
+
+func TestLossExceedanceCurveExports(t *testing.T) {
+	inherent, err := montecargo.JointMonteCarloSimulation(montecargo.WithoutControls(controlledEvents), 10_000, controlledDependencies, montecargo.WithSeed(29))
+	assert.NoError(t, err)
+	residual, err := montecargo.JointMonteCarloSimulation(controlledEvents, 10_000, controlledDependencies, montecargo.WithSeed(29))
+	assert.NoError(t, err)
+	curve := montecargo.NewLossExceedanceCurve(inherent, residual, breachTolerance, 20)
+	assert.Len(t, curve.Points, 20)
+
+	var csvOutput bytes.Buffer
+	assert.NoError(t, curve.WriteCSV(&csvOutput))
+	records, err := csv.NewReader(&csvOutput).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 21)
+	assert.Equal(t, []string{"loss", "inherent", "residual", "tolerance"}, records[0])
+
+	var jsonOutput bytes.Buffer
+	assert.NoError(t, curve.WriteJSON(&jsonOutput))
+	var decoded montecargo.LossExceedanceCurve
+	assert.NoError(t, json.Unmarshal(jsonOutput.Bytes(), &decoded))
+	assert.Equal(t, curve, decoded)
+
+	var svgOutput bytes.Buffer
+	assert.NoError(t, curve.WriteSVG(&svgOutput))
+	svg := svgOutput.String()
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Equal(t, 3, strings.Count(svg, "<polyline"))
+	assert.Equal(t, 1, strings.Count(svg, "http"), "the chart must not reference external resources")
+
+	// without a tolerance there is no tolerance column, line or crossing
+	untolerated := montecargo.NewLossExceedanceCurve(inherent, residual, nil, 20)
+	assert.Nil(t, untolerated.Points[0].Tolerance)
+	assert.Empty(t, untolerated.Crossings)
+	svgOutput.Reset()
+	assert.NoError(t, untolerated.WriteSVG(&svgOutput))
+	assert.Equal(t, 2, strings.Count(svgOutput.String(), "<polyline"))
+}
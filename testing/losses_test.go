@@ -0,0 +1,107 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	testing_utils "github.com/bcdannyboy/montecargo/testing/testing_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLossDistribution(t *testing.T) {
+	losses := make([]float64, 100)
+	for i := range losses {
+		losses[len(losses)-1-i] = float64(i + 1)
+	}
+	distribution := montecargo.NewLossDistribution(losses)
+
+	assert.Equal(t, 100, distribution.Len())
+	assert.InDelta(t, 50.5, distribution.Mean(), 1e-9)
+	assert.InDelta(t, 50.5, distribution.Percentile(0.5), 1e-9)
+	assert.InDelta(t, 1, distribution.Percentile(0), 1e-9)
+	assert.InDelta(t, 100, distribution.Percentile(1), 1e-9)
+	assert.InDelta(t, 95.5, distribution.ExpectedShortfall(0.9), 1e-9)
+	assert.InDelta(t, 0.1, distribution.ExceedanceProbability(90), 1e-9)
+	assert.Equal(t, 100.0, distribution.Max())
+
+	// the input isn't reordered
+	assert.Equal(t, 100.0, losses[0])
+}
+
+func TestExpectedShortfallTailSize(t *testing.T) {
+	losses := make([]float64, 1000)
+	for i := range losses {
+		losses[i] = float64(i + 1)
+	}
+	distribution := montecargo.NewLossDistribution(losses)
+
+	// the mean of the worst 50, 10 and 1 trials, not of one more
+	assert.Equal(t, 975.5, distribution.ExpectedShortfall(0.95))
+	assert.Equal(t, 995.5, distribution.ExpectedShortfall(0.99))
+	assert.Equal(t, 1000.0, distribution.ExpectedShortfall(0.999))
+}
+
+func TestLossReport(t *testing.T) {
+	numSimulations := 200_000
+	events := []montecargo.Event{
+		{
+			Name:      "Certain Loss",
+			LowerProb: 1,
+			UpperProb: 1,
+			Timeframe: montecargo.Yearly,
+			MinImpact: testing_utils.Float64Pointer(0),
+			MaxImpact: testing_utils.Float64Pointer(1_000),
+		},
+		{
+			Name:      "Rare Loss",
+			LowerProb: 0.01,
+			UpperProb: 0.01,
+			Timeframe: montecargo.Yearly,
+			MinImpact: testing_utils.Float64Pointer(1_000_000),
+			MaxImpact: testing_utils.Float64Pointer(1_000_000),
+		},
+		{
+			Name:                      "Control",
+			LowerProb:                 1,
+			UpperProb:                 1,
+			Timeframe:                 montecargo.Yearly,
+			MinImpact:                 testing_utils.Float64Pointer(500),
+			MaxImpact:                 testing_utils.Float64Pointer(500),
+			IsCostSaving:              true,
+			CostOfImplementationLower: testing_utils.Float64Pointer(100),
+			CostOfImplementationUpper: testing_utils.Float64Pointer(200),
+		},
+	}
+
+	result, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(17))
+	assert.NoError(t, err)
+	assert.Len(t, result.TrialLosses, numSimulations)
+
+	report := montecargo.NewLossReport(result, 0.99)
+	assert.Equal(t, numSimulations, report.Trials)
+	assert.InEpsilon(t, 500+10_000, report.Mean, 0.05)
+	assert.InEpsilon(t, result.EventStats["Certain Loss"].Loss.ExpectedLoss+result.EventStats["Rare Loss"].Loss.ExpectedLoss, report.Mean, 1e-9)
+
+	// below the 99th percentile only the certain loss contributes
+	assert.InDelta(t, 500, report.P50, 10)
+	assert.InDelta(t, 900, report.P90, 10)
+	assert.InDelta(t, 950, report.P95, 10)
+	assert.Greater(t, report.P999, 1_000_000.0)
+	assert.Equal(t, report.P99, report.VaR)
+
+	// the worst 1% of trials are about the ones with the rare loss
+	assert.InEpsilon(t, 1_000_500, report.TVaR, 0.05)
+	assert.LessOrEqual(t, report.Max, 1_001_000.0)
+	assert.GreaterOrEqual(t, report.Max, report.P999)
+}
+
+func TestTrialLossesAcrossLevels(t *testing.T) {
+	numSimulations := 10_007
+	result, err := montecargo.MonteCarloSimulation(chainEvents, numSimulations, chainDependencies, montecargo.WithSeed(19), montecargo.WithWorkers(3))
+	assert.NoError(t, err)
+	assert.Len(t, result.TrialLosses, numSimulations)
+
+	again, err := montecargo.MonteCarloSimulation(chainEvents, numSimulations, chainDependencies, montecargo.WithSeed(19), montecargo.WithWorkers(3))
+	assert.NoError(t, err)
+	assert.Equal(t, result.TrialLosses, again.TrialLosses)
+}
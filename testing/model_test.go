@@ -0,0 +1,170 @@
+package testing
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+const breachModel = `version: 1
+settings:
+  trials: 20000
+  seed: 42
+  horizon: P1Y
+  strategy: joint
+  sampling: beta
+events:
+  - name: Data Breach
+    lowerProb: 0.15
+    upperProb: 0.9
+    lowerProbStdDev: 0.1
+    upperProbStdDev: 0.15
+    confidence: 0.8
+    timeframe: every 5 years
+    impactDistribution:
+      type: lognormal
+      lower: 100000
+      upper: 300000000
+  - name: Phishing
+    timeframe: P1Y
+    frequency: negative binomial
+    annualRate: 12
+    dispersion: 2
+    minImpact: 1000
+    maxImpact: 25000
+  - name: Network-Level Breach Detected
+    lowerProb: 0.45
+    upperProb: 0.75
+    timeframe: P2Y
+    isCostSaving: true
+    costOfImplementationLower: 50000
+    costOfImplementationUpper: 200000
+dependencies:
+  Data Breach:
+    - event: Network-Level Breach Detected
+      condition: not happens
+`
+
+func TestParseModel(t *testing.T) {
+	model, err := montecargo.ParseModel([]byte(breachModel), "breach.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, montecargo.ModelVersion, model.Version)
+	assert.Len(t, model.Events, 3)
+
+	breach := model.Events[0]
+	assert.Equal(t, "Data Breach", breach.Name)
+	assert.Equal(t, montecargo.EveryFiveYears, breach.Timeframe)
+	assert.Equal(t, montecargo.LogNormalFrom90CI(100_000, 300_000_000), breach.ImpactDistribution)
+	assert.Equal(t, 0.1, *breach.LowerProbStdDev)
+
+	phishing := model.Events[1]
+	assert.Equal(t, montecargo.FrequencyNegativeBinomial, phishing.Frequency)
+	assert.Equal(t, 12.0, *phishing.AnnualRate)
+
+	assert.True(t, model.Events[2].IsCostSaving)
+	assert.Equal(t, []montecargo.Dependency{{EventName: "Network-Level Breach Detected", Condition: "not happens"}}, model.Dependencies["Data Breach"])
+
+	assert.Equal(t, 20_000, model.Settings.Trials)
+	assert.Equal(t, int64(42), *model.Settings.Seed)
+	assert.Equal(t, montecargo.StrategyJoint, model.Settings.Strategy)
+	assert.Equal(t, montecargo.SampleBeta, model.Settings.Sampling)
+
+	result, err := montecargo.JointMonteCarloSimulation(model.Events, 1_000, model.Dependencies, model.Settings.Options()...)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), result.Seed)
+}
+
+func TestModelRoundTrips(t *testing.T) {
+	model, err := montecargo.ParseModel([]byte(breachModel), "breach.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var yamlOutput bytes.Buffer
+	assert.NoError(t, model.WriteYAML(&yamlOutput))
+	fromYAML, err := montecargo.ParseModel(yamlOutput.Bytes(), "saved.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, model, fromYAML)
+
+	path := filepath.Join(t.TempDir(), "breach.json")
+	assert.NoError(t, montecargo.SaveModel(path, model))
+	fromJSON, err := montecargo.LoadModel(path)
+	assert.NoError(t, err)
+	assert.Equal(t, model, fromJSON)
+}
+
+func TestModelErrorsHavePositions(t *testing.T) {
+	tests := []struct {
+		name, model, want string
+	}{
+		{
+			name:  "unknown field",
+			model: "version: 1\nevents:\n  - name: A\n    timeframe: P1Y\n    lowerProbability: 0.2\n",
+			want:  `model.yaml:5: unknown field "lowerProbability"`,
+		},
+		{
+			name:  "type mismatch",
+			model: "version: 1\nevents:\n  - name: A\n    timeframe: P1Y\n    lowerProb: high\n",
+			want:  "model.yaml:5: cannot unmarshal !!str `high` into float64",
+		},
+		{
+			name:  "unknown dependency",
+			model: "version: 1\nevents:\n  - name: A\n    timeframe: P1Y\ndependencies:\n  A:\n    - event: B\n      condition: happens\n",
+			want:  `model.yaml:7:14: event "A" depends on unknown event "B"`,
+		},
+		{
+			name:  "duplicate event",
+			model: "version: 1\nevents:\n  - name: A\n    timeframe: P1Y\n  - name: A\n    timeframe: P1Y\n",
+			want:  `model.yaml:5:11: duplicate event "A", first defined on line 3`,
+		},
+		{
+			name:  "bad timeframe",
+			model: "version: 1\nevents:\n  - name: A\n    timeframe: sometimes\n",
+			want:  `model.yaml:4:16: event "A": unrecognized timeframe "sometimes"`,
+		},
+		{
+			name:  "unsupported version",
+			model: "version: 2\nevents:\n  - name: A\n    timeframe: P1Y\n",
+			want:  "model.yaml:1:10: unsupported version 2, expected 1",
+		},
+		{
+			name:  "cycle",
+			model: "version: 1\nevents:\n  - name: A\n    timeframe: P1Y\n  - name: B\n    timeframe: P1Y\ndependencies:\n  A:\n    - {event: B, condition: happens}\n  B:\n    - {event: A, condition: happens}\n",
+			want:  "model.yaml:8:3: dependency cycle detected: A -> B -> A",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := montecargo.ParseModel([]byte(test.model), "model.yaml")
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.want)
+			}
+		})
+	}
+}
+
+func TestModelErrorsAreOrdered(t *testing.T) {
+	// every error is on the same line, so only their columns order them
+	model := `{"version": 1, "settings": {"strategy": "fastest", "sampling": "random"}, "events": [{"name": "A", "timeframe": "soon", "frequency": "often"}]}`
+
+	for i := 0; i < 20; i++ {
+		_, err := montecargo.ParseModel([]byte(model), "model.json")
+		var modelErrs montecargo.ModelErrors
+		if !assert.ErrorAs(t, err, &modelErrs) {
+			return
+		}
+		assert.Equal(t, []string{
+			`model.json:1:41: unknown strategy "fastest", expected one of "levels", "joint"`,
+			`model.json:1:64: unknown probability sampling "random", expected one of "midpoint", "uniform", "beta"`,
+			`model.json:1:113: event "A": unrecognized timeframe "soon"`,
+			`model.json:1:134: event "A": unknown frequency "often", expected one of "bernoulli", "poisson", "negative binomial"`,
+		}, errorStrings(modelErrs))
+	}
+}
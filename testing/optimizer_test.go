@@ -0,0 +1,139 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	testing_utils "github.com/bcdannyboy/montecargo/testing/testing_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// threatWithControl returns a threat that happens half the time and a control that stops it
+// with the given probability.
+func threatWithControl(name string, impact, detection, cost float64) ([]montecargo.Event, montecargo.Dependency) {
+	control := name + " Control"
+	events := []montecargo.Event{
+		{
+			Name:      name,
+			LowerProb: 0.5,
+			UpperProb: 0.5,
+			Timeframe: montecargo.Yearly,
+			MinImpact: testing_utils.Float64Pointer(impact),
+			MaxImpact: testing_utils.Float64Pointer(impact),
+		},
+		{
+			Name:                      control,
+			LowerProb:                 detection,
+			UpperProb:                 detection,
+			Timeframe:                 montecargo.Yearly,
+			IsCostSaving:              true,
+			CostOfImplementationLower: testing_utils.Float64Pointer(cost),
+			CostOfImplementationUpper: testing_utils.Float64Pointer(cost),
+		},
+	}
+	return events, montecargo.Dependency{EventName: control, Condition: "not happens"}
+}
+
+func TestOptimizeControlsExhaustively(t *testing.T) {
+	var events []montecargo.Event
+	dependencies := make(map[string][]montecargo.Dependency)
+	for _, threat := range []struct {
+		name                    string
+		impact, detection, cost float64
+	}{
+		{"Phishing", 1_000_000, 0.8, 100_000},
+		{"Malware", 500_000, 0.8, 50_000},
+		{"Insider", 2_000_000, 0.5, 150_000},
+	} {
+		threatEvents, dependency := threatWithControl(threat.name, threat.impact, threat.detection, threat.cost)
+		events = append(events, threatEvents...)
+		dependencies[threat.name] = []montecargo.Dependency{dependency}
+	}
+
+	simulator := montecargo.NewSimulator(montecargo.WithSeed(41), montecargo.WithStrategy(montecargo.StrategyJoint))
+	optimization, err := simulator.OptimizeControls(context.Background(), events, 50_000, dependencies, 200_000, montecargo.Objective{})
+	assert.NoError(t, err)
+
+	assert.True(t, optimization.Exhaustive)
+	assert.Empty(t, optimization.Baseline.Controls)
+	assert.InEpsilon(t, 1_750_000, optimization.Baseline.Risk, 0.02)
+
+	// {}, three single controls, and the two affordable pairs
+	assert.Len(t, optimization.Ranked, 6)
+	for _, portfolio := range optimization.Ranked {
+		assert.LessOrEqual(t, portfolio.Cost, 200_000.0)
+	}
+
+	// the malware and insider controls avoid $200,000 and $500,000 a year for $200,000
+	best := optimization.Best()
+	assert.Equal(t, []string{"Malware Control", "Insider Control"}, best.Controls)
+	assert.Equal(t, 200_000.0, best.Cost)
+	assert.InEpsilon(t, 700_000, best.RiskReduction, 0.03)
+
+	for i := 1; i < len(optimization.Frontier); i++ {
+		assert.Greater(t, optimization.Frontier[i].Cost, optimization.Frontier[i-1].Cost)
+		assert.Less(t, optimization.Frontier[i].Risk, optimization.Frontier[i-1].Risk)
+	}
+	assert.Equal(t, best, optimization.Frontier[len(optimization.Frontier)-1])
+}
+
+func TestOptimizeControlsGreedily(t *testing.T) {
+	var events []montecargo.Event
+	dependencies := make(map[string][]montecargo.Dependency)
+	for i := 0; i < 11; i++ {
+		name := fmt.Sprintf("Threat %d", i)
+		threatEvents, dependency := threatWithControl(name, float64(100_000*(i+1)), 0.9, 10_000)
+		events = append(events, threatEvents...)
+		dependencies[name] = []montecargo.Dependency{dependency}
+	}
+
+	simulator := montecargo.NewSimulator(montecargo.WithSeed(43), montecargo.WithStrategy(montecargo.StrategyJoint))
+	optimization, err := simulator.OptimizeControls(context.Background(), events, 2_000, dependencies, 55_000, montecargo.Objective{Percentile: 0.9})
+	assert.NoError(t, err)
+
+	// five controls fit the budget, and the largest threats are worth controlling first
+	assert.False(t, optimization.Exhaustive)
+	best := optimization.Best()
+	assert.Len(t, best.Controls, 5)
+	assert.Equal(t, 50_000.0, best.Cost)
+	assert.Contains(t, best.Controls, "Threat 10 Control")
+	assert.Less(t, best.Risk, optimization.Baseline.Risk)
+}
+
+func TestOptimizeControlsCountsSavings(t *testing.T) {
+	events, dependency := threatWithControl("Phishing", 200_000, 0.8, 50_000)
+	// insurance doesn't stop anything, its value is the payout it saves
+	events = append(events, montecargo.Event{
+		Name:                      "Cyber Insurance",
+		LowerProb:                 1,
+		UpperProb:                 1,
+		Timeframe:                 montecargo.Yearly,
+		IsCostSaving:              true,
+		MinImpact:                 testing_utils.Float64Pointer(150_000),
+		MaxImpact:                 testing_utils.Float64Pointer(150_000),
+		CostOfImplementationLower: testing_utils.Float64Pointer(40_000),
+		CostOfImplementationUpper: testing_utils.Float64Pointer(60_000),
+	})
+	dependencies := map[string][]montecargo.Dependency{"Phishing": {dependency}}
+
+	simulator := montecargo.NewSimulator(montecargo.WithSeed(47), montecargo.WithStrategy(montecargo.StrategyJoint))
+	optimization, err := simulator.OptimizeControls(context.Background(), events, 20_000, dependencies, 60_000, montecargo.Objective{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the phishing control avoids $80,000 a year, insurance saves $150,000
+	best := optimization.Best()
+	assert.Equal(t, []string{"Cyber Insurance"}, best.Controls)
+	assert.InEpsilon(t, 150_000, best.RiskReduction, 0.02)
+
+	// the optimizer agrees with the control analysis on costs and on which control is worth more
+	analyses, err := simulator.AnalyzeControls(context.Background(), events, 20_000, dependencies)
+	if assert.NoError(t, err) && assert.Len(t, analyses, 2) {
+		assert.Equal(t, "Cyber Insurance", analyses[1].Control)
+		assert.Greater(t, analyses[1].ROSI, analyses[0].ROSI)
+		assert.InDelta(t, analyses[1].ExpectedCost, best.Cost, 1e-6)
+	}
+}
@@ -249,7 +249,8 @@ func TestProbabilityCalculations(t *testing.T) {
 	dependencies := map[string][]montecargo.Dependency{}
 	numSimulations := 1_000_000
 	fmt.Printf("Running %d simulations on %d events...\n", numSimulations, len(shuffled_events))
-	simulationResult := montecargo.MonteCarloSimulation(shuffled_events, numSimulations, dependencies)
+	simulationResult, err := montecargo.MonteCarloSimulation(shuffled_events, numSimulations, dependencies)
+	assert.NoError(t, err)
 
 	for _, event := range shuffled_events {
 		eventStat := simulationResult.EventStats[event.Name]
@@ -286,7 +287,8 @@ func TestProbabilityCalculations(t *testing.T) {
 		confidenceScores := make([]float64, numRuns)
 		for i := 0; i < numRuns; i++ {
 			fmt.Printf("\r - Running simulation %d/%d...", i+1, numRuns)
-			simResult := montecargo.MonteCarloSimulation(shuffled_events, numSimulations, dependencies)
+			simResult, err := montecargo.MonteCarloSimulation(shuffled_events, numSimulations, dependencies)
+			assert.NoError(t, err)
 			eventStat := simResult.EventStats[event.Name]
 			actualAvg := eventStat.Probability
 			errorRate := math.Abs(actualAvg - expectedAvg)
@@ -325,7 +327,8 @@ func TestProbabilityConvergence(t *testing.T) {
 
 	for _, sims := range numSimulations {
 
-		results := montecargo.MonteCarloSimulation(events, sims, dependencies)
+		results, err := montecargo.MonteCarloSimulation(events, sims, dependencies)
+		assert.NoError(t, err)
 
 		for _, event := range events {
 
@@ -0,0 +1,77 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbabilitySamplingKeepsRangeMean(t *testing.T) {
+	lowerStdDev, upperStdDev := 0.05, 0.05
+	events := []montecargo.Event{
+		{
+			Name:            "Uncertain Event",
+			LowerProb:       0.2,
+			UpperProb:       0.6,
+			LowerProbStdDev: &lowerStdDev,
+			UpperProbStdDev: &upperStdDev,
+			Timeframe:       montecargo.Yearly,
+		},
+	}
+
+	numSimulations := 200_000
+	for _, sampling := range []montecargo.ProbabilitySampling{montecargo.SampleUniform, montecargo.SampleBeta, montecargo.SampleMidpoint} {
+		t.Run(sampling.String(), func(t *testing.T) {
+			result, err := montecargo.JointMonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(7), montecargo.WithProbabilitySampling(sampling))
+			assert.NoError(t, err)
+			assert.InDelta(t, 0.4, result.EventStats["Uncertain Event"].Probability, 0.01)
+		})
+	}
+}
+
+func TestProbabilitySamplingIsReproducible(t *testing.T) {
+	stdDev := 0.1
+	events := []montecargo.Event{{Name: "Uncertain Event", LowerProb: 0.1, UpperProb: 0.3, LowerProbStdDev: &stdDev, UpperProbStdDev: &stdDev, Timeframe: montecargo.Monthly}}
+
+	first, err := montecargo.JointMonteCarloSimulation(events, 10_000, nil, montecargo.WithSeed(3), montecargo.WithProbabilitySampling(montecargo.SampleBeta))
+	assert.NoError(t, err)
+	second, err := montecargo.JointMonteCarloSimulation(events, 10_000, nil, montecargo.WithSeed(3), montecargo.WithProbabilitySampling(montecargo.SampleBeta))
+	assert.NoError(t, err)
+
+	assert.Equal(t, first.EventResults, second.EventResults)
+}
+
+func TestProbabilitySamplingWithoutSpread(t *testing.T) {
+	events := []montecargo.Event{{Name: "Fixed Event", LowerProb: 0.25, UpperProb: 0.25, Timeframe: montecargo.Yearly}}
+
+	for _, sampling := range []montecargo.ProbabilitySampling{montecargo.SampleUniform, montecargo.SampleBeta} {
+		result, err := montecargo.JointMonteCarloSimulation(events, 100_000, nil, montecargo.WithSeed(11), montecargo.WithProbabilitySampling(sampling))
+		assert.NoError(t, err)
+		assert.InDelta(t, 0.25, result.EventStats["Fixed Event"].Probability, 0.01, sampling.String())
+	}
+}
+
+func TestProbabilitySamplingKeepsMeanNearBounds(t *testing.T) {
+	for _, event := range []montecargo.Event{
+		{Name: "Rare Event", LowerProb: 0.01, UpperProb: 0.2, Confidence: 0.5, Timeframe: montecargo.Yearly},
+		{Name: "Likely Event", LowerProb: 0.8, UpperProb: 0.99, Confidence: 0.5, Timeframe: montecargo.Yearly},
+	} {
+		// widened by half the confidence the range runs past 0 or 1, clamping it would move the mean
+		result, err := montecargo.JointMonteCarloSimulation([]montecargo.Event{event}, 200_000, nil, montecargo.WithSeed(13), montecargo.WithProbabilitySampling(montecargo.SampleUniform))
+		assert.NoError(t, err)
+		assert.InDelta(t, (event.LowerProb+event.UpperProb)/2, result.EventStats[event.Name].Probability, 0.005, event.Name)
+	}
+}
+
+func TestProbabilitySamplingDefaultsToMidpoint(t *testing.T) {
+	stdDev := 0.1
+	events := []montecargo.Event{{Name: "Uncertain Event", LowerProb: 0.1, UpperProb: 0.3, LowerProbStdDev: &stdDev, UpperProbStdDev: &stdDev, Timeframe: montecargo.Yearly}}
+
+	byDefault, err := montecargo.JointMonteCarloSimulation(events, 10_000, nil, montecargo.WithSeed(17))
+	assert.NoError(t, err)
+	midpoint, err := montecargo.JointMonteCarloSimulation(events, 10_000, nil, montecargo.WithSeed(17), montecargo.WithProbabilitySampling(montecargo.SampleMidpoint))
+	assert.NoError(t, err)
+
+	assert.Equal(t, midpoint.EventResults, byDefault.EventResults)
+}
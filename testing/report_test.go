@@ -0,0 +1,68 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRiskReportHTML(t *testing.T) {
+	model := &montecargo.Model{Events: controlledEvents, Dependencies: controlledDependencies, Settings: montecargo.ModelSettings{Strategy: montecargo.StrategyJoint}}
+	simulator := montecargo.NewSimulator(montecargo.WithSeed(23), montecargo.WithStrategy(montecargo.StrategyJoint))
+	report, err := simulator.Report(context.Background(), model, 20_000, breachTolerance)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, report.Controls, 1)
+	assert.NotEmpty(t, report.Curve.Points)
+	assert.Equal(t, int64(23), report.Metadata.Seed)
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, report.WriteHTML(&buf)) {
+		return
+	}
+	html := buf.String()
+
+	for _, section := range []string{"Executive Summary", "Loss Exceedance Curve", "Events", "Control Return on Investment", "Dependencies", "Run Metadata"} {
+		assert.Contains(t, html, "<h2>"+section+"</h2>")
+	}
+	assert.Contains(t, html, "Residual risk exceeds the risk tolerance")
+	assert.Contains(t, html, report.Metadata.InputHash)
+	assert.Contains(t, html, "Breach Detected")
+	assert.Equal(t, 2, strings.Count(html, "<svg"), "the curve and the dependency diagram are inline SVG")
+
+	// the report must open offline: no scripts and no external resources
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "<link")
+	for _, url := range regexp.MustCompile(`https?://[^"'\s)]+`).FindAllString(html, -1) {
+		assert.Equal(t, "http://www.w3.org/2000/svg", url)
+	}
+}
+
+func TestModelDiagramsEscapeEventNames(t *testing.T) {
+	model := &montecargo.Model{
+		Events: []montecargo.Event{
+			{Name: `Breach <"core">`, LowerProb: 0.1, UpperProb: 0.2},
+			{Name: "Detection", LowerProb: 0.5, UpperProb: 0.5, IsCostSaving: true},
+		},
+		Dependencies: map[string][]montecargo.Dependency{
+			`Breach <"core">`: {{EventName: "Detection", Condition: "not happens"}},
+		},
+	}
+
+	var svg, dot, mermaid bytes.Buffer
+	assert.NoError(t, model.WriteSVG(&svg))
+	assert.NoError(t, model.WriteDOT(&dot))
+	assert.NoError(t, model.WriteMermaid(&mermaid))
+
+	assert.Contains(t, svg.String(), "Breach &lt;&#34;core&#34;&gt;")
+	assert.Contains(t, svg.String(), `stroke-dasharray="5 4" marker-end`)
+	assert.Contains(t, dot.String(), `"Detection" -> "Breach <\"core\">" [label="not happens", style=dashed];`)
+	assert.Contains(t, mermaid.String(), `e0["Breach <#quot;core#quot;>"]`)
+	assert.Contains(t, mermaid.String(), `e1 -.->|"not happens"| e0`)
+}
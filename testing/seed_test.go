@@ -0,0 +1,40 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeededSimulationsAreReproducible(t *testing.T) {
+	numSimulations := 50_000
+
+	first, err := montecargo.MonteCarloSimulation(chainEvents, numSimulations, chainDependencies, montecargo.WithSeed(42))
+	assert.NoError(t, err)
+	second, err := montecargo.MonteCarloSimulation(chainEvents, numSimulations, chainDependencies, montecargo.WithSeed(42))
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int64(42), first.Seed)
+
+	other, err := montecargo.MonteCarloSimulation(chainEvents, numSimulations, chainDependencies, montecargo.WithSeed(43))
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.EventResults, other.EventResults)
+
+	jointFirst, err := montecargo.JointMonteCarloSimulation(chainEvents, numSimulations, chainDependencies, montecargo.WithSeed(7))
+	assert.NoError(t, err)
+	jointSecond, err := montecargo.JointMonteCarloSimulation(chainEvents, numSimulations, chainDependencies, montecargo.WithSeed(7))
+	assert.NoError(t, err)
+	assert.Equal(t, jointFirst, jointSecond)
+}
+
+func TestUnseededSimulationsRecordTheirSeed(t *testing.T) {
+	numSimulations := 10_000
+
+	first, err := montecargo.MonteCarloSimulation(events, numSimulations, nil)
+	assert.NoError(t, err)
+	replay, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(first.Seed))
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, replay)
+}
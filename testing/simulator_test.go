@@ -0,0 +1,136 @@
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulatorReportsProgress(t *testing.T) {
+	numSimulations := 20_000
+	last, calls := 0, 0
+	simulator := montecargo.NewSimulator(
+		montecargo.WithSeed(1),
+		montecargo.WithWorkers(3),
+		montecargo.WithStrategy(montecargo.StrategyJoint),
+		montecargo.WithProgress(func(completed, total int) {
+			assert.GreaterOrEqual(t, completed, last)
+			assert.Equal(t, numSimulations, total)
+			last = completed
+			calls++
+		}),
+	)
+
+	result, err := simulator.Run(context.Background(), chainEvents, numSimulations, chainDependencies)
+	assert.NoError(t, err)
+	assert.False(t, result.Incomplete)
+	assert.Equal(t, numSimulations, result.Trials)
+	assert.Equal(t, numSimulations, last)
+	assert.Greater(t, calls, 1)
+}
+
+func TestSimulatorStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	simulator := montecargo.NewSimulator(
+		montecargo.WithWorkers(2),
+		montecargo.WithStrategy(montecargo.StrategyJoint),
+		montecargo.WithProgress(func(completed, total int) {
+			cancel()
+		}),
+	)
+
+	numSimulations := 10_000_000
+	result, err := simulator.Run(ctx, chainEvents, numSimulations, chainDependencies)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.True(t, result.Incomplete)
+	assert.Greater(t, result.Trials, 0)
+	assert.Less(t, result.Trials, numSimulations)
+	assert.Equal(t, result.Trials, result.EventResults["Network Detection"].Trials)
+	assert.Len(t, result.TrialLosses, result.Trials)
+}
+
+func TestSimulatorKeepsTrialsCompletedInEveryLevel(t *testing.T) {
+	numSimulations := 100_000
+	ctx, cancel := context.WithCancel(context.Background())
+	simulator := montecargo.NewSimulator(
+		montecargo.WithWorkers(2),
+		montecargo.WithStrategy(montecargo.StrategyLevels),
+		montecargo.WithProgress(func(completed, total int) {
+			// the first level completes, the second is cancelled partway
+			if completed > numSimulations {
+				cancel()
+			}
+		}),
+	)
+
+	result, err := simulator.Run(ctx, chainEvents, numSimulations, chainDependencies)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.True(t, result.Incomplete)
+	assert.Less(t, result.Trials, numSimulations)
+	assert.Len(t, result.TrialLosses, result.Trials)
+	assert.Len(t, result.TrialSavings, result.Trials)
+	assert.Equal(t, numSimulations, result.EventResults["Network Detection"].Trials)
+	assert.LessOrEqual(t, result.Trials, result.EventResults["Data Breach"].Trials)
+}
+
+func TestSimulatorWithoutTrialLosses(t *testing.T) {
+	for _, strategy := range []montecargo.Strategy{montecargo.StrategyLevels, montecargo.StrategyJoint} {
+		kept, err := montecargo.NewSimulator(montecargo.WithSeed(5), montecargo.WithWorkers(3), montecargo.WithStrategy(strategy)).
+			Run(context.Background(), chainEvents, 10_000, chainDependencies)
+		assert.NoError(t, err)
+		discarded, err := montecargo.NewSimulator(montecargo.WithSeed(5), montecargo.WithWorkers(3), montecargo.WithStrategy(strategy), montecargo.WithoutTrialLosses()).
+			Run(context.Background(), chainEvents, 10_000, chainDependencies)
+		assert.NoError(t, err)
+
+		assert.Len(t, kept.TrialLosses, 10_000)
+		assert.Nil(t, discarded.TrialLosses)
+		assert.Nil(t, discarded.TrialSavings)
+		assert.Equal(t, 10_000, discarded.Trials)
+		assert.Equal(t, kept.EventResults, discarded.EventResults)
+		assert.Equal(t, kept.EventStats, discarded.EventStats)
+	}
+
+	// control analyses compare the trials of their runs, so they keep them regardless
+	events, dependency := threatWithControl("Phishing", 100_000, 0.8, 10_000)
+	simulator := montecargo.NewSimulator(montecargo.WithSeed(5), montecargo.WithoutTrialLosses())
+	analyses, err := simulator.AnalyzeControls(context.Background(), events, 10_000, map[string][]montecargo.Dependency{"Phishing": {dependency}})
+	if assert.NoError(t, err) && assert.Len(t, analyses, 1) {
+		assert.Equal(t, 10_000, analyses[0].AvoidedLoss.Trials)
+	}
+}
+
+func TestSimulatorWithWorkersIsReproducible(t *testing.T) {
+	run := func() montecargo.SimulationResult {
+		simulator := montecargo.NewSimulator(montecargo.WithSeed(99), montecargo.WithWorkers(4))
+		result, err := simulator.Run(context.Background(), chainEvents, 40_000, chainDependencies)
+		assert.NoError(t, err)
+		return result
+	}
+
+	assert.Equal(t, run(), run())
+}
+
+func TestSimulatorRunsExactlyNumSimulations(t *testing.T) {
+	numSimulations := 10_007
+	certain := []montecargo.Event{
+		{Name: "Certain Event", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.Yearly},
+		{Name: "Certain Dependent", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.Yearly},
+	}
+	dependencies := map[string][]montecargo.Dependency{
+		"Certain Dependent": {{EventName: "Certain Event", Condition: "happens"}},
+	}
+
+	for _, strategy := range []montecargo.Strategy{montecargo.StrategyLevels, montecargo.StrategyJoint} {
+		simulator := montecargo.NewSimulator(montecargo.WithWorkers(3), montecargo.WithStrategy(strategy))
+		result, err := simulator.Run(context.Background(), certain, numSimulations, dependencies)
+		assert.NoError(t, err)
+
+		assert.Equal(t, numSimulations, result.Trials)
+		for _, event := range certain {
+			assert.Equal(t, numSimulations, result.EventResults[event.Name].Trials)
+			assert.Equal(t, numSimulations, result.EventResults[event.Name].Sum)
+		}
+	}
+}
@@ -118,28 +118,10 @@ func DetermineConfidenceThreshold(event montecargo.Event) float64 {
 	return math.Max(0.5, math.Min(0.9, adjustedThreshold))
 }
 
-// AdjustProbabilityForTimeframe adjusts the probability of an event based on its timeframe.
+// AdjustProbabilityForTimeframe converts the probability of an event over its timeframe into
+// the probability of it occurring at least once within a year.
 func AdjustProbabilityForTimeframe(probability float64, timeframe montecargo.Timeframe) float64 {
-
-	switch timeframe {
-	case montecargo.Daily:
-		return probability / 365
-	case montecargo.Weekly:
-		return probability / 52
-	case montecargo.Monthly:
-		return probability / 12
-	case montecargo.Yearly:
-		return probability
-	case montecargo.EveryTwoYears:
-		return probability * 2
-	case montecargo.EveryFiveYears:
-		return probability * 5
-	case montecargo.EveryTenYears:
-		return probability * 10
-	default:
-		return probability
-	}
-
+	return 1 - math.Pow(1-probability, montecargo.GetOccurrencesPerYear(timeframe))
 }
 
 // AdjustProbabilityWithConfidenceStdDev adjusts the probability based on a normal distribution
@@ -0,0 +1,141 @@
+package testing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertProbability(t *testing.T) {
+	// 0.3 every ten years is about 3.5% per year, not 300%
+	yearly := montecargo.ConvertProbability(0.3, montecargo.EveryTenYears, montecargo.Yearly)
+	assert.InDelta(t, 1-math.Pow(0.7, 0.1), yearly, 1e-12)
+	assert.InDelta(t, 0.3, montecargo.ConvertProbability(yearly, montecargo.Yearly, montecargo.EveryTenYears), 1e-12)
+
+	timeframes := []montecargo.Timeframe{
+		montecargo.Daily, montecargo.Weekly, montecargo.Monthly, montecargo.Yearly,
+		montecargo.EveryTwoYears, montecargo.EveryFiveYears, montecargo.EveryTenYears,
+	}
+	for _, from := range timeframes {
+		for _, to := range timeframes {
+			for _, p := range []float64{0, 0.001, 0.3, 0.9, 0.999, 1} {
+				converted := montecargo.ConvertProbability(p, from, to)
+				assert.GreaterOrEqual(t, converted, 0.0)
+				assert.LessOrEqual(t, converted, 1.0)
+				// near certainty the round trip loses precision
+				if converted < 0.99 {
+					assert.InDelta(t, p, montecargo.ConvertProbability(converted, to, from), 1e-9)
+				}
+			}
+		}
+	}
+}
+
+func TestCheckTimeframes(t *testing.T) {
+	events := []montecargo.Event{
+		{Name: "Valid", LowerProb: 0.1, UpperProb: 0.3, Timeframe: montecargo.Yearly},
+		{Name: "Rate As Probability", LowerProb: 2, UpperProb: 4, Timeframe: montecargo.Yearly},
+		{Name: "Certain Decade", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.EveryTenYears},
+		{Name: "Frequent Daily", LowerProb: 0.9, UpperProb: 0.95, Timeframe: montecargo.Daily},
+	}
+
+	warnings := montecargo.CheckTimeframes(events, montecargo.Yearly)
+
+	var names []string
+	for _, warning := range warnings {
+		names = append(names, warning.EventName)
+	}
+	assert.Equal(t, []string{"Rate As Probability", "Certain Decade", "Frequent Daily"}, names)
+
+	// a probability above 1 is an error, so only the others can be simulated
+	valid := append([]montecargo.Event{events[0]}, events[2:]...)
+	result, err := montecargo.MonteCarloSimulation(valid, 1_000, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, montecargo.CheckTimeframes(valid, montecargo.Yearly), result.Warnings)
+	assert.Len(t, result.Warnings, 2)
+}
+
+func TestSimulationHorizon(t *testing.T) {
+	numSimulations := 200_000
+	events := []montecargo.Event{
+		{Name: "Decade Event", LowerProb: 0.3, UpperProb: 0.3, Timeframe: montecargo.EveryTenYears},
+		{Name: "Monthly Event", LowerProb: 0.1, UpperProb: 0.1, Timeframe: montecargo.Monthly},
+	}
+
+	yearly, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(5))
+	assert.NoError(t, err)
+	assert.InDelta(t, 1-math.Pow(0.7, 0.1), yearly.EventStats["Decade Event"].Probability, 0.002)
+	assert.InDelta(t, 1-math.Pow(0.9, 12), yearly.EventStats["Monthly Event"].Probability, 0.003)
+
+	fiveYears, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(5), montecargo.WithHorizon(montecargo.EveryFiveYears))
+	assert.NoError(t, err)
+	assert.Equal(t, montecargo.EveryFiveYears, fiveYears.Horizon)
+	assert.InDelta(t, 1-math.Pow(0.7, 0.5), fiveYears.EventStats["Decade Event"].Probability, 0.003)
+	assert.InDelta(t, 1-math.Pow(0.9, 60), fiveYears.EventStats["Monthly Event"].Probability, 0.002)
+}
+
+func TestParseTimeframe(t *testing.T) {
+	valid := map[string]montecargo.Timeframe{
+		"daily":               montecargo.Daily,
+		"weekly":              montecargo.Weekly,
+		"monthly":             montecargo.Monthly,
+		"Quarterly":           montecargo.Quarterly,
+		"yearly":              montecargo.Yearly,
+		"annually":            montecargo.Yearly,
+		"2 years":             montecargo.EveryTwoYears,
+		"5 years":             montecargo.EveryFiveYears,
+		"10 years":            montecargo.EveryTenYears,
+		"every 18 months":     18 * montecargo.Monthly,
+		"per 3-year contract": 3 * montecargo.Yearly,
+		"a decade":            montecargo.EveryTenYears,
+		"90 days":             90 * montecargo.Daily,
+		"P1Y":                 montecargo.Yearly,
+		"P18M":                18 * montecargo.Monthly,
+		"P1Y6M":               18 * montecargo.Monthly,
+		"P2W":                 2 * montecargo.Weekly,
+		"P1.5Y":               18 * montecargo.Monthly,
+		"PT12H":               montecargo.Daily / 2,
+	}
+	for input, expected := range valid {
+		tf, err := montecargo.ParseTimeframe(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, tf, input)
+
+		roundTrip, err := montecargo.ParseTimeframe(tf.ISO8601())
+		assert.NoError(t, err, input)
+		assert.Equal(t, tf, roundTrip, input)
+	}
+
+	for _, input := range []string{"", "sometimes", "P", "PT", "P-1Y", "0 years", "1 year 6 months", "fortnights ago 3", "1 year please", "every 2 weeks or so"} {
+		_, err := montecargo.ParseTimeframe(input)
+		assert.Error(t, err, input)
+	}
+
+	assert.Equal(t, "18 months", montecargo.TimeframeToString(18*montecargo.Monthly))
+	assert.Equal(t, "3 years", (3 * montecargo.Yearly).String())
+	// a zero Timeframe is a year wherever it is used
+	assert.Equal(t, "1 year", montecargo.TimeframeToString(0))
+	assert.Equal(t, "P1Y", montecargo.Timeframe(0).ISO8601())
+	assert.Equal(t, 1.0, montecargo.Timeframe(0).Years())
+	assert.Equal(t, 1.5, (18 * montecargo.Monthly).Years())
+}
+
+func TestCustomTimeframesAndHorizon(t *testing.T) {
+	numSimulations := 200_000
+	quarterly, err := montecargo.ParseTimeframe("quarterly")
+	assert.NoError(t, err)
+	contract, err := montecargo.ParseTimeframe("per 3-year contract")
+	assert.NoError(t, err)
+
+	events := []montecargo.Event{
+		{Name: "Quarterly Event", LowerProb: 0.1, UpperProb: 0.1, Timeframe: quarterly},
+		{Name: "Contract Event", LowerProb: 0.5, UpperProb: 0.5, Timeframe: contract},
+	}
+
+	result, err := montecargo.MonteCarloSimulation(events, numSimulations, nil, montecargo.WithSeed(9), montecargo.WithHorizon(contract))
+	assert.NoError(t, err)
+	assert.InDelta(t, 1-math.Pow(0.9, 12), result.EventStats["Quarterly Event"].Probability, 0.003)
+	assert.InDelta(t, 0.5, result.EventStats["Contract Event"].Probability, 0.003)
+}
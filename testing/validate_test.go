@@ -0,0 +1,254 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/bcdannyboy/montecargo/internal/cli"
+	"github.com/bcdannyboy/montecargo/montecargo"
+	"github.com/bcdannyboy/montecargo/testing/testing_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	valid := montecargo.Event{
+		Name:      "Data Breach",
+		LowerProb: 0.1,
+		UpperProb: 0.3,
+		Timeframe: montecargo.Yearly,
+		MinImpact: testing_utils.Float64Pointer(1_000),
+		MaxImpact: testing_utils.Float64Pointer(5_000),
+	}
+
+	tests := []struct {
+		name         string
+		event        func(event *montecargo.Event)
+		dependencies map[string][]montecargo.Dependency
+		field        string
+		contains     string
+	}{
+		{
+			name:     "lower probability above upper",
+			event:    func(e *montecargo.Event) { e.LowerProb, e.UpperProb = 0.6, 0.4 },
+			field:    "LowerProb",
+			contains: "LowerProb 0.6 is greater than UpperProb 0.4",
+		},
+		{
+			name:     "probability above 1 over the timeframe",
+			event:    func(e *montecargo.Event) { e.UpperProb = 3 },
+			field:    "UpperProb",
+			contains: "UpperProb 3 per 1 year is greater than 1",
+		},
+		{
+			name:     "negative impact",
+			event:    func(e *montecargo.Event) { e.MinImpact = testing_utils.Float64Pointer(-10) },
+			field:    "MinImpact",
+			contains: "MinImpact -10 is negative",
+		},
+		{
+			name:     "minimum impact without maximum",
+			event:    func(e *montecargo.Event) { e.MaxImpact = nil },
+			field:    "MinImpact",
+			contains: "MinImpact is set without MaxImpact",
+		},
+		{
+			name:     "negative timeframe",
+			event:    func(e *montecargo.Event) { e.Timeframe = -montecargo.Yearly },
+			field:    "Timeframe",
+			contains: "is negative",
+		},
+		{
+			name:         "unknown dependency",
+			dependencies: map[string][]montecargo.Dependency{"Data Breach": {{EventName: "Phishing", Condition: "happens"}}},
+			field:        "Dependencies",
+			contains:     `depends on unknown event "Phishing"`,
+		},
+		{
+			name:         "self reference",
+			dependencies: map[string][]montecargo.Dependency{"Data Breach": {{EventName: "Data Breach", Condition: "happens"}}},
+			field:        "Dependencies",
+			contains:     `event "Data Breach" depends on itself`,
+		},
+		{
+			name:         "unknown condition",
+			dependencies: map[string][]montecargo.Dependency{"Data Breach": {{EventName: "Breach Detected", Condition: "sometimes"}}},
+			field:        "Dependencies",
+			contains:     `unknown condition "sometimes" on "Breach Detected"`,
+		},
+	}
+
+	detection := montecargo.Event{Name: "Breach Detected", LowerProb: 0.5, UpperProb: 0.5, Timeframe: montecargo.Yearly, IsCostSaving: true}
+	assert.Empty(t, montecargo.Validate([]montecargo.Event{valid, detection}, controlledDependencies, montecargo.Yearly))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := valid
+			if tt.event != nil {
+				tt.event(&event)
+			}
+			events := []montecargo.Event{event, detection}
+
+			diagnostics := montecargo.Validate(events, tt.dependencies, montecargo.Yearly)
+			if assert.True(t, diagnostics.HasErrors()) {
+				first := diagnostics.Errors()[0]
+				assert.Equal(t, montecargo.SeverityError, first.Severity)
+				assert.Equal(t, "Data Breach", first.Event)
+				assert.Equal(t, tt.field, first.Field)
+				assert.Contains(t, first.Message, tt.contains)
+			}
+
+			_, err := montecargo.NewSimulator().Run(context.Background(), events, 10, tt.dependencies)
+			var runDiagnostics montecargo.Diagnostics
+			assert.True(t, errors.As(err, &runDiagnostics), "simulations refuse models with errors")
+			result, err := montecargo.MonteCarloSimulation(events, 10, tt.dependencies)
+			assert.Error(t, err)
+			assert.Empty(t, result.EventStats)
+		})
+	}
+}
+
+func TestValidateDuplicatesAndWarnings(t *testing.T) {
+	events := []montecargo.Event{
+		{Name: "Outage", LowerProb: 0.1, UpperProb: 0.2, Timeframe: montecargo.Yearly},
+		{Name: "Outage", LowerProb: 0.3, UpperProb: 0.4, Timeframe: montecargo.Yearly},
+		{Name: "Certain Decade", LowerProb: 1, UpperProb: 1, Timeframe: montecargo.EveryTenYears},
+	}
+
+	diagnostics := montecargo.Validate(events, nil, montecargo.Yearly)
+	if assert.Len(t, diagnostics, 2) {
+		assert.Equal(t, montecargo.Diagnostic{Severity: montecargo.SeverityError, Event: "Outage", Field: "Name", Message: `duplicate event name "Outage"; events are looked up by name, so only the first would be used`, Err: montecargo.ErrDuplicateEvent}, diagnostics[0])
+		assert.Equal(t, montecargo.SeverityWarning, diagnostics[1].Severity)
+		assert.Equal(t, "Certain Decade", diagnostics[1].Event)
+	}
+	assert.Len(t, diagnostics.Warnings(), 1)
+
+	// warnings alone don't stop a simulation
+	_, err := montecargo.NewSimulator().Run(context.Background(), events[1:], 10, nil)
+	assert.NoError(t, err)
+}
+
+func TestValidateImpactDistributions(t *testing.T) {
+	tests := []struct {
+		name         string
+		distribution montecargo.Distribution
+		contains     string
+	}{
+		{"negative lognormal sigma", montecargo.LogNormal{Mu: 10, Sigma: -1}, "lognormal distribution Sigma -1 is negative"},
+		{"triangular min above max", montecargo.Triangular{Min: 100, Mode: 50, Max: 10}, "triangular distribution Min 100 is greater than Max 10"},
+		{"pert mode outside range", montecargo.PERT{Min: 10, Mode: 200, Max: 100}, "PERT distribution Mode 200 is not between Min 10 and Max 100"},
+		{"gamma without shape", montecargo.Gamma{Shape: 0, Scale: 1_000}, "gamma distribution Shape 0 must be positive"},
+		{"pareto without shape", montecargo.Pareto{Scale: 1_000, Shape: 0}, "Pareto distribution Shape 0 must be positive"},
+		{"not a number", montecargo.Normal{Mu: math.NaN(), Sigma: 1}, "normal distribution has a parameter that is not a finite number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []montecargo.Event{{Name: "Data Breach", LowerProb: 0.1, UpperProb: 0.3, Timeframe: montecargo.Yearly, ImpactDistribution: tt.distribution}}
+			diagnostics := montecargo.Validate(events, nil, montecargo.Yearly)
+			if assert.Len(t, diagnostics.Errors(), 1) {
+				first := diagnostics.Errors()[0]
+				assert.Equal(t, "ImpactDistribution", first.Field)
+				assert.ErrorIs(t, first.Err, montecargo.ErrInvalidImpact)
+				assert.Equal(t, tt.contains, first.Message)
+			}
+			_, err := montecargo.MonteCarloSimulation(events, 10, nil)
+			assert.ErrorIs(t, err, montecargo.ErrInvalidImpact)
+		})
+	}
+
+	model := `version: 1
+events:
+  - name: Data Breach
+    lowerProb: 0.1
+    upperProb: 0.3
+    timeframe: P1Y
+    impactDistribution:
+      type: pareto
+      scale: 1000
+      shape: 0
+`
+	_, err := montecargo.ParseModel([]byte(model), "model.yaml")
+	var modelErrs montecargo.ModelErrors
+	if assert.ErrorAs(t, err, &modelErrs) {
+		assert.Equal(t, []string{`model.yaml:8:7: event "Data Breach": Pareto distribution Shape 0 must be positive`}, errorStrings(modelErrs))
+	}
+}
+
+func TestValidateHorizon(t *testing.T) {
+	events := []montecargo.Event{{Name: "Outage", LowerProb: 0.1, UpperProb: 0.2, Timeframe: montecargo.Yearly}}
+
+	diagnostics := montecargo.Validate(events, nil, -montecargo.Yearly)
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, "Horizon", diagnostics[0].Field)
+		assert.ErrorIs(t, diagnostics[0].Err, montecargo.ErrInvalidValue)
+		assert.Contains(t, diagnostics[0].Message, "is negative")
+	}
+	_, err := montecargo.MonteCarloSimulation(events, 10, nil, montecargo.WithHorizon(-montecargo.Yearly))
+	assert.ErrorIs(t, err, montecargo.ErrInvalidValue)
+
+	// a zero horizon is a year, like a zero Timeframe
+	assert.Empty(t, montecargo.Validate(events, nil, 0))
+	result, err := montecargo.MonteCarloSimulation(events, 10, nil, montecargo.WithHorizon(0))
+	if assert.NoError(t, err) {
+		assert.Equal(t, montecargo.Yearly, result.Horizon)
+	}
+
+	model := `version: 1
+settings:
+  horizon: P0D
+events:
+  - name: Outage
+    lowerProb: 0.1
+    upperProb: 0.2
+    timeframe: P1Y
+`
+	_, err = montecargo.ParseModel([]byte(model), "model.yaml")
+	var modelErrs montecargo.ModelErrors
+	if assert.ErrorAs(t, err, &modelErrs) {
+		assert.Equal(t, []string{`model.yaml:3:12: invalid timeframe "P0D": duration must be positive`}, errorStrings(modelErrs))
+	}
+}
+
+func TestValidateModelFilePositions(t *testing.T) {
+	model := `version: 1
+events:
+  - name: A
+    lowerProb: 0.6
+    upperProb: 0.4
+    timeframe: P1Y
+    minImpact: 10
+`
+	_, err := montecargo.ParseModel([]byte(model), "model.yaml")
+	var modelErrs montecargo.ModelErrors
+	if assert.True(t, errors.As(err, &modelErrs)) {
+		assert.Equal(t, []string{
+			`model.yaml:4:16: event "A": LowerProb 0.6 is greater than UpperProb 0.4`,
+			`model.yaml:7:16: event "A": MinImpact is set without MaxImpact`,
+		}, errorStrings(modelErrs))
+	}
+
+	code, _, stderr := runCLI("run", writeModelFile(t, "invalid.yaml", model))
+	assert.Equal(t, cli.ExitValidation, code)
+	assert.Contains(t, stderr, "invalid.yaml:4:16:")
+}
+
+func errorStrings(errs montecargo.ModelErrors) []string {
+	strings := make([]string, len(errs))
+	for i, err := range errs {
+		strings[i] = err.Error()
+	}
+	return strings
+}
+
+func TestValidateUnknownFrequency(t *testing.T) {
+	events := []montecargo.Event{{Name: "Outage", LowerProb: 0.1, UpperProb: 0.2, Timeframe: montecargo.Yearly, Frequency: montecargo.Frequency(7)}}
+
+	diagnostics := montecargo.Validate(events, nil, montecargo.Yearly)
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, "Frequency", diagnostics[0].Field)
+		assert.ErrorIs(t, diagnostics[0].Err, montecargo.ErrInvalidValue)
+		assert.Equal(t, "unknown Frequency 7", diagnostics[0].Message)
+	}
+}